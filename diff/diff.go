@@ -0,0 +1,313 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bluesky0724/jsonstream/encoder"
+	"github.com/bluesky0724/jsonstream/jsonpath"
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// ChangeKind tells how an element differs between the documents
+type ChangeKind int
+
+const (
+	Changed ChangeKind = iota // the element is in both documents with different values
+	Added                     // the element is only in the new document
+	Removed                   // the element is only in the old document
+)
+
+// String returns the name of the change kind
+func (k ChangeKind) String() string {
+	switch k {
+	case Changed:
+		return "changed"
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Change is an element of the base that differs between the documents
+type Change struct {
+	Kind       ChangeKind
+	Key        any         // the value of the key field of the element, nil when it has none
+	OldPointer string      // the location of the element in the old document, e.g. "/dataset/3", empty when added
+	NewPointer string      // the location of the element in the new document, empty when removed
+	Old        any         // the element in the old document, nil when added
+	New        any         // the element in the new document, nil when removed
+	Operations []Operation // the differences inside a changed element, with paths relative to the element
+}
+
+// Operation is one difference between two values, in the terms of JSON Patch (RFC 6902)
+type Operation struct {
+	Op    string // "add", "remove" or "replace"
+	Path  string // the JSON Pointer of the value, e.g. "/publisher/name", "/keyword/-" to append to an array
+	Value any    // the new value of add and replace
+	Old   any    // the previous value of remove and replace
+}
+
+// Options configures Diff
+type Options struct {
+	// Base selects the compared elements: the array holding them, dotted like ".dataset" or a JSON Pointer
+	// like "/dataset", or a JSONPath expression selecting them like "$.dataset[*]"; the root array when empty
+	Base string
+	// Key is the field identifying an element, relative to it, e.g. "identifier" or "/identifier"
+	// Elements with the same key are matched in order; elements are matched by position when Key is empty
+	Key string
+	// TempDir is where the elements are spilled while the documents are read, the default temporary directory when empty
+	TempDir string
+	// Format selects the output of Write
+	Format Format
+	// Encoder configures the JSON Patch output of Write
+	Encoder encoder.Options
+	// Parser configures the parsers, strings are always decoded and numbers kept as text
+	Parser parser.Options
+}
+
+// Diff streams both documents, matches the elements of the base by key and calls handler with each difference
+// The documents are read in parallel and their elements spilled to temporary files: only the keys and the
+// locations of the elements are kept in memory, and two elements at a time once both documents are read
+// The changes come in the order that makes them a valid patch of the old document: the changed elements in the
+// order of the new document, then the removed elements from the last one, then the added elements
+func Diff(oldReader io.Reader, newReader io.Reader, opts Options, handler func(Change) error) error {
+	base, err := elementPath(opts.Base)
+	if err != nil {
+		return fmt.Errorf("invalid base field: %w", err)
+	}
+	key, err := keyPointer(opts.Key)
+	if err != nil {
+		return fmt.Errorf("invalid key field: %w", err)
+	}
+
+	parserOptions := encoder.PipeOptions(opts.Parser)
+	readers := []io.Reader{oldReader, newReader}
+	stores := make([]*store, len(readers))
+	for i := range readers {
+		if stores[i], err = newStore(opts.TempDir); err != nil {
+			return err
+		}
+		defer stores[i].close()
+	}
+
+	errs := make(chan error, len(readers))
+	for i, reader := range readers {
+		go func() {
+			errs <- spill(reader, stores[i], base, key, parserOptions)
+		}()
+	}
+	for range readers {
+		if spillErr := <-errs; spillErr != nil && err == nil {
+			err = spillErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return compare(stores[0], stores[1], key, handler)
+}
+
+// spill reads the elements of one document into its store
+func spill(reader io.Reader, s *store, base *jsonpath.Path, key parser.Pointer, opts parser.Options) error {
+	position := 0
+	err := jsonpath.Stream(reader, base.String(), opts, func(match jsonpath.Match) error {
+		elementKey := any(position)
+		if key != nil {
+			elementKey = keyOf(match.Value, key)
+		}
+		position++
+		return s.add(elementKey, match.Pointer, match.Value)
+	})
+	if err != nil {
+		return err
+	}
+	return s.finish()
+}
+
+// compare reports the differences between the spilled elements of the documents
+func compare(oldStore *store, newStore *store, key parser.Pointer, handler func(Change) error) error {
+	var added []int
+	for i, newEntry := range newStore.entries {
+		j, ok := oldStore.index[newEntry.matchKey]
+		if !ok {
+			added = append(added, i)
+			continue
+		}
+		oldStore.entries[j].matched = true
+
+		oldValue, oldBody, err := oldStore.read(j)
+		if err != nil {
+			return err
+		}
+		newValue, newBody, err := newStore.read(i)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(oldBody, newBody) {
+			continue
+		}
+		change := Change{
+			Kind:       Changed,
+			Key:        keyOf(newValue, key),
+			OldPointer: oldStore.entries[j].pointer,
+			NewPointer: newEntry.pointer,
+			Old:        oldValue,
+			New:        newValue,
+			Operations: compareValues("", oldValue, newValue, nil),
+		}
+		if err := handler(change); err != nil {
+			return err
+		}
+	}
+
+	for j := len(oldStore.entries) - 1; j >= 0; j-- {
+		if oldStore.entries[j].matched {
+			continue
+		}
+		oldValue, _, err := oldStore.read(j)
+		if err != nil {
+			return err
+		}
+		change := Change{Kind: Removed, Key: keyOf(oldValue, key), OldPointer: oldStore.entries[j].pointer, Old: oldValue}
+		if err := handler(change); err != nil {
+			return err
+		}
+	}
+
+	for _, i := range added {
+		newValue, _, err := newStore.read(i)
+		if err != nil {
+			return err
+		}
+		change := Change{Kind: Added, Key: keyOf(newValue, key), NewPointer: newStore.entries[i].pointer, New: newValue}
+		if err := handler(change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compareValues appends the operations turning old into new, at path
+// Objects are compared by key and arrays by position, any other difference replaces the value
+func compareValues(path string, old any, new any, operations []Operation) []Operation {
+	switch old := old.(type) {
+	case map[string]any:
+		if new, ok := new.(map[string]any); ok {
+			for _, name := range sortedKeys(old) {
+				if newValue, ok := new[name]; ok {
+					operations = compareValues(path+"/"+escape(name), old[name], newValue, operations)
+				} else {
+					operations = append(operations, Operation{Op: "remove", Path: path + "/" + escape(name), Old: old[name]})
+				}
+			}
+			for _, name := range sortedKeys(new) {
+				if _, ok := old[name]; !ok {
+					operations = append(operations, Operation{Op: "add", Path: path + "/" + escape(name), Value: new[name]})
+				}
+			}
+			return operations
+		}
+	case []any:
+		if new, ok := new.([]any); ok {
+			common := min(len(old), len(new))
+			for i := 0; i < common; i++ {
+				operations = compareValues(path+"/"+strconv.Itoa(i), old[i], new[i], operations)
+			}
+			// Removing from the end keeps the positions of the previous operations valid
+			for i := len(old) - 1; i >= common; i-- {
+				operations = append(operations, Operation{Op: "remove", Path: path + "/" + strconv.Itoa(i), Old: old[i]})
+			}
+			for i := common; i < len(new); i++ {
+				operations = append(operations, Operation{Op: "add", Path: path + "/-", Value: new[i]})
+			}
+			return operations
+		}
+	}
+	if reflect.DeepEqual(old, new) {
+		return operations
+	}
+	return append(operations, Operation{Op: "replace", Path: path, Value: new, Old: old})
+}
+
+// elementPath compiles the JSONPath expression selecting the elements of a dotted, pointer or JSONPath base
+func elementPath(base string) (*jsonpath.Path, error) {
+	if jsonpath.IsPath(base) {
+		return jsonpath.Compile(base)
+	}
+
+	var tokens parser.Pointer
+	if base == "" || parser.IsPointer(base) {
+		var err error
+		if tokens, err = parser.ParsePointer(base); err != nil {
+			return nil, err
+		}
+	} else {
+		tokens = strings.Split(strings.TrimPrefix(base, "."), ".")
+	}
+
+	var expression strings.Builder
+	expression.WriteString("$")
+	for _, token := range tokens {
+		quoted := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(token)
+		expression.WriteString(`["` + quoted + `"]`)
+	}
+	expression.WriteString("[*]")
+	return jsonpath.Compile(expression.String())
+}
+
+// keyPointer parses a dotted or pointer key field, nil when the elements are matched by position
+func keyPointer(key string) (parser.Pointer, error) {
+	if key == "" {
+		return nil, nil
+	}
+	if parser.IsPointer(key) {
+		return parser.ParsePointer(key)
+	}
+	return strings.Split(key, "."), nil
+}
+
+// keyOf returns the value of the key field of an element, nil when it is missing or the elements have no key
+func keyOf(value any, key parser.Pointer) any {
+	if key == nil {
+		return nil
+	}
+	for _, token := range key {
+		switch container := value.(type) {
+		case map[string]any:
+			value = container[token]
+		case []any:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(container) {
+				return nil
+			}
+			value = container[index]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+// sortedKeys returns the keys of an object in order, for a stable output
+func sortedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// escape escapes a key as a reference token of a JSON Pointer
+func escape(key string) string {
+	return parser.Pointer{key}.String()[1:]
+}
@@ -0,0 +1,135 @@
+package diff
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const oldCatalog = `{"dataset": [
+	{"identifier": "a", "title": "A", "keyword": ["x", "y"]},
+	{"identifier": "b", "title": "B"},
+	{"identifier": "c", "title": "C", "publisher": {"name": "GSA"}},
+	{"identifier": "d", "title": "D"}
+]}`
+
+const newCatalog = `{"dataset": [
+	{"identifier": "c", "title": "C", "publisher": {"name": "NASA", "url": "https://nasa.gov"}},
+	{"identifier": "a", "title": "A", "keyword": ["x"]},
+	{"identifier": "e", "title": "E"},
+	{"identifier": "b", "title": "B"}
+]}`
+
+// collect runs Diff and returns the changes
+func collect(t *testing.T, oldDocument string, newDocument string, opts Options) []Change {
+	t.Helper()
+	opts.TempDir = t.TempDir()
+	var changes []Change
+	err := Diff(strings.NewReader(oldDocument), strings.NewReader(newDocument), opts, func(change Change) error {
+		changes = append(changes, change)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if files, _ := os.ReadDir(opts.TempDir); len(files) > 0 {
+		t.Errorf("Diff() left %d spill files", len(files))
+	}
+	return changes
+}
+
+func TestDiff(t *testing.T) {
+	changes := collect(t, oldCatalog, newCatalog, Options{Base: ".dataset", Key: "identifier"})
+
+	expected := []Change{
+		{Kind: Changed, Key: "c", OldPointer: "/dataset/2", NewPointer: "/dataset/0", Operations: []Operation{
+			{Op: "replace", Path: "/publisher/name", Value: "NASA", Old: "GSA"},
+			{Op: "add", Path: "/publisher/url", Value: "https://nasa.gov"},
+		}},
+		{Kind: Changed, Key: "a", OldPointer: "/dataset/0", NewPointer: "/dataset/1", Operations: []Operation{
+			{Op: "remove", Path: "/keyword/1", Old: "y"},
+		}},
+		{Kind: Removed, Key: "d", OldPointer: "/dataset/3"},
+		{Kind: Added, Key: "e", NewPointer: "/dataset/2"},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Diff() = %d changes, want %d: %+v", len(changes), len(expected), changes)
+	}
+	for i, change := range changes {
+		if change.Kind == Changed && (change.Old == nil || change.New == nil) {
+			t.Errorf("change %d holds the elements %v and %v", i, change.Old, change.New)
+		}
+		change.Old, change.New = nil, nil
+		if !reflect.DeepEqual(change, expected[i]) {
+			t.Errorf("change %d = %+v, want %+v", i, change, expected[i])
+		}
+	}
+}
+
+func TestDiffOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		oldDocument string
+		newDocument string
+		opts        Options
+		expected    []string
+	}{
+		{"pointers", oldCatalog, newCatalog, Options{Base: "/dataset", Key: "/identifier"},
+			[]string{`changed "c"`, `changed "a"`, `removed "d"`, `added "e"`}},
+		{"JSONPath base", oldCatalog, newCatalog, Options{Base: "$.dataset[?(@.identifier != 'c')]", Key: "identifier"},
+			[]string{`changed "a"`, `removed "d"`, `added "e"`}},
+		{"by position", `[1, 2, {"a": 3}]`, `[1, 5, {"a": 3}, 4]`, Options{},
+			[]string{"changed <nil>", "added <nil>"}},
+		{"duplicate keys", `[{"id": 1, "v": 1}, {"id": 1, "v": 2}]`, `[{"id": 1, "v": 1}, {"id": 1, "v": 3}, {"id": 1}]`, Options{Key: "id"},
+			[]string{"changed 1", "added 1"}},
+		{"numbers keep their text", `[{"id": 1, "v": 1.0}]`, `[{"id": 1, "v": 1}]`, Options{Key: "id"},
+			[]string{"changed 1"}},
+		{"missing keys", `[{"v": 1}]`, `[{"v": 1}, {"v": 2}]`, Options{Key: "id"},
+			[]string{"added <nil>"}},
+		{"identical", oldCatalog, oldCatalog, Options{Base: ".dataset", Key: "identifier"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var summary []string
+			for _, change := range collect(t, tt.oldDocument, tt.newDocument, tt.opts) {
+				summary = append(summary, change.Kind.String()+" "+formatKey(change.Key))
+			}
+			if !reflect.DeepEqual(summary, tt.expected) {
+				t.Errorf("Diff() = %q, want %q", summary, tt.expected)
+			}
+		})
+	}
+}
+
+// formatKey formats a key in the summaries of TestDiffOptions
+func formatKey(value any) string {
+	if value == nil {
+		return "<nil>"
+	}
+	body, _ := marshal(value)
+	return string(body)
+}
+
+func TestDiffErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		oldDocument string
+		newDocument string
+		opts        Options
+	}{
+		{"invalid old document", `[1,`, `[]`, Options{}},
+		{"invalid new document", `[]`, `{"a"`, Options{}},
+		{"invalid base", `[]`, `[]`, Options{Base: "$.a[?("}},
+		{"invalid key", `[]`, `[]`, Options{Key: "/~2"}},
+		{"missing temporary directory", `[]`, `[]`, Options{TempDir: "/nonexistent/directory"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Diff(strings.NewReader(tt.oldDocument), strings.NewReader(tt.newDocument), tt.opts, func(Change) error { return nil })
+			if err == nil {
+				t.Error("Diff() error = nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package diff
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bluesky0724/jsonstream/encoder"
+)
+
+// Format defines how Write reports the changes
+type Format int
+
+const (
+	// CSVReport writes one row per added or removed element and per changed path, see CSVWriter
+	CSVReport Format = iota
+	// JSONPatch writes a JSON Patch (RFC 6902) document, see PatchWriter
+	JSONPatch
+)
+
+// Write compares the documents and writes the changes to w in opts.Format
+func Write(oldReader io.Reader, newReader io.Reader, w io.Writer, opts Options) error {
+	switch opts.Format {
+	case CSVReport:
+		report := NewCSVWriter(csv.NewWriter(w))
+		if err := Diff(oldReader, newReader, opts, report.Write); err != nil {
+			return err
+		}
+		return report.Flush()
+	case JSONPatch:
+		patch := NewPatchWriter(w, opts.Encoder)
+		if err := Diff(oldReader, newReader, opts, patch.Write); err != nil {
+			return err
+		}
+		return patch.Close()
+	}
+	return fmt.Errorf("unknown format %d", opts.Format)
+}
+
+// PatchWriter writes the changes as a JSON Patch turning the old document into the new one,
+// apart from the order of the elements: the added elements are appended to their array
+// It relies on the order of the changes given by Diff
+type PatchWriter struct {
+	encoder *encoder.JSONEncoder
+	started bool
+}
+
+// NewPatchWriter creates a writer of a JSON Patch document to w
+func NewPatchWriter(w io.Writer, opts encoder.Options) *PatchWriter {
+	return &PatchWriter{encoder: encoder.NewJSONEncoder(w, opts)}
+}
+
+// Write writes the operations of one change
+func (p *PatchWriter) Write(change Change) error {
+	if !p.started {
+		p.started = true
+		if err := p.encoder.BeginArray(); err != nil {
+			return err
+		}
+	}
+
+	switch change.Kind {
+	case Changed:
+		for _, operation := range change.Operations {
+			operation.Path = change.OldPointer + operation.Path
+			if err := p.writeOperation(operation); err != nil {
+				return err
+			}
+		}
+		return nil
+	case Removed:
+		return p.writeOperation(Operation{Op: "remove", Path: change.OldPointer})
+	}
+	array := change.NewPointer[:strings.LastIndexByte(change.NewPointer, '/')]
+	return p.writeOperation(Operation{Op: "add", Path: array + "/-", Value: change.New})
+}
+
+// writeOperation writes one operation object
+func (p *PatchWriter) writeOperation(operation Operation) error {
+	e := p.encoder
+	e.BeginObject()
+	e.Key("op")
+	e.String(operation.Op)
+	e.Key("path")
+	e.String(operation.Path)
+	if operation.Op != "remove" {
+		e.Key("value")
+		writeValue(e, operation.Value)
+	}
+	// The encoder keeps the first error
+	return e.EndObject()
+}
+
+// Close ends the patch, an empty array when there is no change, and flushes the output
+func (p *PatchWriter) Close() error {
+	if !p.started {
+		p.started = true
+		if err := p.encoder.BeginArray(); err != nil {
+			return err
+		}
+	}
+	if err := p.encoder.EndArray(); err != nil {
+		return err
+	}
+	return p.encoder.Close()
+}
+
+// writeValue writes a decoded value, the members of objects sorted by key
+func writeValue(e *encoder.JSONEncoder, value any) error {
+	switch value := value.(type) {
+	case map[string]any:
+		e.BeginObject()
+		for _, key := range sortedKeys(value) {
+			e.Key(key)
+			writeValue(e, value[key])
+		}
+		return e.EndObject()
+	case []any:
+		e.BeginArray()
+		for _, item := range value {
+			writeValue(e, item)
+		}
+		return e.EndArray()
+	}
+	return e.Value(value)
+}
+
+// CSVWriter writes the changes as a CSV report with the columns change, key, pointer, path, old and new:
+// one row per added or removed element, holding the whole element, and one row per operation of a changed
+// element, with the path relative to the element
+// Strings are written as they are, the other values as JSON
+type CSVWriter struct {
+	writer  *csv.Writer
+	started bool
+}
+
+// NewCSVWriter creates a writer of a CSV report
+func NewCSVWriter(writer *csv.Writer) *CSVWriter {
+	return &CSVWriter{writer: writer}
+}
+
+// Write writes the rows of one change
+func (c *CSVWriter) Write(change Change) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	kind, key := change.Kind.String(), ""
+	if change.Key != nil {
+		key = cell(change.Key)
+	}
+	switch change.Kind {
+	case Added:
+		return c.writer.Write([]string{kind, key, change.NewPointer, "", "", cell(change.New)})
+	case Removed:
+		return c.writer.Write([]string{kind, key, change.OldPointer, "", cell(change.Old), ""})
+	}
+	for _, operation := range change.Operations {
+		var old, new string
+		if operation.Op != "add" {
+			old = cell(operation.Old)
+		}
+		if operation.Op != "remove" {
+			new = cell(operation.Value)
+		}
+		if err := c.writer.Write([]string{kind, key, change.OldPointer, operation.Path, old, new}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes the header if no change was written and flushes the output
+func (c *CSVWriter) Flush() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// writeHeader writes the header row before the first row
+func (c *CSVWriter) writeHeader() error {
+	if c.started {
+		return nil
+	}
+	c.started = true
+	return c.writer.Write([]string{"change", "key", "pointer", "path", "old", "new"})
+}
+
+// cell formats a value for the report
+func cell(value any) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	body, err := marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(body)
+}
@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/bluesky0724/jsonstream/encoder"
+)
+
+func TestWritePatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		oldDocument string
+		newDocument string
+		expected    string
+	}{
+		{"catalog", oldCatalog, newCatalog, `[` +
+			`{"op":"replace","path":"/dataset/2/publisher/name","value":"NASA"},` +
+			`{"op":"add","path":"/dataset/2/publisher/url","value":"https://nasa.gov"},` +
+			`{"op":"remove","path":"/dataset/0/keyword/1"},` +
+			`{"op":"remove","path":"/dataset/3"},` +
+			`{"op":"add","path":"/dataset/-","value":{"identifier":"e","title":"E"}}` +
+			`]`},
+		{"no change", oldCatalog, oldCatalog, `[]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			opts := Options{Base: ".dataset", Key: "identifier", Format: JSONPatch, TempDir: t.TempDir()}
+			if err := Write(strings.NewReader(tt.oldDocument), strings.NewReader(tt.newDocument), &output, opts); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if output.String() != tt.expected {
+				t.Errorf("Write() = %s, want %s", output.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestPatchWriterIndent(t *testing.T) {
+	var output bytes.Buffer
+	patch := NewPatchWriter(&output, encoder.Options{Indent: " "})
+	change := Change{Kind: Changed, OldPointer: "/0", Operations: []Operation{{Op: "replace", Path: "/a", Value: []any{nil, true}}}}
+	if err := patch.Write(change); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := patch.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	expected := "[\n {\n  \"op\": \"replace\",\n  \"path\": \"/0/a\",\n  \"value\": [\n   null,\n   true\n  ]\n }\n]"
+	if output.String() != expected {
+		t.Errorf("output = %q, want %q", output.String(), expected)
+	}
+}
+
+func TestWriteCSVReport(t *testing.T) {
+	var output bytes.Buffer
+	opts := Options{Base: ".dataset", Key: "identifier", TempDir: t.TempDir()}
+	if err := Write(strings.NewReader(oldCatalog), strings.NewReader(newCatalog), &output, opts); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	expected := "change,key,pointer,path,old,new\n" +
+		"changed,c,/dataset/2,/publisher/name,GSA,NASA\n" +
+		"changed,c,/dataset/2,/publisher/url,,https://nasa.gov\n" +
+		"changed,a,/dataset/0,/keyword/1,y,\n" +
+		"removed,d,/dataset/3,,\"{\"\"identifier\"\":\"\"d\"\",\"\"title\"\":\"\"D\"\"}\",\n" +
+		"added,e,/dataset/2,,,\"{\"\"identifier\"\":\"\"e\"\",\"\"title\"\":\"\"E\"\"}\"\n"
+	if output.String() != expected {
+		t.Errorf("Write() = %s, want %s", output.String(), expected)
+	}
+
+	var empty bytes.Buffer
+	report := NewCSVWriter(csv.NewWriter(&empty))
+	if err := report.Flush(); err != nil || empty.String() != "change,key,pointer,path,old,new\n" {
+		t.Errorf("Flush() = %q, %v, want the header", empty.String(), err)
+	}
+}
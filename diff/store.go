@@ -0,0 +1,119 @@
+package diff
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// store spills the elements of one document to a temporary file, only their keys and locations stay in memory
+type store struct {
+	file    *os.File
+	writer  *bufio.Writer
+	size    int64          // the number of bytes written to the file
+	entries []entry        // the elements in document order
+	index   map[string]int // the position of each element in entries by match key
+	seen    map[string]int // the number of elements found so far for each key
+}
+
+// entry locates one spilled element
+type entry struct {
+	matchKey string // the JSON of the value of the key field, followed by the occurrence for duplicates
+	pointer  string // the location of the element in its document, e.g. "/dataset/3"
+	offset   int64  // the position of the element's JSON in the file
+	length   int
+	matched  bool // whether an element of the other document has the same match key
+}
+
+// newStore creates a store in a new temporary file of dir
+func newStore(dir string) (*store, error) {
+	file, err := os.CreateTemp(dir, "jsonstream-diff-*.json")
+	if err != nil {
+		return nil, fmt.Errorf("error creating spill file: %w", err)
+	}
+	return &store{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		index:  make(map[string]int),
+		seen:   make(map[string]int),
+	}, nil
+}
+
+// add spills an element, the elements with the same key are told apart by their order
+func (s *store) add(key any, pointer string, value any) error {
+	body, err := marshal(value)
+	if err != nil {
+		return err
+	}
+	if _, err := s.writer.Write(body); err != nil {
+		return fmt.Errorf("error writing spill file: %w", err)
+	}
+
+	keyText, err := marshal(key)
+	if err != nil {
+		return err
+	}
+	matchKey := string(keyText)
+	if occurrence := s.seen[matchKey]; occurrence > 0 {
+		matchKey += "\x00" + strconv.Itoa(occurrence)
+	}
+	s.seen[string(keyText)]++
+
+	s.index[matchKey] = len(s.entries)
+	s.entries = append(s.entries, entry{matchKey: matchKey, pointer: pointer, offset: s.size, length: len(body)})
+	s.size += int64(len(body))
+	return nil
+}
+
+// finish flushes the spilled elements so that they can be read
+func (s *store) finish() error {
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("error writing spill file: %w", err)
+	}
+	return nil
+}
+
+// read loads a spilled element back
+func (s *store) read(position int) (any, []byte, error) {
+	e := s.entries[position]
+	body := make([]byte, e.length)
+	if _, err := s.file.ReadAt(body, e.offset); err != nil {
+		return nil, nil, fmt.Errorf("error reading spill file: %w", err)
+	}
+	value, err := unmarshal(body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, body, nil
+}
+
+// close removes the temporary file
+func (s *store) close() error {
+	s.file.Close()
+	return os.Remove(s.file.Name())
+}
+
+// marshal encodes a value as compact JSON, with sorted keys and without HTML escapes
+func marshal(value any) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, fmt.Errorf("error encoding element: %w", err)
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil // without the newline of Encode
+}
+
+// unmarshal decodes a spilled value, keeping numbers as their text
+func unmarshal(body []byte) (any, error) {
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("error decoding element: %w", err)
+	}
+	return value, nil
+}
@@ -0,0 +1,331 @@
+package encoder
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// Options configures the output of a JSONEncoder
+type Options struct {
+	// Indent is repeated once per nesting level before each element, compact output when empty
+	Indent string
+	// Prefix starts every line of indented output
+	Prefix string
+	// EscapeHTML escapes '<', '>' and '&' in strings like encoding/json does by default
+	EscapeHTML bool
+	// MultipleValues allows several top-level values, each followed by a newline (e.g. NDJSON)
+	// Without it the encoder accepts a single document
+	MultipleValues bool
+}
+
+// scope is the kind of container the encoder is writing into
+type scope int
+
+const (
+	topLevel scope = iota
+	inObject
+	inArray
+)
+
+// frame tracks the state of one open container
+type frame struct {
+	scope   scope
+	count   int  // number of values written in the container
+	keyNext bool // for objects: whether a key must come before the next value
+}
+
+// JSONEncoder writes a JSON document token by token, without building it in memory
+// It escapes strings, optionally indents, and validates the order of the calls,
+// e.g. a value in an object without a key or an EndArray closing an object is an error
+// The first error is kept and returned by every following call
+type JSONEncoder struct {
+	writer *bufio.Writer
+	opts   Options
+	stack  []frame // the open containers, the first frame is the top level
+	err    error
+}
+
+// NewJSONEncoder creates a new encoder writing to w
+func NewJSONEncoder(w io.Writer, opts Options) *JSONEncoder {
+	return &JSONEncoder{
+		writer: bufio.NewWriter(w),
+		opts:   opts,
+		stack:  []frame{{scope: topLevel}},
+	}
+}
+
+// BeginObject writes '{' and opens an object
+func (e *JSONEncoder) BeginObject() error {
+	return e.open(inObject, '{')
+}
+
+// EndObject writes '}' and closes the current object
+func (e *JSONEncoder) EndObject() error {
+	return e.close(inObject, '}')
+}
+
+// BeginArray writes '[' and opens an array
+func (e *JSONEncoder) BeginArray() error {
+	return e.open(inArray, '[')
+}
+
+// EndArray writes ']' and closes the current array
+func (e *JSONEncoder) EndArray() error {
+	return e.close(inArray, ']')
+}
+
+// Key writes the key of the next value of the current object
+func (e *JSONEncoder) Key(key string) error {
+	if e.err != nil {
+		return e.err
+	}
+	top := &e.stack[len(e.stack)-1]
+	if top.scope != inObject {
+		return e.fail(errors.New("key written outside of an object"))
+	}
+	if !top.keyNext {
+		return e.fail(fmt.Errorf("key %q written where a value is expected", key))
+	}
+
+	e.separate(top)
+	e.writeString(key)
+	e.writer.WriteByte(':')
+	if e.opts.Indent != "" {
+		e.writer.WriteByte(' ')
+	}
+	top.keyNext = false
+	return e.err
+}
+
+// String writes a string value
+func (e *JSONEncoder) String(value string) error {
+	if err := e.beginValue(); err != nil {
+		return err
+	}
+	e.writeString(value)
+	return e.endValue()
+}
+
+// Float writes a number value, NaN and infinities are not valid JSON
+func (e *JSONEncoder) Float(value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return e.fail(fmt.Errorf("unsupported number %v", value))
+	}
+	return e.raw(formatFloat(value))
+}
+
+// Int writes an integer value
+func (e *JSONEncoder) Int(value int64) error {
+	return e.raw(strconv.FormatInt(value, 10))
+}
+
+// Number writes a number given as JSON text as it is, e.g. to keep the digits of a json.Number
+func (e *JSONEncoder) Number(text string) error {
+	if !parser.IsValidNumber(text) {
+		return e.fail(fmt.Errorf("invalid number %q", text))
+	}
+	return e.raw(text)
+}
+
+// RawJSON writes a value given as JSON text as it is, e.g. an object captured from a document
+// The text is not checked, it must be a single valid JSON value
+func (e *JSONEncoder) RawJSON(text string) error {
+	return e.raw(text)
+}
+
+// Bool writes true or false
+func (e *JSONEncoder) Bool(value bool) error {
+	if value {
+		return e.raw("true")
+	}
+	return e.raw("false")
+}
+
+// Null writes null
+func (e *JSONEncoder) Null() error {
+	return e.raw("null")
+}
+
+// Value writes a primitive value of one of the types passed by the parser:
+// string, float64, int64, json.Number, bool or nil
+func (e *JSONEncoder) Value(value any) error {
+	switch v := value.(type) {
+	case nil:
+		return e.Null()
+	case string:
+		return e.String(v)
+	case float64:
+		return e.Float(v)
+	case int64:
+		return e.Int(v)
+	case int:
+		return e.Int(int64(v))
+	case json.Number:
+		return e.Number(string(v))
+	case bool:
+		return e.Bool(v)
+	}
+	return e.fail(fmt.Errorf("unsupported value type %T", value))
+}
+
+// Flush writes the buffered output to the underlying writer
+func (e *JSONEncoder) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	if err := e.writer.Flush(); err != nil {
+		return e.fail(err)
+	}
+	return nil
+}
+
+// Close verifies that the document is complete and flushes the output
+// It does not close the underlying writer
+func (e *JSONEncoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.stack) > 1 {
+		return e.fail(fmt.Errorf("%d containers left open", len(e.stack)-1))
+	}
+	if e.stack[0].count == 0 {
+		return e.fail(errors.New("no value written"))
+	}
+	return e.Flush()
+}
+
+// Depth returns the number of open objects and arrays
+func (e *JSONEncoder) Depth() int {
+	return len(e.stack) - 1
+}
+
+// open starts a container as a value of the current one
+func (e *JSONEncoder) open(s scope, delimiter byte) error {
+	if err := e.beginValue(); err != nil {
+		return err
+	}
+	e.writer.WriteByte(delimiter)
+	e.stack = append(e.stack, frame{scope: s, keyNext: s == inObject})
+	return e.err
+}
+
+// close ends the current container, which must be of the expected kind
+func (e *JSONEncoder) close(s scope, delimiter byte) error {
+	if e.err != nil {
+		return e.err
+	}
+	top := e.stack[len(e.stack)-1]
+	if top.scope != s {
+		return e.fail(fmt.Errorf("'%c' does not match the open container", delimiter))
+	}
+	if s == inObject && !top.keyNext {
+		return e.fail(errors.New("object closed after a key without its value"))
+	}
+
+	e.stack = e.stack[:len(e.stack)-1]
+	// Empty containers stay on one line like encoding/json does
+	if top.count > 0 {
+		e.newline()
+	}
+	e.writer.WriteByte(delimiter)
+	return e.endValue()
+}
+
+// beginValue checks that a value is allowed at this point and writes what comes before it
+func (e *JSONEncoder) beginValue() error {
+	if e.err != nil {
+		return e.err
+	}
+	top := &e.stack[len(e.stack)-1]
+	switch top.scope {
+	case inObject:
+		if top.keyNext {
+			return e.fail(errors.New("value written in an object without a key"))
+		}
+	case inArray:
+		e.separate(top)
+	case topLevel:
+		if top.count > 0 && !e.opts.MultipleValues {
+			return e.fail(errors.New("more than one top-level value"))
+		}
+		e.writer.WriteString(e.opts.Prefix)
+	}
+	return nil
+}
+
+// endValue updates the state of the container once a value is complete
+func (e *JSONEncoder) endValue() error {
+	top := &e.stack[len(e.stack)-1]
+	top.count++
+	switch top.scope {
+	case inObject:
+		top.keyNext = true
+	case topLevel:
+		if e.opts.MultipleValues {
+			e.writer.WriteByte('\n')
+		}
+	}
+	return e.err
+}
+
+// separate writes the comma and the indentation before an element of an array or a key of an object
+func (e *JSONEncoder) separate(top *frame) {
+	if top.count > 0 {
+		e.writer.WriteByte(',')
+	}
+	e.newline()
+}
+
+// newline starts a new indented line for the current nesting level, nothing in compact output
+func (e *JSONEncoder) newline() {
+	if e.opts.Indent == "" {
+		return
+	}
+	e.writer.WriteByte('\n')
+	e.writer.WriteString(e.opts.Prefix)
+	for i := 1; i < len(e.stack); i++ {
+		e.writer.WriteString(e.opts.Indent)
+	}
+}
+
+// raw writes a value that needs no escaping
+func (e *JSONEncoder) raw(text string) error {
+	if err := e.beginValue(); err != nil {
+		return err
+	}
+	e.writer.WriteString(text)
+	return e.endValue()
+}
+
+// fail records the first error
+func (e *JSONEncoder) fail(err error) error {
+	if e.err == nil {
+		e.err = err
+	}
+	return e.err
+}
+
+// formatFloat formats a number like encoding/json: exponent notation only for very small or large values
+func formatFloat(value float64) string {
+	abs := math.Abs(value)
+	format := byte('f')
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	text := strconv.FormatFloat(value, format, -1, 64)
+	if format == 'e' {
+		// Clean up e-09 to e-9
+		n := len(text)
+		if n >= 4 && text[n-4] == 'e' && text[n-3] == '-' && text[n-2] == '0' {
+			text = text[:n-2] + text[n-1:]
+		}
+	}
+	return text
+}
@@ -0,0 +1,163 @@
+package encoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONEncoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		write    func(e *JSONEncoder)
+		expected string
+	}{
+		{
+			name: "compact object",
+			write: func(e *JSONEncoder) {
+				e.BeginObject()
+				e.Key("id")
+				e.Int(1)
+				e.Key("tags")
+				e.BeginArray()
+				e.String("a")
+				e.Bool(true)
+				e.Null()
+				e.EndArray()
+				e.EndObject()
+			},
+			expected: `{"id":1,"tags":["a",true,null]}`,
+		},
+		{
+			name: "indented object",
+			opts: Options{Indent: "  "},
+			write: func(e *JSONEncoder) {
+				e.BeginObject()
+				e.Key("id")
+				e.Float(1.5)
+				e.Key("empty")
+				e.BeginArray()
+				e.EndArray()
+				e.Key("list")
+				e.BeginArray()
+				e.Number("12345678901234567890")
+				e.EndArray()
+				e.EndObject()
+			},
+			expected: "{\n  \"id\": 1.5,\n  \"empty\": [],\n  \"list\": [\n    12345678901234567890\n  ]\n}",
+		},
+		{
+			name: "multiple values",
+			opts: Options{MultipleValues: true},
+			write: func(e *JSONEncoder) {
+				e.BeginObject()
+				e.EndObject()
+				e.String("x")
+			},
+			expected: "{}\n\"x\"\n",
+		},
+		{
+			name: "escaped strings",
+			opts: Options{EscapeHTML: true},
+			write: func(e *JSONEncoder) {
+				e.BeginArray()
+				e.String("quote\" backslash\\ newline\n tab\t bell\x07")
+				e.String("<b>&</b>")
+				e.String("line\u2028separator")
+				e.String("bad \xff utf8")
+				e.EndArray()
+			},
+			expected: `["quote\" backslash\\ newline\n tab\t bell\u0007","\u003cb\u003e\u0026\u003c/b\u003e","line\u2028separator","bad \ufffd utf8"]`,
+		},
+		{
+			name: "floats like encoding/json",
+			write: func(e *JSONEncoder) {
+				e.BeginArray()
+				e.Float(1e21)
+				e.Float(0.000001)
+				e.Float(1e-7)
+				e.Float(-0.5)
+				e.EndArray()
+			},
+			expected: `[1e+21,0.000001,1e-7,-0.5]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			e := NewJSONEncoder(&output, tt.opts)
+			tt.write(e)
+			if err := e.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if output.String() != tt.expected {
+				t.Errorf("output = %q, want %q", output.String(), tt.expected)
+			}
+			if !tt.opts.MultipleValues && !json.Valid(output.Bytes()) {
+				t.Errorf("output %q is not valid JSON", output.String())
+			}
+		})
+	}
+}
+
+func TestJSONEncoderCallOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(e *JSONEncoder) error
+	}{
+		{name: "value without key", write: func(e *JSONEncoder) error {
+			e.BeginObject()
+			return e.String("x")
+		}},
+		{name: "key outside object", write: func(e *JSONEncoder) error {
+			e.BeginArray()
+			return e.Key("x")
+		}},
+		{name: "two keys", write: func(e *JSONEncoder) error {
+			e.BeginObject()
+			e.Key("a")
+			return e.Key("b")
+		}},
+		{name: "mismatched end", write: func(e *JSONEncoder) error {
+			e.BeginObject()
+			return e.EndArray()
+		}},
+		{name: "key without value", write: func(e *JSONEncoder) error {
+			e.BeginObject()
+			e.Key("a")
+			return e.EndObject()
+		}},
+		{name: "second document", write: func(e *JSONEncoder) error {
+			e.Null()
+			return e.Null()
+		}},
+		{name: "unclosed container", write: func(e *JSONEncoder) error {
+			e.BeginArray()
+			return e.Close()
+		}},
+		{name: "empty document", write: func(e *JSONEncoder) error {
+			return e.Close()
+		}},
+		{name: "invalid number", write: func(e *JSONEncoder) error {
+			return e.Number("Inf")
+		}},
+		{name: "unsupported type", write: func(e *JSONEncoder) error {
+			return e.Value(struct{}{})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewJSONEncoder(&bytes.Buffer{}, Options{})
+			if err := tt.write(e); err == nil {
+				t.Fatal("expected an error")
+			}
+			// The error sticks to the encoder
+			if err := e.Null(); err == nil {
+				t.Error("expected the error to be kept")
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package encoder
+
+import (
+	"unicode/utf8"
+)
+
+const hex = "0123456789abcdef"
+
+// writeString writes a quoted and escaped JSON string
+// Invalid UTF-8 is replaced by U+FFFD, and U+2028 and U+2029 are escaped
+// so that the output is also valid JavaScript, like encoding/json does
+func (e *JSONEncoder) writeString(value string) {
+	w := e.writer
+	w.WriteByte('"')
+
+	start := 0 // the start of the run of bytes written as they are
+	for i := 0; i < len(value); {
+		if c := value[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && (!e.opts.EscapeHTML || (c != '<' && c != '>' && c != '&')) {
+				i++
+				continue
+			}
+			w.WriteString(value[start:i])
+			switch c {
+			case '"', '\\':
+				w.WriteByte('\\')
+				w.WriteByte(c)
+			case '\n':
+				w.WriteString(`\n`)
+			case '\r':
+				w.WriteString(`\r`)
+			case '\t':
+				w.WriteString(`\t`)
+			default:
+				// Other control characters and the HTML characters are written as \u00XX
+				w.WriteString(`\u00`)
+				w.WriteByte(hex[c>>4])
+				w.WriteByte(hex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(value[i:])
+		if r == utf8.RuneError && size == 1 {
+			w.WriteString(value[start:i])
+			w.WriteString(`\ufffd`)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			w.WriteString(value[start:i])
+			w.WriteString(`\u202`)
+			w.WriteByte(hex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+
+	w.WriteString(value[start:])
+	w.WriteByte('"')
+}
@@ -0,0 +1,54 @@
+package encoder
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// WriteEvent writes the token of a parser event, so parser events can be piped straight into the encoder:
+//
+//	p.SetEventHandler(enc.WriteEvent)
+//
+// The parser should decode strings (parser.Options.DecodeStrings), otherwise their escape sequences
+// are escaped a second time, and keep numbers as text (parser.NumberText) to write them unchanged
+func (e *JSONEncoder) WriteEvent(event parser.Event) error {
+	switch event.Kind {
+	case parser.ObjectStart:
+		return e.BeginObject()
+	case parser.ObjectEnd:
+		return e.EndObject()
+	case parser.ArrayStart:
+		return e.BeginArray()
+	case parser.ArrayEnd:
+		return e.EndArray()
+	case parser.Key:
+		return e.Key(event.Key)
+	case parser.Value:
+		return e.Value(event.Value)
+	}
+	return e.fail(fmt.Errorf("unknown event kind %v", event.Kind))
+}
+
+// PipeOptions returns the parser options to use when piping the parser events into an encoder:
+// strings are decoded and numbers are kept as text, on top of the given options
+func PipeOptions(opts parser.Options) parser.Options {
+	opts.DecodeStrings = true
+	opts.NumberMode = parser.NumberText
+	return opts
+}
+
+// Transcode parses the JSON document from r and writes it again through the encoder,
+// e.g. to change its indentation, then closes the encoder
+func Transcode(r io.Reader, e *JSONEncoder, opts parser.Options) error {
+	p, err := parser.NewParser(r, PipeOptions(opts), nil)
+	if err != nil {
+		return err
+	}
+	p.SetEventHandler(e.WriteEvent)
+	if err := p.Parse(); err != nil {
+		return err
+	}
+	return e.Close()
+}
@@ -0,0 +1,78 @@
+package encoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+func TestTranscode(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     Options
+		expected string
+	}{
+		{
+			name:     "minify",
+			input:    "{\n  \"a\" : [1, 2.50, {\"b\": null}],\n  \"c\": \"x\\ty\\u00e9\"\n}",
+			expected: `{"a":[1,2.50,{"b":null}],"c":"x\ty` + "\u00e9" + `"}`,
+		},
+		{
+			name:     "indent",
+			input:    `[{"a":true},[]]`,
+			opts:     Options{Indent: "\t"},
+			expected: "[\n\t{\n\t\t\"a\": true\n\t},\n\t[]\n]",
+		},
+		{
+			name:     "big numbers keep their digits",
+			input:    `[12345678901234567890, 1e400]`,
+			expected: `[12345678901234567890,1e400]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			e := NewJSONEncoder(&output, tt.opts)
+			if err := Transcode(strings.NewReader(tt.input), e, parser.Options{}); err != nil {
+				t.Fatalf("Transcode() error = %v", err)
+			}
+			if output.String() != tt.expected {
+				t.Errorf("Transcode() = %q, want %q", output.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestWriteEventRoundTrip(t *testing.T) {
+	input := `{"data":[{"id":1,"name":"J\"ane","tags":["a","b"],"meta":{}},{"id":2,"ok":false,"none":null}]}`
+
+	var output bytes.Buffer
+	e := NewJSONEncoder(&output, Options{})
+	p, err := parser.NewParser(strings.NewReader(input), PipeOptions(parser.Options{ChunkSize: 7}), nil)
+	if err != nil {
+		t.Fatalf("NewParser() error = %v", err)
+	}
+	p.SetEventHandler(e.WriteEvent)
+	if err := p.Parse(); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	var expected, result any
+	json.Unmarshal([]byte(input), &expected)
+	if err := json.Unmarshal(output.Bytes(), &result); err != nil {
+		t.Fatalf("output %q is not valid JSON: %v", output.String(), err)
+	}
+	expectedJSON, _ := json.Marshal(expected)
+	resultJSON, _ := json.Marshal(result)
+	if !bytes.Equal(expectedJSON, resultJSON) {
+		t.Errorf("round trip = %s, want %s", resultJSON, expectedJSON)
+	}
+}
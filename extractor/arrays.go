@@ -0,0 +1,281 @@
+package extractor
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bluesky0724/jsonstream/jsonpath"
+	"github.com/bluesky0724/jsonstream/output"
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// arrayMode is how a column writes the values of its field when there are several of them, e.g. an array
+type arrayMode int
+
+const (
+	explodeArray arrayMode = iota // one row per value, combined with the other exploded columns
+	joinArray                     // the text of the values joined by a separator
+	jsonArray                     // the value of the field as JSON, see fieldJSON
+	firstValue                    // the first value only
+	lastValue                     // the last value only
+	countValues                   // the number of values
+	spreadArray                   // the values in numbered columns, field_1 to field_N
+)
+
+// arrayModes are the names of the modes in the field specs
+var arrayModes = map[string]arrayMode{
+	"explode": explodeArray,
+	"join":    joinArray,
+	"json":    jsonArray,
+	"first":   firstValue,
+	"last":    lastValue,
+	"count":   countValues,
+	"spread":  spreadArray,
+}
+
+// defaultSeparator joins the values of a join column without an argument
+const defaultSeparator = ";"
+
+// columnSpecPattern matches a field spec ending with an array mode, e.g. "keyword|join(;)" or "contacts|count"
+var columnSpecPattern = regexp.MustCompile(`^(.*?)\|([a-z]+)(?:\((.*)\))?$`)
+
+// column is an output column: the field it reads and how it writes the values of the field
+type column struct {
+	field     string
+	mode      arrayMode
+	separator string // the separator of joinArray
+	width     int    // the number of columns of spreadArray
+	name      string // the header of the column when it is not the field, see renamed
+}
+
+// parseColumn parses a field spec, the field optionally followed by '|' and an array mode:
+// explode, join or join(separator), json, first, last, count or spread(N)
+// A suffix that is not a mode is part of the field, e.g. the key "a|b" of the pointer "/a|b"
+func parseColumn(spec string) (column, error) {
+	match := columnSpecPattern.FindStringSubmatch(spec)
+	if match == nil {
+		return column{field: spec}, nil
+	}
+	field, name, argument := match[1], match[2], match[3]
+	hasArgument := strings.HasSuffix(spec, ")")
+	mode, ok := arrayModes[name]
+	if !ok {
+		return column{field: spec}, nil
+	}
+
+	c := column{field: field, mode: mode}
+	switch mode {
+	case joinArray:
+		c.separator = defaultSeparator
+		if hasArgument {
+			c.separator = argument
+		}
+	case spreadArray:
+		width, err := strconv.Atoi(argument)
+		if err != nil || width <= 0 {
+			return column{}, fmt.Errorf("invalid target field %q: spread needs a positive number of columns, e.g. spread(5)", spec)
+		}
+		c.width = width
+	default:
+		if hasArgument {
+			return column{}, fmt.Errorf("invalid target field %q: %s takes no argument", spec, name)
+		}
+	}
+	return c, nil
+}
+
+// header returns the name of the column, the field unless the column is renamed
+func (c column) header() string {
+	if c.name != "" {
+		return c.name
+	}
+	return c.field
+}
+
+// headers returns the names of the output columns, the column itself or its numbered columns for spread
+func (c column) headers() []string {
+	if c.mode != spreadArray {
+		return []string{c.header()}
+	}
+	headers := make([]string, c.width)
+	for i := range headers {
+		headers[i] = c.header() + "_" + strconv.Itoa(i+1)
+	}
+	return headers
+}
+
+// renamed returns the column named after its field and its mode, e.g. "keyword_count" for "keyword|count",
+// for a column whose field is already written by an earlier column
+func (c column) renamed() column {
+	for name, mode := range arrayModes {
+		if mode == c.mode {
+			c.name = c.field + "_" + name
+		}
+	}
+	return c
+}
+
+// appendSlots appends the values of each output column of c: all the values of the field for explode,
+// the values to combine, and a single value or none for the other modes
+// No value is a missing field, written as output.Missing in rows
+// rawStrings tells that the strings are passed as written in the document, their escapes kept
+func (c column) appendSlots(slots [][]any, values []any, rawStrings bool) [][]any {
+	switch c.mode {
+	case joinArray:
+		if len(values) == 0 {
+			return append(slots, nil)
+		}
+		texts := make([]string, len(values))
+		for i, value := range values {
+			texts[i] = output.Text(value)
+		}
+		return append(slots, []any{strings.Join(texts, c.separator)})
+	case jsonArray:
+		if len(values) == 0 {
+			return append(slots, nil)
+		}
+		return append(slots, []any{encodeValues(values, rawStrings)})
+	case firstValue:
+		return append(slots, values[:min(len(values), 1)])
+	case lastValue:
+		return append(slots, values[max(len(values)-1, 0):])
+	case countValues:
+		return append(slots, []any{int64(len(values))})
+	case spreadArray:
+		for i := range c.width {
+			if i < len(values) {
+				slots = append(slots, values[i:i+1])
+			} else {
+				slots = append(slots, nil)
+			}
+		}
+		return slots
+	}
+	return append(slots, values)
+}
+
+// encodeArray returns the JSON text of an array of values
+func encodeArray(values []any, rawStrings bool) string {
+	var builder strings.Builder
+	builder.WriteByte('[')
+	for i, value := range values {
+		if i > 0 {
+			builder.WriteByte(',')
+		}
+		appendJSONValue(&builder, value, rawStrings)
+	}
+	builder.WriteByte(']')
+	return builder.String()
+}
+
+// encodeValues returns the JSON text of the values of a json column: the value of its field as captured,
+// or the array of the values when an array of the element holds them or the field is not captured
+func encodeValues(values []any, rawStrings bool) string {
+	if len(values) == 1 {
+		if value, ok := values[0].(fieldJSON); ok && !value.InArray {
+			return value.Text
+		}
+	}
+	return encodeArray(values, rawStrings)
+}
+
+// fieldJSON is a value of the field of a json column, captured as JSON like the objects of the containers
+// InArray tells that an array of the element left out of the field holds it, e.g. each email of "contacts.email"
+// for an array of contacts, so that the values of that array are written as an array
+type fieldJSON struct {
+	Text    string
+	InArray bool
+}
+
+// arrayCapture is a field of the json columns, whose values are captured whole
+type arrayCapture struct {
+	field   string
+	pointer parser.Pointer
+	node    *containerNode // the array or object being captured, nil when there is none
+}
+
+// parseArrays prepares the capture of the fields of the json columns
+// The JSONPath and synthetic fields are not captured, their json columns write the array of their values
+func (e *JSONExtractor) parseArrays() error {
+	e.arrays = nil
+	for _, c := range e.columns {
+		if c.mode != jsonArray || jsonpath.IsPath(c.field) || isSynthetic(c.field) || e.captured(c) {
+			continue
+		}
+		pointer, err := toPointer(strings.TrimLeft(c.field, ancestorPrefix))
+		if err != nil {
+			return fmt.Errorf("invalid target field: %w", err)
+		}
+		if len(pointer) > 0 {
+			e.arrays = append(e.arrays, arrayCapture{field: c.field, pointer: pointer})
+		}
+	}
+	return nil
+}
+
+// captured tells whether the field of a json column is captured
+func (e *JSONExtractor) captured(c column) bool {
+	return c.mode == jsonArray && slices.ContainsFunc(e.arrays, func(capture arrayCapture) bool { return capture.field == c.field })
+}
+
+// arrayPath is the key of the captured values of a field in the values map, next to the values of the field
+func (e *JSONExtractor) arrayPath(field string) string {
+	return getAbsolutePath(e.base, field) + "|json"
+}
+
+// arrayLevel returns the level of the elements a captured field is read from, see fieldLevel
+func (e *JSONExtractor) arrayLevel(capture arrayCapture) int {
+	return e.fieldLevel(slices.Index(e.fields, capture.field))
+}
+
+// columnFieldValues returns the values written by a column: the captured values for a json column
+func (e *JSONExtractor) columnFieldValues(values map[string][]any, c column) []any {
+	if e.captured(c) {
+		return values[e.arrayPath(c.field)]
+	}
+	return values[getAbsolutePath(e.base, c.field)]
+}
+
+// captureArrays adds a primitive value, or the end of an object or array, to the values of the json columns it is in
+// relative is its path inside the element of the level of the nested base, 0 in the other modes
+// A value of the field is captured once it ends, as a primitive value or the whole array or object
+func (e *JSONExtractor) captureArrays(level int, relative []parser.PathSegment, value any) {
+	ending := e.parser.Ending()
+	for i := range e.arrays {
+		capture := &e.arrays[i]
+		if e.arrayLevel(*capture) != level {
+			continue
+		}
+		root := 1
+		for root <= len(relative) && !capture.pointer.Match(relative[:root]) {
+			root++
+		}
+		if root > len(relative) {
+			continue
+		}
+		if root < len(relative) {
+			if capture.node == nil {
+				kind := parser.ObjectEnd
+				if relative[root].Index >= 0 {
+					kind = parser.ArrayEnd
+				}
+				capture.node = &containerNode{kind: kind}
+			}
+			capture.node.insert(relative[root:], ending, value)
+			continue
+		}
+		node := capture.node
+		if node == nil {
+			node = &containerNode{kind: ending, value: value} // a primitive value, or an empty array or object
+		}
+		capture.node = nil
+		var text strings.Builder
+		node.appendJSON(&text, !e.parser.Options().DecodeStrings)
+		// The pointer has a token for each segment of the path, but for the arrays it leaves out
+		captured := fieldJSON{Text: text.String(), InArray: root > len(capture.pointer)}
+		e.values[e.arrayPath(capture.field)] = append(e.values[e.arrayPath(capture.field)], captured)
+	}
+}
@@ -0,0 +1,92 @@
+package extractor
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseColumn(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected column
+		headers  []string
+	}{
+		{"keyword", column{field: "keyword"}, []string{"keyword"}},
+		{"keyword|explode", column{field: "keyword"}, []string{"keyword"}},
+		{"keyword|join", column{field: "keyword", mode: joinArray, separator: ";"}, []string{"keyword"}},
+		{"keyword|join(, )", column{field: "keyword", mode: joinArray, separator: ", "}, []string{"keyword"}},
+		{"keyword|join(|)", column{field: "keyword", mode: joinArray, separator: "|"}, []string{"keyword"}},
+		{"keyword|join()", column{field: "keyword", mode: joinArray}, []string{"keyword"}},
+		{"/tags|json", column{field: "/tags", mode: jsonArray}, []string{"/tags"}},
+		{"contact.email|first", column{field: "contact.email", mode: firstValue}, []string{"contact.email"}},
+		{"contact.email|last", column{field: "contact.email", mode: lastValue}, []string{"contact.email"}},
+		{"contacts|count", column{field: "contacts", mode: countValues}, []string{"contacts"}},
+		{"keyword|spread(3)", column{field: "keyword", mode: spreadArray, width: 3}, []string{"keyword_1", "keyword_2", "keyword_3"}},
+		{"/a|b", column{field: "/a|b"}, []string{"/a|b"}},
+		{"$.a[?(@.b=='x|y')]", column{field: "$.a[?(@.b=='x|y')]"}, []string{"$.a[?(@.b=='x|y')]"}},
+	}
+
+	for _, tt := range tests {
+		result, err := parseColumn(tt.spec)
+		if err != nil {
+			t.Errorf("parseColumn(%q) error = %v", tt.spec, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("parseColumn(%q) = %+v, want %+v", tt.spec, result, tt.expected)
+		}
+		if headers := result.headers(); !reflect.DeepEqual(headers, tt.headers) {
+			t.Errorf("headers() of %q = %q, want %q", tt.spec, headers, tt.headers)
+		}
+	}
+
+	for _, spec := range []string{"keyword|spread", "keyword|spread(0)", "keyword|spread(x)", "keyword|count(2)"} {
+		if _, err := parseColumn(spec); err == nil {
+			t.Errorf("parseColumn(%q) expected an error", spec)
+		}
+	}
+}
+
+func TestAppendSlots(t *testing.T) {
+	values := []any{"a", json.Number("2"), true}
+
+	tests := []struct {
+		spec     string
+		values   []any
+		expected [][]any
+	}{
+		{"f", values, [][]any{values}},
+		{"f|join", values, [][]any{{"a;2;true"}}},
+		{"f|join", nil, [][]any{nil}},
+		{"f|json", values, [][]any{{`["a",2,true]`}}},
+		{"f|json", nil, [][]any{nil}},
+		{"f|first", values, [][]any{{"a"}}},
+		{"f|last", values, [][]any{{true}}},
+		{"f|last", nil, [][]any{nil}},
+		{"f|count", values, [][]any{{int64(3)}}},
+		{"f|spread(2)", values, [][]any{{"a"}, {json.Number("2")}}},
+		{"f|spread(4)", values, [][]any{{"a"}, {json.Number("2")}, {true}, nil}},
+	}
+
+	for _, tt := range tests {
+		c, err := parseColumn(tt.spec)
+		if err != nil {
+			t.Fatalf("parseColumn(%q) error = %v", tt.spec, err)
+		}
+		if result := c.appendSlots(nil, tt.values, false); !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("appendSlots(%q, %v) = %v, want %v", tt.spec, tt.values, result, tt.expected)
+		}
+	}
+}
+
+func TestEncodeArray(t *testing.T) {
+	values := []any{`say "hi"`, 1.5, nil}
+	if result := encodeArray(values, false); result != `["say \"hi\"",1.5,null]` {
+		t.Errorf("encodeArray() = %s", result)
+	}
+	raw := []any{`say \"hi\" é`}
+	if result := encodeArray(raw, true); result != `["say \"hi\" é"]` {
+		t.Errorf("encodeArray() of raw strings = %s", result)
+	}
+}
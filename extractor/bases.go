@@ -0,0 +1,86 @@
+package extractor
+
+import (
+	"strings"
+
+	"github.com/bluesky0724/jsonstream/jsonpath"
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// The synthetic fields are not read from the elements but from where they are: they can be extracted,
+// filtered and deduplicated like any other field
+const (
+	// keyField is the key of the element in its object, e.g. the member keys with a base like ".items.*",
+	// missing for the elements of an array
+	keyField = "#key"
+	// valueField is the element itself when it is a primitive value, e.g. with a base array of strings,
+	// missing for objects and arrays
+	valueField = "#value"
+)
+
+// membersWildcard ends a dotted or pointer base selecting the members of the objects at the path before it,
+// e.g. ".items.*" or "/items/*" for {"items": {"id1": {...}, "id2": {...}}}
+const membersWildcard = "*"
+
+// isSynthetic tells whether a field is a synthetic field, which no path matches
+func isSynthetic(field string) bool {
+	return field == keyField || field == valueField
+}
+
+// membersBase returns the path of the objects whose members are the base elements,
+// and false when the base does not end with the members wildcard
+func membersBase(base string) (string, bool) {
+	if jsonpath.IsPath(base) || isNestedBase(base) {
+		return base, false
+	}
+	if base == membersWildcard {
+		return "", true
+	}
+	for _, separator := range []string{".", "/"} {
+		if path, ok := strings.CutSuffix(base, separator+membersWildcard); ok {
+			return path, true
+		}
+	}
+	return base, false
+}
+
+// elementPath matches the elements of a base in the pointer mode: the objects and primitive values
+// where the pointer matches, or with members any value of a member of the objects there
+type elementPath struct {
+	pointer parser.Pointer
+	members bool
+}
+
+// Match checks if the path leads to an element, or to a base array where the pointer matches
+func (p elementPath) Match(path []parser.PathSegment) bool {
+	if !p.members {
+		return p.pointer.Match(path)
+	}
+	last := len(path) - 1
+	return last >= 0 && path[last].Index < 0 && p.pointer.Match(path[:last])
+}
+
+// at checks if the value of the kind starting or ending at the path is an element
+// The arrays where the pointer matches are the base arrays, not elements
+func (p elementPath) at(kind parser.EventKind, path []parser.PathSegment) bool {
+	if !p.members && (kind == parser.ArrayStart || kind == parser.ArrayEnd) {
+		return false
+	}
+	return p.Match(path)
+}
+
+// collectSynthetic collects the synthetic fields of the element ending at the path with the value
+func (e *JSONExtractor) collectSynthetic(path []parser.PathSegment, value any) {
+	for _, field := range e.fields {
+		switch field {
+		case keyField:
+			if last := len(path) - 1; last >= 0 && path[last].Index < 0 {
+				e.collect(getAbsolutePath(e.base, field), path[last].Key, nil)
+			}
+		case valueField:
+			if e.parser.Ending() == parser.Value {
+				e.collect(getAbsolutePath(e.base, field), value, nil)
+			}
+		}
+	}
+}
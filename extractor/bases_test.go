@@ -0,0 +1,130 @@
+package extractor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestJSONExtractorBases(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		bases    []string
+		fields   []string
+		filter   string
+		expected string
+	}{
+		{
+			name:     "root array",
+			input:    `[{"id":1},{"id":2}]`,
+			bases:    []string{"", ".", "$[*]"},
+			fields:   []string{"id"},
+			expected: "id\n1\n2\n",
+		},
+		{
+			name:     "array of scalars",
+			input:    `{"k":["x",2,{"a":"y"},true]}`,
+			bases:    []string{".k", "/k", "$.k[*]"},
+			fields:   []string{"#value", "a", "#key"},
+			expected: "#value,a,#key\nx,,\n2,,\n,y,\ntrue,,\n",
+		},
+		{
+			name:     "root array of scalars",
+			input:    `["a","b"]`,
+			bases:    []string{"", "$[*]"},
+			fields:   []string{"#value"},
+			expected: "#value\na\nb\n",
+		},
+		{
+			name:     "keyed objects",
+			input:    `{"m":{"id1":{"n":"a"},"id2":{"n":"b","t":["x","y"]},"id3":5}}`,
+			bases:    []string{".m.*", "/m/*", "$.m.*"},
+			fields:   []string{"#key", "n", "t", "#value"},
+			expected: "#key,n,t,#value\nid1,a,,\nid2,b,x,\nid2,b,y,\nid3,,,5\n",
+		},
+		{
+			name:     "root keyed objects",
+			input:    `{"a":{"x":1},"b":{"x":2}}`,
+			bases:    []string{"*", ".*", "/*", "$.*"},
+			fields:   []string{"#key", "x"},
+			filter:   "x > 1",
+			expected: "#key,x\nb,2\n",
+		},
+		{
+			name:     "filter on the key",
+			input:    `{"m":{"id1":{"n":"a"},"id2":{"n":"b"}}}`,
+			bases:    []string{".m.*", "$.m.*"},
+			fields:   []string{"n"},
+			filter:   `#key == "id2"`,
+			expected: "n\nb\n",
+		},
+		{
+			name:     "lone object",
+			input:    `{"o":{"a":1,"b":[2,3]}}`,
+			bases:    []string{".o", "/o", "$.o"},
+			fields:   []string{"#key", "a", "b"},
+			expected: "#key,a,b\no,1,2\no,1,3\n",
+		},
+		{
+			name:     "nested scalars",
+			input:    `{"dataset":[{"identifier":"d1","keyword":["a","b"]},{"keyword":["c"],"identifier":"d2"}]}`,
+			bases:    []string{".dataset[*].keyword[*]", "/dataset[*]/keyword[*]"},
+			fields:   []string{"^identifier", "#value"},
+			expected: "^identifier,#value\nd1,a\nd1,b\nd2,c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, base := range tt.bases {
+			t.Run(tt.name+" "+base, func(t *testing.T) {
+				var output bytes.Buffer
+				writer := csv.NewWriter(&output)
+				extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(tt.input)), writer, base, tt.fields)
+				if err != nil {
+					t.Fatalf("NewJSONExtractor() error = %v", err)
+				}
+				if tt.filter != "" {
+					filter, err := ParseRowFilter(tt.filter)
+					if err != nil {
+						t.Fatalf("ParseRowFilter() error = %v", err)
+					}
+					if err := extractor.SetFilter(filter); err != nil {
+						t.Fatalf("SetFilter() error = %v", err)
+					}
+				}
+				if err := extractor.Extract(); err != nil {
+					t.Fatalf("Extract() error = %v", err)
+				}
+				writer.Flush()
+				if output.String() != tt.expected {
+					t.Errorf("Extract() output = %q, want %q", output.String(), tt.expected)
+				}
+			})
+		}
+	}
+}
+
+func TestMembersBase(t *testing.T) {
+	tests := []struct {
+		base    string
+		path    string
+		members bool
+	}{
+		{".items.*", ".items", true},
+		{"/items/*", "/items", true},
+		{"*", "", true},
+		{".items", ".items", false},
+		{"$.items.*", "$.items.*", false},
+		{".dataset[*].items.*", ".dataset[*].items.*", false},
+	}
+
+	for _, tt := range tests {
+		path, members := membersBase(tt.base)
+		if path != tt.path || members != tt.members {
+			t.Errorf("membersBase(%q) = %q, %v, want %q, %v", tt.base, path, members, tt.path, tt.members)
+		}
+	}
+}
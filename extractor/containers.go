@@ -0,0 +1,535 @@
+package extractor
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/bluesky0724/jsonstream/encoder"
+	"github.com/bluesky0724/jsonstream/jsonpath"
+	"github.com/bluesky0724/jsonstream/output"
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// ContainerMode is how a target whose value is an object is written, see JSONExtractor.SetContainers
+type ContainerMode int
+
+const (
+	// ContainerEmpty leaves the objects out, their cells are empty like the ones of a missing field
+	ContainerEmpty ContainerMode = iota
+	// ContainerJSON writes each object as compact JSON in the cell
+	ContainerJSON
+	// ContainerFlatten writes the members of the objects in sub-columns named after the target and their path,
+	// e.g. "publisher.name" and "publisher.subOrganizationOf.name" for the target "publisher"
+	// The arrays in the objects are written as JSON, and the columns that join, count or spread the values
+	// of a target get the JSON of its objects
+	ContainerFlatten
+)
+
+// ContainerOptions configures the targets whose values are objects, see JSONExtractor.SetContainers
+type ContainerOptions struct {
+	Mode ContainerMode
+	// TempDir is where the elements wait in the flatten mode until the sub-columns are known,
+	// the default temporary directory when empty
+	TempDir string
+}
+
+func init() {
+	// The values of the elements written to the temporary file of the flatten mode
+	gob.Register(json.Number(""))
+	gob.Register(objectValue{})
+	gob.Register(fieldJSON{})
+}
+
+// containerNode is an object or an array captured from the input, or a primitive value in one
+type containerNode struct {
+	kind    parser.EventKind // ObjectEnd for an object, ArrayEnd for an array, Value for a primitive value
+	value   any              // the primitive value
+	keys    []string         // the keys of the members of an object
+	members []*containerNode // the members of an object or the items of an array
+}
+
+// objectValue is an object of a target: its JSON text and, in the flatten mode, its members by their dotted path
+type objectValue struct {
+	Keys   []string
+	Values []any
+	Text   string
+}
+
+// String returns the JSON text of the object, e.g. for output.Text
+func (c objectValue) String() string {
+	return c.Text
+}
+
+// JSONText returns the JSON text of the object, so that the documents of an output.ElementWriter nest it
+func (c objectValue) JSONText() string {
+	return c.Text
+}
+
+// flattenState is the flatten mode of an extraction: the sub-columns found so far and the elements waiting for them
+type flattenState struct {
+	opts      ContainerOptions
+	columns   [][]string // the sub-columns of each column of the extractor, by their path in the objects
+	primitive []bool     // whether each column had other values than objects, so that it keeps its own column
+
+	file       *os.File // the waiting elements, nil before Extract
+	buffer     *bufio.Writer
+	encoder    *gob.Encoder
+	collecting bool             // whether the elements are kept in collected instead, for JSONPath elements
+	collected  *deferredElement // the last element kept while collecting
+}
+
+// deferredElement is an element waiting for the sub-columns in the flatten mode:
+// the values of the targets and, with row options, their positions
+type deferredElement struct {
+	Values    [][]any
+	Indexes   [][]int
+	Ancestors [][]string
+	Arrays    [][]any // the captured values of the fields of the json columns
+}
+
+// SetContainers sets how the targets whose values are objects are written, nil leaves the objects out again
+// Each object of a target is a value of it, so an array of objects gives one value per object,
+// exploded into rows like the other arrays; the JSONPath fields are not captured
+// In the flatten mode the header is only written once the input is parsed, with the sub-columns found in it,
+// so the dedup columns may name sub-columns when SetContainers is called first
+// An output.ElementWriter nests the objects with ContainerJSON; it can not take the flatten mode, whose sub-columns
+// would clash in its documents with the column of a target that is an object in one element and not in another
+// It must be called before Extract
+func (e *JSONExtractor) SetContainers(opts *ContainerOptions) error {
+	e.containers, e.flat = nil, nil
+	e.containerPointers, e.captures = nil, nil
+	if opts != nil && opts.Mode != ContainerEmpty {
+		if opts.Mode != ContainerJSON && opts.Mode != ContainerFlatten {
+			return fmt.Errorf("invalid container mode %d", opts.Mode)
+		}
+		if opts.Mode == ContainerFlatten && e.writeElement != nil {
+			return errors.New("invalid container mode: the documents of the writer nest the objects, use ContainerJSON instead of ContainerFlatten")
+		}
+		e.containers = opts
+		e.containerPointers = make([]parser.Pointer, len(e.targets))
+		for i, target := range e.targets {
+			if jsonpath.IsPath(target) || isSynthetic(target) {
+				continue
+			}
+			pointer, err := toPointer(strings.TrimLeft(target, ancestorPrefix))
+			if err != nil {
+				return fmt.Errorf("invalid target field: %w", err)
+			}
+			if len(pointer) > 0 {
+				e.containerPointers[i] = pointer
+			}
+		}
+		e.captures = make([]*containerNode, len(e.targets))
+		if opts.Mode == ContainerFlatten {
+			e.flat = &flattenState{
+				opts:      *opts,
+				columns:   make([][]string, len(e.columns)),
+				primitive: make([]bool, len(e.columns)),
+			}
+		}
+	}
+	return e.SetRows(e.rows)
+}
+
+// capturing tells whether values are captured whole, the objects of the containers or the fields of the json columns
+func (e *JSONExtractor) capturing() bool {
+	return e.containers != nil || len(e.arrays) > 0
+}
+
+// capture adds a primitive value, or the end of an object or array, to the values captured whole, see capturing
+func (e *JSONExtractor) capture(level int, relative []parser.PathSegment, value any) {
+	if e.containers != nil {
+		e.captureContainers(level, relative, value)
+	}
+	e.captureArrays(level, relative, value)
+}
+
+// captureContainers adds a primitive value, or the end of an object or array, to the objects of the targets it is in
+// relative is its path inside the element of the level of the nested base, 0 in the other modes;
+// the object is a value of its target once it ends
+func (e *JSONExtractor) captureContainers(level int, relative []parser.PathSegment, value any) {
+	ending := e.parser.Ending()
+	for i, target := range e.containerPointers {
+		if target == nil || e.fieldLevel(i) != level {
+			continue
+		}
+		root := containerRoot(target, relative)
+		if root < 0 {
+			continue
+		}
+		if root < len(relative) {
+			if e.captures[i] == nil {
+				e.captures[i] = &containerNode{kind: parser.ObjectEnd}
+			}
+			e.captures[i].insert(relative[root:], ending, value)
+			continue
+		}
+		if ending != parser.ObjectEnd {
+			continue // a primitive value or an array of the target itself
+		}
+		node := e.captures[i]
+		if node == nil {
+			node = &containerNode{kind: parser.ObjectEnd} // an empty object
+		}
+		e.captures[i] = nil
+		e.collect(getAbsolutePath(e.base, e.targets[i]), e.containerValue(node), relative)
+	}
+}
+
+// containerRoot returns the length of the path of the object of the target holding the value at relative,
+// len(relative) for a value of the target itself and -1 for a value outside of the target
+// The arrays right below the target are transparent, like for the primitive values
+func containerRoot(target parser.Pointer, relative []parser.PathSegment) int {
+	for root := 1; root <= len(relative); root++ {
+		if target.Match(relative[:root]) {
+			for root < len(relative) && relative[root].Index >= 0 {
+				root++
+			}
+			return root
+		}
+	}
+	return -1
+}
+
+// containerValue returns the value of a captured object in the container mode
+func (e *JSONExtractor) containerValue(node *containerNode) any {
+	rawStrings := !e.parser.Options().DecodeStrings
+	var text strings.Builder
+	node.appendJSON(&text, rawStrings)
+	container := objectValue{Text: text.String()}
+	if e.flat != nil {
+		node.flatten("", &container, rawStrings)
+	}
+	return container
+}
+
+// insert adds a primitive value, or an empty object or array for the kinds ObjectEnd and ArrayEnd, at the path below the node
+// The containers on the way are created with the kind given by the next segment, an index for an array
+func (n *containerNode) insert(path []parser.PathSegment, kind parser.EventKind, value any) {
+	node := n
+	for i, segment := range path {
+		childKind := kind
+		if i < len(path)-1 {
+			childKind = parser.ObjectEnd
+			if path[i+1].Index >= 0 {
+				childKind = parser.ArrayEnd
+			}
+		}
+		node = node.child(segment, childKind)
+	}
+	if kind == parser.Value {
+		node.value = value
+	}
+}
+
+// child returns the member or item at the segment, created with the kind when it is new
+func (n *containerNode) child(segment parser.PathSegment, kind parser.EventKind) *containerNode {
+	if segment.Index >= 0 {
+		if segment.Index < len(n.members) {
+			return n.members[segment.Index]
+		}
+	} else if i := slices.Index(n.keys, segment.Key); i >= 0 {
+		return n.members[i]
+	} else {
+		n.keys = append(n.keys, segment.Key)
+	}
+	child := &containerNode{kind: kind}
+	n.members = append(n.members, child)
+	return child
+}
+
+// appendJSON writes the compact JSON text of the node
+func (n *containerNode) appendJSON(b *strings.Builder, rawStrings bool) {
+	switch n.kind {
+	case parser.ObjectEnd:
+		b.WriteByte('{')
+		for i, key := range n.keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			appendJSONValue(b, key, rawStrings)
+			b.WriteByte(':')
+			n.members[i].appendJSON(b, rawStrings)
+		}
+		b.WriteByte('}')
+	case parser.ArrayEnd:
+		b.WriteByte('[')
+		for i, member := range n.members {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			member.appendJSON(b, rawStrings)
+		}
+		b.WriteByte(']')
+	default:
+		appendJSONValue(b, n.value, rawStrings)
+	}
+}
+
+// flatten adds the members of an object by their dotted path, the nested objects flattened too and the arrays as JSON
+func (n *containerNode) flatten(prefix string, c *objectValue, rawStrings bool) {
+	for i, key := range n.keys {
+		member := n.members[i]
+		name := prefix + key
+		if member.kind == parser.ObjectEnd && len(member.keys) > 0 {
+			member.flatten(name+".", c, rawStrings)
+			continue
+		}
+		c.Keys = append(c.Keys, name)
+		if member.kind == parser.Value {
+			c.Values = append(c.Values, member.value)
+			continue
+		}
+		var text strings.Builder
+		member.appendJSON(&text, rawStrings)
+		c.Values = append(c.Values, text.String())
+	}
+}
+
+// flattens tells whether the objects of the column are flattened into sub-columns,
+// the other columns write their JSON
+func (e *JSONExtractor) flattens(c column) bool {
+	return e.flat != nil && (c.mode == explodeArray || c.mode == firstValue || c.mode == lastValue)
+}
+
+// columnHeaders returns the names of the output columns of a column: the column itself, unless it only had objects,
+// and its sub-columns in the flatten mode
+func (e *JSONExtractor) columnHeaders(i int) []string {
+	c := e.columns[i]
+	if !e.flattens(c) || len(e.flat.columns[i]) == 0 {
+		return c.headers()
+	}
+	var headers []string
+	if e.flat.primitive[i] {
+		headers = append(headers, c.header())
+	}
+	for _, key := range e.flat.columns[i] {
+		headers = append(headers, c.header()+"."+key)
+	}
+	return headers
+}
+
+// cells returns the values of the output columns of a column for one of its values, see columnHeaders
+// An object stays an output.JSONValue, which the writers of JSON nest and the others write as its text
+func (e *JSONExtractor) cells(i int, value any) []any {
+	if !e.flattens(e.columns[i]) || len(e.flat.columns[i]) == 0 {
+		return []any{value}
+	}
+	keys := e.flat.columns[i]
+	cells := make([]any, 0, len(keys)+1)
+	if e.flat.primitive[i] {
+		if _, ok := value.(objectValue); ok {
+			cells = append(cells, output.Missing)
+		} else {
+			cells = append(cells, value)
+		}
+	}
+	container, _ := value.(objectValue)
+	for _, key := range keys {
+		if j := slices.Index(container.Keys, key); j >= 0 {
+			cells = append(cells, container.Values[j])
+		} else {
+			cells = append(cells, output.Missing)
+		}
+	}
+	return cells
+}
+
+// elementCells returns the values of the output columns of a column for an output.ElementWriter:
+// every value of the column, the objects as they are, and every value of each sub-column in the flatten mode
+func (e *JSONExtractor) elementCells(i int, values []any) [][]any {
+	width := len(e.columnHeaders(i))
+	if width == 1 && (!e.flattens(e.columns[i]) || len(e.flat.columns[i]) == 0) {
+		return [][]any{values}
+	}
+	cells := make([][]any, width)
+	for _, value := range values {
+		for j, cell := range e.cells(i, value) {
+			if cell != output.Missing {
+				cells[j] = append(cells[j], cell)
+			}
+		}
+	}
+	return cells
+}
+
+// containerText returns the JSON text of an object, the other values as they are
+func containerText(value any) any {
+	if container, ok := value.(objectValue); ok {
+		return container.Text
+	}
+	return value
+}
+
+// columnValues returns the values given to the array mode of a column: the json mode embeds the objects
+// in its array, the other modes write their JSON text
+func columnValues(c column, values []any) []any {
+	if c.mode == jsonArray {
+		return values
+	}
+	return containerTexts(values)
+}
+
+// containerTexts replaces the objects by their JSON text
+func containerTexts(values []any) []any {
+	if !slices.ContainsFunc(values, func(value any) bool { _, ok := value.(objectValue); return ok }) {
+		return values
+	}
+	texts := make([]any, len(values))
+	for i, value := range values {
+		texts[i] = containerText(value)
+	}
+	return texts
+}
+
+// deferElement keeps the element until the sub-columns are known, and adds the sub-columns of its objects
+func (e *JSONExtractor) deferElement(values map[string][]any) error {
+	element := &deferredElement{}
+	for _, target := range e.targets {
+		absolutePath := getAbsolutePath(e.base, target)
+		element.Values = append(element.Values, values[absolutePath])
+		if e.rows != nil {
+			var indexes []int
+			var ancestors []string
+			for _, position := range e.positions[absolutePath] {
+				indexes = append(indexes, position.index)
+				ancestors = append(ancestors, position.ancestor)
+			}
+			element.Indexes = append(element.Indexes, indexes)
+			element.Ancestors = append(element.Ancestors, ancestors)
+		}
+	}
+	for _, capture := range e.arrays {
+		element.Arrays = append(element.Arrays, values[e.arrayPath(capture.field)])
+	}
+	if e.flat.collecting {
+		e.flat.collected = element
+		return nil
+	}
+	return e.spill(element)
+}
+
+// spill adds the sub-columns of an element and writes it to the temporary file
+func (e *JSONExtractor) spill(element *deferredElement) error {
+	flat := e.flat
+	for i, c := range e.columns {
+		if !e.flattens(c) {
+			continue
+		}
+		for _, value := range element.Values[slices.Index(e.targets, c.field)] {
+			container, ok := value.(objectValue)
+			if !ok {
+				flat.primitive[i] = true
+				continue
+			}
+			for _, key := range container.Keys {
+				if !slices.Contains(flat.columns[i], key) {
+					flat.columns[i] = append(flat.columns[i], key)
+				}
+			}
+		}
+	}
+	if err := flat.encoder.Encode(element); err != nil {
+		return fmt.Errorf("error writing temporary file: %w", err)
+	}
+	return nil
+}
+
+// open creates the temporary file of the waiting elements
+func (f *flattenState) open() error {
+	file, err := os.CreateTemp(f.opts.TempDir, "jsonstream-flatten-*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	f.file = file
+	f.buffer = bufio.NewWriter(file)
+	f.encoder = gob.NewEncoder(f.buffer)
+	return nil
+}
+
+// close removes the temporary file
+func (f *flattenState) close() error {
+	if f.file == nil {
+		return nil
+	}
+	name := f.file.Name()
+	err := f.file.Close()
+	f.file = nil
+	return errors.Join(err, os.Remove(name))
+}
+
+// writeDeferred writes the header with the sub-columns found in the data, then the rows of the waiting elements
+func (e *JSONExtractor) writeDeferred() error {
+	if err := e.SetRows(e.rows); err != nil {
+		return err
+	}
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	flat := e.flat
+	if err := flat.buffer.Flush(); err != nil {
+		return fmt.Errorf("error writing temporary file: %w", err)
+	}
+	if _, err := flat.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error reading temporary file: %w", err)
+	}
+	decoder := gob.NewDecoder(bufio.NewReader(flat.file))
+	values := make(map[string][]any, len(e.targets))
+	for {
+		var element deferredElement
+		if err := decoder.Decode(&element); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error reading temporary file: %w", err)
+		}
+		for i, target := range e.targets {
+			absolutePath := getAbsolutePath(e.base, target)
+			values[absolutePath] = element.Values[i]
+			if e.rows != nil {
+				e.positions[absolutePath] = e.positions[absolutePath][:0]
+				for j, index := range element.Indexes[i] {
+					position := arrayPosition{ancestor: element.Ancestors[i][j], index: index}
+					e.positions[absolutePath] = append(e.positions[absolutePath], position)
+				}
+			}
+		}
+		for i, capture := range e.arrays {
+			values[e.arrayPath(capture.field)] = element.Arrays[i]
+		}
+		if err := e.composeRows(values); err != nil {
+			return err
+		}
+	}
+}
+
+// appendJSONValue writes the JSON text of a primitive value
+// Raw strings are already escaped, so they are only quoted
+func appendJSONValue(b *strings.Builder, value any, rawStrings bool) {
+	switch v := value.(type) {
+	case string:
+		if rawStrings {
+			b.WriteString(`"` + v + `"`)
+			return
+		}
+	case objectValue:
+		b.WriteString(v.Text)
+		return
+	case fieldJSON:
+		b.WriteString(v.Text)
+		return
+	}
+	e := encoder.NewJSONEncoder(b, encoder.Options{})
+	if err := e.Value(value); err != nil {
+		b.WriteString("null") // NaN and infinities have no JSON text
+		return
+	}
+	e.Flush()
+}
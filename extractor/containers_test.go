@@ -0,0 +1,276 @@
+package extractor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bluesky0724/jsonstream/encoder"
+	"github.com/bluesky0724/jsonstream/output"
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+func TestJSONExtractorContainers(t *testing.T) {
+	input := `{"data":[
+		{"id":1,"publisher":{"name":"P","address":{"city":"X"}},"distribution":[{"format":"csv"},{"format":"json","tags":["a","b"]}]},
+		{"id":2,"publisher":"none","distribution":[]},
+		{"id":3,"publisher":{}}
+	]}`
+
+	tests := []struct {
+		name     string
+		bases    []string
+		fields   []string
+		opts     *ContainerOptions
+		rows     *RowOptions
+		expected string
+	}{
+		{
+			name:   "empty",
+			bases:  []string{".data", "/data", "$.data[*]"},
+			fields: []string{"id", "publisher", "distribution"},
+			opts:   &ContainerOptions{Mode: ContainerEmpty},
+			expected: "id,publisher,distribution\n" +
+				"1,,\n2,none,\n3,,\n",
+		},
+		{
+			name:   "json",
+			bases:  []string{".data", "/data", "$.data[*]"},
+			fields: []string{"id", "publisher", "distribution"},
+			opts:   &ContainerOptions{Mode: ContainerJSON},
+			expected: "id,publisher,distribution\n" +
+				`1,"{""name"":""P"",""address"":{""city"":""X""}}","{""format"":""csv""}"` + "\n" +
+				`1,"{""name"":""P"",""address"":{""city"":""X""}}","{""format"":""json"",""tags"":[""a"",""b""]}"` + "\n" +
+				"2,none,\n3,{},\n",
+		},
+		{
+			name:   "flatten",
+			bases:  []string{".data", "/data", "$.data[*]"},
+			fields: []string{"id", "publisher", "distribution"},
+			opts:   &ContainerOptions{Mode: ContainerFlatten},
+			expected: "id,publisher,publisher.name,publisher.address.city,distribution.format,distribution.tags\n" +
+				"1,,P,X,csv,\n" +
+				`1,,P,X,json,"[""a"",""b""]"` + "\n" +
+				"2,none,,,,\n3,,,,,\n",
+		},
+		{
+			name:   "flatten with array modes",
+			bases:  []string{".data", "$.data[*]"},
+			fields: []string{"id", "distribution|last", "distribution|count", "publisher|json"},
+			opts:   &ContainerOptions{Mode: ContainerFlatten},
+			expected: "id,distribution.format,distribution.tags,distribution_count,publisher\n" +
+				`1,json,"[""a"",""b""]",2,"{""name"":""P"",""address"":{""city"":""X""}}"` + "\n" +
+				`2,,,0,"""none"""` + "\n" +
+				"3,,,0,{}\n",
+		},
+		{
+			name:   "flatten with ordinality",
+			bases:  []string{".data", "/data"},
+			fields: []string{"id", "distribution"},
+			opts:   &ContainerOptions{Mode: ContainerFlatten},
+			rows:   &RowOptions{Ordinality: []string{"distribution"}},
+			expected: "id,distribution.format,distribution.tags,distribution_ordinality\n" +
+				"1,csv,,1\n" +
+				`1,json,"[""a"",""b""]",2` + "\n" +
+				"2,,,\n3,,,\n",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, base := range tt.bases {
+			t.Run(tt.name+" "+base, func(t *testing.T) {
+				var output bytes.Buffer
+				writer := csv.NewWriter(&output)
+				extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), writer, base, tt.fields)
+				if err != nil {
+					t.Fatalf("NewJSONExtractor() error = %v", err)
+				}
+				if err := extractor.SetRows(tt.rows); err != nil {
+					t.Fatalf("SetRows() error = %v", err)
+				}
+				if err := extractor.SetContainers(tt.opts); err != nil {
+					t.Fatalf("SetContainers() error = %v", err)
+				}
+				if err := extractor.Extract(); err != nil {
+					t.Fatalf("Extract() error = %v", err)
+				}
+				writer.Flush()
+				if output.String() != tt.expected {
+					t.Errorf("Extract() output = %q, want %q", output.String(), tt.expected)
+				}
+			})
+		}
+	}
+}
+
+func TestJSONExtractorContainersElements(t *testing.T) {
+	input := `{"data":[{"id":1,"p":{"n":"x","a":[1,{"b":true}]},"tags":[{"t":"a"},{"t":"b"}]},{"id":2,"p":"none"}]}`
+
+	// The writers of JSON nest the objects instead of writing their text as strings
+	writers := []struct {
+		name     string
+		create   func(*bytes.Buffer) (output.RowWriter, error)
+		expected string
+	}{
+		{
+			name: "bulk",
+			create: func(b *bytes.Buffer) (output.RowWriter, error) {
+				return output.NewBulkWriter(b, output.BulkOptions{})
+			},
+			expected: `{"index":{}}` + "\n" + `{"id":1,"p":{"n":"x","a":[1,{"b":true}]},"tags":[{"t":"a"},{"t":"b"}]}` + "\n" +
+				`{"index":{}}` + "\n" + `{"id":2,"p":"none"}` + "\n",
+		},
+		{
+			name: "NDJSON",
+			create: func(b *bytes.Buffer) (output.RowWriter, error) {
+				return output.NewNDJSONWriter(b, encoder.Options{}), nil
+			},
+			expected: `{"id":1,"p":{"n":"x","a":[1,{"b":true}]},"tags":{"t":"a"}}` + "\n" +
+				`{"id":1,"p":{"n":"x","a":[1,{"b":true}]},"tags":{"t":"b"}}` + "\n" +
+				`{"id":2,"p":"none"}` + "\n",
+		},
+		{
+			name: "JSON",
+			create: func(b *bytes.Buffer) (output.RowWriter, error) {
+				return output.NewJSONWriter(b, encoder.Options{}), nil
+			},
+			expected: `[{"id":1,"p":{"n":"x","a":[1,{"b":true}]},"tags":{"t":"a"}},` +
+				`{"id":1,"p":{"n":"x","a":[1,{"b":true}]},"tags":{"t":"b"}},` +
+				`{"id":2,"p":"none"}]`,
+		},
+	}
+
+	for _, w := range writers {
+		for _, base := range []string{".data", "/data", "$.data[*]"} {
+			t.Run(w.name+" "+base, func(t *testing.T) {
+				jsonParser, err := parser.NewParser(strings.NewReader(input), parser.Options{DecodeStrings: true}, nil)
+				if err != nil {
+					t.Fatalf("NewParser() error = %v", err)
+				}
+				var buffer bytes.Buffer
+				writer, err := w.create(&buffer)
+				if err != nil {
+					t.Fatalf("create writer error = %v", err)
+				}
+				extractor, err := NewJSONExtractorWithWriter(jsonParser, writer, base, []string{"id", "p", "tags"})
+				if err != nil {
+					t.Fatalf("NewJSONExtractorWithWriter() error = %v", err)
+				}
+				if err := extractor.SetContainers(&ContainerOptions{Mode: ContainerJSON}); err != nil {
+					t.Fatalf("SetContainers() error = %v", err)
+				}
+				if err := extractor.Extract(); err != nil {
+					t.Fatalf("Extract() error = %v", err)
+				}
+				if err := writer.Close(); err != nil {
+					t.Fatalf("Close() error = %v", err)
+				}
+				if buffer.String() != w.expected {
+					t.Errorf("Extract() output = %q, want %q", buffer.String(), w.expected)
+				}
+			})
+		}
+	}
+
+	// A target that is an object in one element and not in another has no place in the documents once flattened,
+	// so the flatten mode is rejected before anything is parsed
+	mixed := `{"data":[{"id":1,"p":"none"},{"id":2,"p":{"n":"x"}}]}`
+	jsonParser, err := parser.NewParser(strings.NewReader(mixed), parser.Options{}, nil)
+	if err != nil {
+		t.Fatalf("NewParser() error = %v", err)
+	}
+	var buffer bytes.Buffer
+	writer, err := output.NewBulkWriter(&buffer, output.BulkOptions{})
+	if err != nil {
+		t.Fatalf("NewBulkWriter() error = %v", err)
+	}
+	extractor, err := NewJSONExtractorWithWriter(jsonParser, writer, ".data", []string{"id", "p"})
+	if err != nil {
+		t.Fatalf("NewJSONExtractorWithWriter() error = %v", err)
+	}
+	if err := extractor.SetContainers(&ContainerOptions{Mode: ContainerFlatten}); err == nil {
+		t.Error("SetContainers() with the flatten mode and a bulk writer error = nil")
+	}
+	// The objects of the mixed data are nested with ContainerJSON
+	if err := extractor.SetContainers(&ContainerOptions{Mode: ContainerJSON}); err != nil {
+		t.Fatalf("SetContainers() error = %v", err)
+	}
+	if err := extractor.Extract(); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	expected := `{"index":{}}` + "\n" + `{"id":1,"p":"none"}` + "\n" + `{"index":{}}` + "\n" + `{"id":2,"p":{"n":"x"}}` + "\n"
+	if buffer.String() != expected {
+		t.Errorf("Extract() output = %q, want %q", buffer.String(), expected)
+	}
+}
+
+func TestJSONExtractorContainersDedup(t *testing.T) {
+	input := `{"data":[{"owner":{"id":1,"name":"a"},"tag":"x"},{"owner":{"id":1,"name":"b"},"tag":"y"},{"owner":{"id":2},"tag":"z"}]}`
+
+	var output bytes.Buffer
+	writer := csv.NewWriter(&output)
+	extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), writer, ".data", []string{"owner", "tag"})
+	if err != nil {
+		t.Fatalf("NewJSONExtractor() error = %v", err)
+	}
+	if err := extractor.SetContainers(&ContainerOptions{Mode: ContainerFlatten, TempDir: t.TempDir()}); err != nil {
+		t.Fatalf("SetContainers() error = %v", err)
+	}
+	// The dedup columns may be sub-columns, which are only known once the input is parsed
+	if err := extractor.SetDedup(&DedupOptions{Columns: []string{"owner.id"}}); err != nil {
+		t.Fatalf("SetDedup() error = %v", err)
+	}
+	if err := extractor.Extract(); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	writer.Flush()
+
+	expected := "owner.id,owner.name,tag\n1,a,x\n2,,z\n"
+	if output.String() != expected {
+		t.Errorf("Extract() output = %q, want %q", output.String(), expected)
+	}
+
+	if err := extractor.SetContainers(&ContainerOptions{Mode: ContainerMode(9)}); err == nil {
+		t.Errorf("SetContainers() expected an error for an unknown mode")
+	}
+}
+
+func TestContainerNode(t *testing.T) {
+	events := []struct {
+		path  []parser.PathSegment
+		kind  parser.EventKind
+		value any
+	}{
+		{[]parser.PathSegment{{Key: "name", Index: -1}}, parser.Value, "P"},
+		{[]parser.PathSegment{{Key: "tags", Index: -1}, {Index: 0}}, parser.Value, "a"},
+		{[]parser.PathSegment{{Key: "tags", Index: -1}, {Index: 1}, {Key: "k", Index: -1}}, parser.Value, 1.5},
+		{[]parser.PathSegment{{Key: "tags", Index: -1}, {Index: 1}}, parser.ObjectEnd, nil},
+		{[]parser.PathSegment{{Key: "tags", Index: -1}}, parser.ArrayEnd, nil},
+		{[]parser.PathSegment{{Key: "empty", Index: -1}}, parser.ObjectEnd, nil},
+		{[]parser.PathSegment{{Key: "sub", Index: -1}, {Key: "ok", Index: -1}}, parser.Value, true},
+		{[]parser.PathSegment{{Key: "sub", Index: -1}}, parser.ObjectEnd, nil},
+	}
+	node := &containerNode{kind: parser.ObjectEnd}
+	for _, event := range events {
+		node.insert(event.path, event.kind, event.value)
+	}
+
+	var text strings.Builder
+	node.appendJSON(&text, true)
+	if expected := `{"name":"P","tags":["a",{"k":1.5}],"empty":{},"sub":{"ok":true}}`; text.String() != expected {
+		t.Errorf("appendJSON() = %s, want %s", text.String(), expected)
+	}
+
+	var container objectValue
+	node.flatten("", &container, true)
+	expected := objectValue{
+		Keys:   []string{"name", "tags", "empty", "sub.ok"},
+		Values: []any{"P", `["a",{"k":1.5}]`, "{}", true},
+	}
+	if !reflect.DeepEqual(container, expected) {
+		t.Errorf("flatten() = %+v, want %+v", container, expected)
+	}
+}
@@ -0,0 +1,304 @@
+package extractor
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bluesky0724/jsonstream/output"
+)
+
+// defaultDedupMemory is the default memory bound of the rows kept to find duplicates: 64mb
+const defaultDedupMemory = 64 << 20
+
+// DedupOptions configures the removal of duplicate rows, see JSONExtractor.SetDedup
+type DedupOptions struct {
+	// Columns are the output columns identifying a row, by their names in the header, the whole row when empty
+	// Only the first row of each group of values is written
+	Columns []string
+	// MemoryLimit bounds the approximate bytes of the rows kept in memory, 64mb when zero
+	// Past the bound, the rows seen are kept as 128-bit hashes in a hash table on disk
+	MemoryLimit int
+	// TempDir is where the hash table is created, the default temporary directory when empty
+	TempDir string
+	// Report, if set, is called with the number of dropped rows once the extraction is done
+	Report func(dropped int64)
+}
+
+// rowSet remembers the rows written so far, in memory and then on disk
+type rowSet struct {
+	opts    DedupOptions
+	columns []int               // the positions of the columns in the rows, nil for the whole row
+	memory  map[string]struct{} // the rows seen while below the memory bound
+	size    int                 // the approximate bytes held by memory
+	disk    *diskSet            // the rows seen once the memory bound was crossed
+	dropped int64
+}
+
+// SetDedup drops the rows whose values, or whose values in opts.Columns, were already written; nil writes them all again
+// The rows are compared by the text of their values, see output.Text, so the same values in elements
+// that differ elsewhere are duplicates; a null, a missing value and an empty string are different values
+// It must be called before Extract
+func (e *JSONExtractor) SetDedup(opts *DedupOptions) error {
+	if e.dedup != nil {
+		e.dedup.close()
+		e.dedup = nil
+	}
+	if opts == nil {
+		return nil
+	}
+
+	set := &rowSet{opts: *opts, memory: make(map[string]struct{})}
+	if set.opts.MemoryLimit <= 0 {
+		set.opts.MemoryLimit = defaultDedupMemory
+	}
+	// The sub-columns of the flatten mode are only known once the input is parsed, the columns are located then
+	if e.flat == nil {
+		if err := set.locate(e.header); err != nil {
+			return err
+		}
+	}
+	e.dedup = set
+	return nil
+}
+
+// locate finds the positions of the dedup columns in the header
+func (s *rowSet) locate(header []string) error {
+	s.columns = nil
+	for _, column := range s.opts.Columns {
+		position := slices.Index(header, column)
+		if position < 0 {
+			return fmt.Errorf("invalid dedup column %q: it is not an output column", column)
+		}
+		s.columns = append(s.columns, position)
+	}
+	return nil
+}
+
+// Duplicates returns the number of rows dropped as duplicates so far
+func (e *JSONExtractor) Duplicates() int64 {
+	if e.dedup == nil {
+		return 0
+	}
+	return e.dedup.dropped
+}
+
+// add records a row and tells whether it is new
+func (s *rowSet) add(record []any) (bool, error) {
+	key := s.key(record)
+	if s.disk == nil {
+		if _, ok := s.memory[key]; ok {
+			s.dropped++
+			return false, nil
+		}
+		s.memory[key] = struct{}{}
+		s.size += len(key) + 16 // the string header
+		if s.size > s.opts.MemoryLimit {
+			return true, s.spill()
+		}
+		return true, nil
+	}
+
+	added, err := s.disk.add(sha256.Sum256([]byte(key)))
+	if err != nil {
+		return false, err
+	}
+	if !added {
+		s.dropped++
+	}
+	return added, nil
+}
+
+// key encodes the values identifying a row, each value prefixed by its length so that no two rows share a key
+// The null and missing values have markers of their own, their text being empty like the one of an empty string
+func (s *rowSet) key(record []any) string {
+	var builder strings.Builder
+	write := func(value any) {
+		switch value {
+		case nil:
+			builder.WriteString("n;")
+			return
+		case output.Missing:
+			builder.WriteString("m;")
+			return
+		}
+		text := output.Text(value)
+		builder.WriteString(strconv.Itoa(len(text)))
+		builder.WriteByte(':')
+		builder.WriteString(text)
+	}
+	if s.columns == nil {
+		for _, value := range record {
+			write(value)
+		}
+	} else {
+		for _, position := range s.columns {
+			write(record[position])
+		}
+	}
+	return builder.String()
+}
+
+// spill moves the rows seen from memory to a hash table on disk
+func (s *rowSet) spill() error {
+	disk, err := newDiskSet(s.opts.TempDir, int64(len(s.memory)))
+	if err != nil {
+		return err
+	}
+	for key := range s.memory {
+		if _, err := disk.add(sha256.Sum256([]byte(key))); err != nil {
+			disk.close()
+			return err
+		}
+	}
+	s.disk, s.memory, s.size = disk, nil, 0
+	return nil
+}
+
+// finish reports the dropped rows and removes the hash table
+func (s *rowSet) finish() error {
+	if s.opts.Report != nil {
+		s.opts.Report(s.dropped)
+	}
+	return s.close()
+}
+
+// close removes the hash table, if any
+func (s *rowSet) close() error {
+	if s.disk == nil {
+		return nil
+	}
+	err := s.disk.close()
+	s.disk = nil
+	return err
+}
+
+// slotSize is the size of a slot of the disk hash table: the first 128 bits of a SHA-256, all zeros when empty
+const slotSize = 16
+
+// diskSet is an open addressing hash table of row hashes in a temporary file, doubled when half full
+// On Linux the file is mapped in memory and the page cache holds the slots probed; elsewhere each probe
+// is a read of the file and each insert a write, at least two system calls per new row
+type diskSet struct {
+	dir   string
+	file  *os.File
+	data  []byte // the mapped file, nil when it is not mapped
+	slots int64  // the number of slots, a power of two
+	count int64
+}
+
+// newDiskSet creates a table with room for at least n hashes
+func newDiskSet(dir string, n int64) (*diskSet, error) {
+	slots := int64(1024)
+	for slots < 4*n {
+		slots *= 2
+	}
+	file, err := os.CreateTemp(dir, "jsonstream-dedup-*.bin")
+	if err != nil {
+		return nil, fmt.Errorf("error creating dedup file: %w", err)
+	}
+	if err := file.Truncate(slots * slotSize); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("error creating dedup file: %w", err)
+	}
+	data, err := mapTable(file, slots*slotSize)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return &diskSet{dir: dir, file: file, data: data, slots: slots}, nil
+}
+
+// add inserts a hash and tells whether it was not in the table
+func (d *diskSet) add(sum [sha256.Size]byte) (bool, error) {
+	var digest [slotSize]byte
+	copy(digest[:], sum[:])
+	if digest == [slotSize]byte{} {
+		digest[0] = 1 // the zero digest marks the empty slots
+	}
+
+	added, err := d.insert(digest)
+	if err != nil || !added {
+		return added, err
+	}
+	d.count++
+	if d.count*2 > d.slots {
+		return true, d.grow()
+	}
+	return true, nil
+}
+
+// insert probes the table from the slot of the digest until it finds it or an empty slot
+func (d *diskSet) insert(digest [slotSize]byte) (bool, error) {
+	var slot [slotSize]byte
+	mask := d.slots - 1
+	for position := int64(binary.LittleEndian.Uint64(digest[:8])) & mask; ; position = (position + 1) & mask {
+		offset := position * slotSize
+		if d.data != nil {
+			copy(slot[:], d.data[offset:])
+		} else if _, err := d.file.ReadAt(slot[:], offset); err != nil {
+			return false, fmt.Errorf("error reading dedup file: %w", err)
+		}
+		if slot == digest {
+			return false, nil
+		}
+		if slot != [slotSize]byte{} {
+			continue
+		}
+		if d.data != nil {
+			copy(d.data[offset:], digest[:])
+		} else if _, err := d.file.WriteAt(digest[:], offset); err != nil {
+			return false, fmt.Errorf("error writing dedup file: %w", err)
+		}
+		return true, nil
+	}
+}
+
+// grow moves the hashes to a table twice as large
+func (d *diskSet) grow() error {
+	larger, err := newDiskSet(d.dir, d.slots/2)
+	if err != nil {
+		return err
+	}
+	var reader io.Reader = bufio.NewReader(io.NewSectionReader(d.file, 0, d.slots*slotSize))
+	if d.data != nil {
+		reader = bytes.NewReader(d.data)
+	}
+	var slot [slotSize]byte
+	for range d.slots {
+		if _, err := io.ReadFull(reader, slot[:]); err != nil {
+			larger.close()
+			return fmt.Errorf("error reading dedup file: %w", err)
+		}
+		if slot == [slotSize]byte{} {
+			continue
+		}
+		if _, err := larger.insert(slot); err != nil {
+			larger.close()
+			return err
+		}
+		larger.count++
+	}
+	d.close()
+	*d = *larger
+	return nil
+}
+
+// close removes the temporary file
+func (d *diskSet) close() error {
+	if d.data != nil {
+		unmapTable(d.data)
+		d.data = nil
+	}
+	d.file.Close()
+	return os.Remove(d.file.Name())
+}
@@ -0,0 +1,147 @@
+package extractor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestJSONExtractorDedup(t *testing.T) {
+	input := `{"dataset":[
+		{"id":1,"publisher":"GSA","keyword":["health","health","data"]},
+		{"id":2,"publisher":"GSA","keyword":["health"]},
+		{"id":1,"publisher":"GSA","keyword":["data"]},
+		{"id":3,"publisher":"NASA","keyword":["space"]}
+	]}`
+
+	tests := []struct {
+		name     string
+		base     string
+		fields   []string
+		opts     *DedupOptions
+		expected string
+		dropped  int64
+	}{
+		{"disabled", "/dataset", []string{"/id", "/keyword"}, nil,
+			"/id,/keyword\n1,health\n1,health\n1,data\n2,health\n1,data\n3,space\n", 0},
+		{"whole rows", "/dataset", []string{"/id", "/keyword"}, &DedupOptions{},
+			"/id,/keyword\n1,health\n1,data\n2,health\n3,space\n", 2},
+		{"key columns", "/dataset", []string{"/id", "/publisher"}, &DedupOptions{Columns: []string{"/publisher"}},
+			"/id,/publisher\n1,GSA\n3,NASA\n", 2},
+		{"spilled to disk", "/dataset", []string{"/id", "/keyword"}, &DedupOptions{MemoryLimit: 1},
+			"/id,/keyword\n1,health\n1,data\n2,health\n3,space\n", 2},
+		{"JSONPath", "$.dataset[?(@.id < 3)]", []string{"@.publisher"}, &DedupOptions{},
+			"@.publisher\nGSA\n", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			writer := csv.NewWriter(&output)
+			extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), writer, tt.base, tt.fields)
+			if err != nil {
+				t.Fatalf("NewJSONExtractor() error = %v", err)
+			}
+			reported := int64(-1)
+			if tt.opts != nil {
+				tt.opts.TempDir = t.TempDir()
+				tt.opts.Report = func(dropped int64) { reported = dropped }
+			}
+			if err := extractor.SetDedup(tt.opts); err != nil {
+				t.Fatalf("SetDedup() error = %v", err)
+			}
+			if err := extractor.Extract(); err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			writer.Flush()
+			if output.String() != tt.expected {
+				t.Errorf("Extract() output = %q, want %q", output.String(), tt.expected)
+			}
+			if extractor.Duplicates() != tt.dropped {
+				t.Errorf("Duplicates() = %d, want %d", extractor.Duplicates(), tt.dropped)
+			}
+			if tt.opts != nil {
+				if reported != tt.dropped {
+					t.Errorf("Report() got %d, want %d", reported, tt.dropped)
+				}
+				if files, _ := os.ReadDir(tt.opts.TempDir); len(files) > 0 {
+					t.Errorf("Extract() left %d dedup files", len(files))
+				}
+			}
+		})
+	}
+
+	extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), csv.NewWriter(&bytes.Buffer{}), "/dataset", []string{"/id"})
+	if err != nil {
+		t.Fatalf("NewJSONExtractor() error = %v", err)
+	}
+	if err := extractor.SetDedup(&DedupOptions{Columns: []string{"/title"}}); err == nil {
+		t.Error("SetDedup() with a column that is not a target error = nil")
+	}
+}
+
+func TestDiskSet(t *testing.T) {
+	set, err := newDiskSet(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("newDiskSet() error = %v", err)
+	}
+	defer set.close()
+
+	// Enough hashes to double the table a few times
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 5000; i++ {
+			added, err := set.add(sha256.Sum256([]byte(strconv.Itoa(i))))
+			if err != nil {
+				t.Fatalf("add() error = %v", err)
+			}
+			if added != (round == 0) {
+				t.Fatalf("round %d: add(%d) = %v", round, i, added)
+			}
+		}
+	}
+	if set.count != 5000 || set.slots < 2*set.count {
+		t.Errorf("count = %d in %d slots", set.count, set.slots)
+	}
+	if added, _ := set.add([sha256.Size]byte{}); !added {
+		t.Error("add() of the zero hash = false")
+	}
+	if added, _ := set.add([sha256.Size]byte{}); added {
+		t.Error("add() of the zero hash twice = true")
+	}
+}
+
+func BenchmarkDiskSet(b *testing.B) {
+	set, err := newDiskSet(b.TempDir(), 0)
+	if err != nil {
+		b.Fatalf("newDiskSet() error = %v", err)
+	}
+	defer set.close()
+
+	for i := range b.N {
+		if _, err := set.add(sha256.Sum256([]byte(strconv.Itoa(i)))); err != nil {
+			b.Fatalf("add() error = %v", err)
+		}
+	}
+}
+
+func TestExtractPipelinedDedup(t *testing.T) {
+	input := `{"data":[{"id":1},{"id":2},{"id":1}]}`
+	var output bytes.Buffer
+	writer := csv.NewWriter(&output)
+	var dropped int64
+	err := ExtractPipelined(context.Background(), strings.NewReader(input), writer, ".data", []string{"id"}, PipelineOptions{
+		Dedup: &DedupOptions{Report: func(n int64) { dropped = n }},
+	})
+	if err != nil {
+		t.Fatalf("ExtractPipelined() error = %v", err)
+	}
+	if expected := "id\n1\n2\n"; output.String() != expected || dropped != 1 {
+		t.Errorf("ExtractPipelined() output = %q with %d dropped, want %q with 1", output.String(), dropped, expected)
+	}
+}
@@ -3,21 +3,87 @@ package extractor
 import (
 	"bufio"
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
+	"github.com/bluesky0724/jsonstream/jsonpath"
+	"github.com/bluesky0724/jsonstream/output"
 	"github.com/bluesky0724/jsonstream/parser"
 )
 
 // JSONExtractor represents a structure for extracting JSON data and converting it to CSV format
 type JSONExtractor struct {
 	parser  *parser.JSONParser // JSON parser instance
-	writer  *csv.Writer        // CSV writer instance
+	writer  output.RowWriter   // the output of the rows
 	base    string             // Base field path for target data
 	targets []string           // Target field names to extract
 	values  map[string][]any   // Map to store extracted values
+	// The keys of the base in the dotted mode, e.g. "data" for ".data"
+	baseKeys []string
+
+	// The columns write the values of the targets, each with its array mode, see parseColumn
+	columns []column
+	header  []string // the names of the output columns
+
+	// The row options zip and number the exploded fields and cap the rows of an element, see SetRows
+	rows      *RowOptions
+	positions map[string][]arrayPosition // the positions of the values, only collected when tracksPositions
+	depths    map[string]int             // the number of tokens of the fields, see fieldDepths
+	truncated int64                      // the number of elements whose rows were capped
+
+	// The container options write the objects of the targets, see SetContainers
+	containers        *ContainerOptions
+	containerPointers []parser.Pointer // the targets as pointers, nil for the JSONPath fields
+	captures          []*containerNode // the object of each target being captured, nil when there is none
+	flat              *flattenState    // the flatten mode, nil in the other modes
+
+	// The fields of the json columns are captured whole, see parseArrays
+	arrays []arrayCapture
+
+	// The filter decides which base elements are written, it may read fields that are not extracted
+	filter       *RowFilter
+	filterFields []string // the fields read by the filter that are not targets
+	fields       []string // the fields whose values are collected: the targets, then the filterFields
+
+	// The dedup set drops the rows already written, see SetDedup
+	dedup *rowSet
+
+	// The gate drops the base elements that do not satisfy a schema, see SetSchema
+	gate *schemaGate
+
+	// When the base or a field is a JSON Pointer, all the paths are matched as pointers
+	// against the structured path of the parser instead of the dotted NowField
+	basePointer    parser.Pointer   // the parsed base, nil in the dotted mode
+	targetPointers []parser.Pointer // the parsed fields, in the order of fields
+	// The elements of each level of the base, the base alone outside of the nested mode
+	// A base ending with ".*" or "/*" selects the members of the objects at its path, see membersBase
+	elements []elementPath
+	// When the base has levels, like ".dataset[*].distribution[*]", the base elements are those of the last level
+	// and the fields may be read from their ancestors, see parseNestedBase
+	nested *nestedBase
+
+	// When the base is a JSONPath expression, it selects the elements and the fields are matched
+	// relative to each element, either as JSONPath expressions or as pointers
+	baseMatcher   *jsonpath.Matcher   // the matcher of the base, nil in the other modes
+	fieldMatchers []*jsonpath.Matcher // the matchers of the JSONPath fields, nil for the other fields
+	elementDepth  int                 // the length of the path of the current element, -1 outside of elements
+
+	// writeRow delivers one row to the writer, it is swapped while the rows of a JSONPath element are collected
+	writeRow func(record []any) error
+	// writeElement delivers the values of the targets of one element when the writer is an output.ElementWriter,
+	// which takes the elements instead of the rows, without deduplication; nil for the other writers
+	writeElement func(columns [][]any) error
 }
 
 // NewJSONExtractor creates a new JSONExtractor instance
+// The base and fields are either dotted paths like ".dataset" and "publisher.name",
+// or JSON Pointers like "/dataset" and "/publisher/name", see parser.Pointer
+// A field may end with the mode of its arrays, e.g. "keyword|join(;)": explode (one row per value, the default),
+// join or join(separator), json, first, last, count, or spread(N) for the columns keyword_1 to keyword_N
+// A column of a field written by an earlier column is named after its mode too, e.g. keyword_count
+// The synthetic fields "#key" and "#value" hold the key of each element and the element itself when it is primitive
 func NewJSONExtractor(reader *bufio.Reader, writer *csv.Writer, baseField string, fields []string) (*JSONExtractor, error) {
 	parser, err := parser.NewJSONParser(reader, nil)
 
@@ -25,19 +91,52 @@ func NewJSONExtractor(reader *bufio.Reader, writer *csv.Writer, baseField string
 		return nil, err
 	}
 
-	// Initialize map with the absolute paths of target fields
-	targetValues := make(map[string][]any)
-	for _, field := range fields {
-		absolutePath := getAbsolutePath(baseField, field)
-		targetValues[absolutePath] = []any{}
-	}
+	return NewJSONExtractorWithParser(parser, writer, baseField, fields)
+}
+
+// NewJSONExtractorWithParser creates a new JSONExtractor instance over an existing parser,
+// e.g. one created by parser.NewParser with its own options or by parser.NewBytesParser
+// The parse handler of the parser is replaced by the extractor's one
+func NewJSONExtractorWithParser(parser *parser.JSONParser, writer *csv.Writer, baseField string, fields []string) (*JSONExtractor, error) {
+	return NewJSONExtractorWithWriter(parser, output.NewCSVWriter(writer), baseField, fields)
+}
 
+// NewJSONExtractorWithWriter creates a new JSONExtractor instance over an existing parser writing the rows
+// to any RowWriter, e.g. NDJSON or a text table, see output.NewWriter
+// The writer is neither flushed nor closed by Extract
+func NewJSONExtractorWithWriter(parser *parser.JSONParser, writer output.RowWriter, baseField string, fields []string) (*JSONExtractor, error) {
 	extractor := &JSONExtractor{
-		parser:  parser,
-		writer:  writer,
-		base:    baseField,
-		targets: fields,
-		values:  targetValues,
+		parser: parser,
+		writer: writer,
+		base:   baseField,
+	}
+	// Every output column needs its own header, for the outputs keyed by the headers like NDJSON and SQL
+	taken := func(header string) bool { return slices.Contains(extractor.header, header) }
+	for _, spec := range fields {
+		column, err := parseColumn(spec)
+		if err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(column.headers(), taken) {
+			column = column.renamed()
+		}
+		if header := slices.IndexFunc(column.headers(), taken); header >= 0 {
+			return nil, fmt.Errorf("invalid target field %q: the column %q is already written", spec, column.headers()[header])
+		}
+		extractor.columns = append(extractor.columns, column)
+		extractor.header = append(extractor.header, column.headers()...)
+		// Several columns may read the same field, whose values are collected once
+		if !slices.Contains(extractor.targets, column.field) {
+			extractor.targets = append(extractor.targets, column.field)
+		}
+	}
+	extractor.fields = extractor.targets
+	extractor.writeRow = writer.WriteRow
+	if elementWriter, ok := writer.(output.ElementWriter); ok {
+		extractor.writeElement = elementWriter.WriteElement
+	}
+	if err := extractor.configure(); err != nil {
+		return nil, err
 	}
 
 	// The logic to extract and export the target data is passed to parser as a parseHandler
@@ -46,9 +145,147 @@ func NewJSONExtractor(reader *bufio.Reader, writer *csv.Writer, baseField string
 	return extractor, nil
 }
 
+// configure prepares the matching of the collected fields
+func (e *JSONExtractor) configure() error {
+	if err := e.parseArrays(); err != nil {
+		return err
+	}
+	// Initialize map with the absolute paths of target fields
+	e.values = make(map[string][]any)
+	e.positions = make(map[string][]arrayPosition)
+	e.initValues()
+	if e.base == "." { // the root, like an empty base
+		e.base = ""
+	}
+	e.baseKeys = nil
+	if base := strings.TrimPrefix(e.base, "."); base != "" {
+		e.baseKeys = strings.Split(base, ".")
+	}
+
+	if err := e.parseJSONPaths(); err != nil {
+		return err
+	}
+	if err := e.parseNestedBase(); err != nil {
+		return err
+	}
+	if err := e.parsePointers(); err != nil {
+		return err
+	}
+	e.fieldDepths()
+	return nil
+}
+
+// SetFilter keeps only the base elements matched by the filter, nil writes them all again
+// The fields read by the filter are collected even when they are not extracted
+// It must be called before Extract
+func (e *JSONExtractor) SetFilter(filter *RowFilter) error {
+	e.filter = filter
+	e.filterFields = nil
+	if filter != nil {
+		for _, field := range filter.Fields() {
+			if !slices.Contains(e.targets, field) {
+				e.filterFields = append(e.filterFields, field)
+			}
+		}
+	}
+	e.fields = append(slices.Clip(e.targets), e.filterFields...)
+	return e.configure()
+}
+
+// parseJSONPaths switches to the JSONPath mode when the base is a JSONPath expression
+func (e *JSONExtractor) parseJSONPaths() error {
+	if !jsonpath.IsPath(e.base) {
+		for _, field := range e.fields {
+			if jsonpath.IsPath(field) {
+				return fmt.Errorf("invalid target field %q: JSONPath fields need a JSONPath base", field)
+			}
+		}
+		return nil
+	}
+
+	base, err := jsonpath.Compile(e.base)
+	if err != nil {
+		return fmt.Errorf("invalid base field: %w", err)
+	}
+	e.baseMatcher = jsonpath.NewMatcher(base, e.writeRows)
+	e.elementDepth = -1
+
+	e.fieldMatchers = make([]*jsonpath.Matcher, len(e.fields))
+	e.targetPointers = make([]parser.Pointer, len(e.fields))
+	for i, field := range e.fields {
+		if !jsonpath.IsPath(field) {
+			if e.targetPointers[i], err = toPointer(field); err != nil {
+				return fmt.Errorf("invalid target field: %w", err)
+			}
+			continue
+		}
+		path, err := jsonpath.Compile(field)
+		if err != nil {
+			return fmt.Errorf("invalid target field: %w", err)
+		}
+		absolutePath := getAbsolutePath(e.base, field)
+		e.fieldMatchers[i] = jsonpath.NewMatcher(path, func(payload any) error {
+			// Only primitive values are collected, objects and arrays have no payload
+			if matched, ok := payload.(fieldValue); ok {
+				e.collect(absolutePath, matched.value, e.parser.Path()[e.elementDepth:])
+			}
+			return nil
+		})
+		// The arrays matched by a field are seen through, like in the dotted syntax
+		e.fieldMatchers[i].ExpandArrays = true
+	}
+	return nil
+}
+
+// fieldValue is the payload of a primitive value matched by a JSONPath field
+type fieldValue struct {
+	value any
+}
+
+// parsePointers switches to the pointer mode when the base or a field is a JSON Pointer
+// The dotted paths given next to pointers are split on '.' into reference tokens
+func (e *JSONExtractor) parsePointers() error {
+	base, members := membersBase(e.base)
+	usePointers := parser.IsPointer(base) || e.nested != nil || members
+	for _, field := range e.fields {
+		usePointers = usePointers || parser.IsPointer(field)
+	}
+	if !usePointers {
+		return nil
+	}
+
+	var err error
+	e.elements = nil
+	if e.nested != nil {
+		for _, level := range e.nested.levels {
+			e.elements = append(e.elements, elementPath{pointer: level})
+		}
+		e.basePointer = e.nested.levels[len(e.nested.levels)-1]
+	} else if e.basePointer, err = toPointer(strings.TrimPrefix(base, ".")); err != nil {
+		return fmt.Errorf("invalid base field: %w", err)
+	} else {
+		e.elements = []elementPath{{pointer: e.basePointer, members: members}}
+	}
+	e.targetPointers = make([]parser.Pointer, len(e.fields))
+	for i, field := range e.fields {
+		if e.targetPointers[i], err = toPointer(strings.TrimLeft(field, ancestorPrefix)); err != nil {
+			return fmt.Errorf("invalid target field: %w", err)
+		}
+	}
+	return nil
+}
+
+// toPointer parses a JSON Pointer, or converts a dotted path to one
+func toPointer(path string) (parser.Pointer, error) {
+	if path == "" || parser.IsPointer(path) {
+		return parser.ParsePointer(path)
+	}
+	return parser.Pointer(strings.Split(path, ".")), nil
+}
+
 // composeCSV writes the collected values to CSV and reinitializes the values map
 func (e *JSONExtractor) composeCSV() error {
-	if err := e.writeCSV(e.targets, e.values); err != nil {
+	if err := e.writeCSV(e.values); err != nil {
 		return fmt.Errorf("failed to write CSV: %w", err)
 	}
 	e.initValues()
@@ -72,23 +309,261 @@ func getAbsolutePath(base string, field string) string {
 // so actually we can even define the new JSONProcessor to handle the brand new job
 // just creating and passing parseHandler to JSONParser
 func (e *JSONExtractor) parseHandler(value any) error {
+	if e.gate != nil {
+		if err := e.gateEnd(); err != nil {
+			return err
+		}
+	}
+	if e.baseMatcher != nil {
+		return e.jsonPathHandler(value)
+	}
+	if e.basePointer != nil {
+		return e.pointerHandler(value)
+	}
 	nowField := e.parser.NowField
 
-	if nowField == e.base+"." { // This means the parser is parsing an element in the base array
+	// This means the parser is parsing an element in the base array, an object or a primitive value
+	if nowField == e.base+"." || nowField == e.base && e.parser.Ending() == parser.Value {
+		e.collectSynthetic(e.parser.Path(), value)
 		if err := e.composeCSV(); err != nil {
 			return fmt.Errorf("failed to compose CSV: %w", err)
 		}
-	} else if e.shouldUpdate(nowField) { // This means the parser parsed the target field
+		return nil
+	}
+	if e.capturing() && strings.HasPrefix(nowField, e.base+".") {
+		e.capture(0, e.dottedRelative(e.parser.Path()), value)
+	}
+	// The end of an array field comes with the field's path and a nil value, which is not one of its values:
+	// collecting it would write one more row with an empty cell for every array
+	if e.parser.Ending() != parser.ArrayEnd && e.shouldUpdate(nowField) { // This means the parser parsed the target field
 		e.updateValues(nowField, value)
 	}
 	return nil
 }
 
+// pointerHandler is the parseHandler of the pointer mode
+// Only primitive values are collected, the end of an object or array adds nothing to its field
+func (e *JSONExtractor) pointerHandler(value any) error {
+	path := e.parser.Path()
+
+	ending := e.parser.Ending()
+	if e.nested != nil {
+		if level := e.nested.levelOf(path, ending); level >= 0 { // The parser finished an element of a level of the base
+			if level == len(e.nested.levels)-1 {
+				e.collectSynthetic(path, value)
+			}
+			if err := e.endNested(level); err != nil {
+				return fmt.Errorf("failed to compose CSV: %w", err)
+			}
+			return nil
+		}
+	} else if e.elements[0].at(ending, path) { // The parser finished an element of the base
+		e.collectSynthetic(path, value)
+		if err := e.composeCSV(); err != nil {
+			return fmt.Errorf("failed to compose CSV: %w", err)
+		}
+		return nil
+	}
+	if e.capturing() {
+		for level, base := range e.elements {
+			if split := elementSplit(base, path); split >= 0 {
+				e.capture(level, path[split:], value)
+			}
+		}
+	}
+	if ending == parser.Value {
+		for i, target := range e.targetPointers {
+			if isSynthetic(e.fields[i]) {
+				continue
+			}
+			if split := matchTarget(e.fieldBase(i), target, path); split >= 0 {
+				e.collect(getAbsolutePath(e.base, e.fields[i]), value, path[split:])
+			}
+		}
+	}
+	return nil
+}
+
+// jsonPathHandler is the parseHandler of the JSONPath mode
+// The outermost node matched by the base is the element, the nodes matched inside it are ignored
+func (e *JSONExtractor) jsonPathHandler(value any) error {
+	path := e.parser.Path()
+	ending := e.parser.Ending()
+
+	if e.elementDepth < 0 {
+		if depth := e.baseMatcher.Enter(path); depth >= 0 { // The parser entered an element
+			e.elementDepth = depth
+			e.initValues()
+			for _, matcher := range e.fieldMatchers {
+				if matcher != nil {
+					matcher.Reset()
+				}
+			}
+		}
+	}
+
+	atElementEnd := len(path) == e.elementDepth
+	if e.elementDepth >= 0 {
+		relative := path[e.elementDepth:]
+		if e.capturing() {
+			e.capture(0, relative, value)
+		}
+		for i, matcher := range e.fieldMatchers {
+			if matcher != nil {
+				payload := func() any {
+					if ending != parser.Value {
+						return nil
+					}
+					return fieldValue{value}
+				}
+				if err := matcher.Handle(relative, ending, value, payload); err != nil {
+					return err
+				}
+			} else if ending == parser.Value && !isSynthetic(e.fields[i]) && e.targetPointers[i].Match(relative) {
+				e.collect(getAbsolutePath(e.base, e.fields[i]), value, relative)
+			}
+		}
+	}
+
+	if atElementEnd {
+		e.collectSynthetic(path, value)
+	}
+	// The rows of an element are composed at its end, and written once the filters of the base pass
+	if err := e.baseMatcher.Handle(path, ending, value, e.elementRows); err != nil {
+		return fmt.Errorf("failed to compose CSV: %w", err)
+	}
+	if atElementEnd {
+		e.elementDepth = -1
+	}
+	return nil
+}
+
+// elementOutput is what an element matched by the base writes once the filters of the base pass
+type elementOutput struct {
+	rows      [][]any
+	columns   [][]any          // the values of the targets for an output.ElementWriter
+	truncated bool             // whether the rows were capped, see RowOptions.MaxRows
+	deferred  *deferredElement // the values waiting for the sub-columns in the flatten mode
+}
+
+// elementRows composes the rows of the element ending at the current path
+func (e *JSONExtractor) elementRows() any {
+	if len(e.parser.Path()) != e.elementDepth {
+		return nil // a node matched inside the element
+	}
+
+	// The rows are only deduplicated when they are written, once the filters of the base pass
+	var element elementOutput
+	writeRow, writeElement, dedup := e.writeRow, e.writeElement, e.dedup
+	e.writeRow = func(record []any) error {
+		element.rows = append(element.rows, append([]any(nil), record...))
+		return nil
+	}
+	if writeElement != nil {
+		e.writeElement = func(columns [][]any) error {
+			element.columns = columns
+			return nil
+		}
+	}
+	e.dedup = nil
+	if e.flat != nil {
+		e.flat.collecting = true
+	}
+	// Collecting the rows can not fail, the capped elements are only counted when written
+	truncated := e.truncated
+	_ = e.writeCSV(e.values)
+	element.truncated = e.truncated > truncated
+	e.writeRow, e.writeElement, e.dedup, e.truncated = writeRow, writeElement, dedup, truncated
+	if e.flat != nil {
+		element.deferred = e.flat.collected
+		e.flat.collecting, e.flat.collected = false, nil
+	}
+	return &element
+}
+
+// writeRows writes the rows of an element matched by the base
+func (e *JSONExtractor) writeRows(payload any) error {
+	element, _ := payload.(*elementOutput)
+	if element == nil {
+		return nil
+	}
+	if element.truncated {
+		e.truncated++
+	}
+	if element.deferred != nil {
+		return e.spill(element.deferred)
+	}
+	if element.columns != nil {
+		if err := e.writeElement(element.columns); err != nil {
+			return fmt.Errorf("error writing field values: %w", err)
+		}
+		return nil
+	}
+	for _, row := range element.rows {
+		if err := e.writeRecord(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRecord writes one row, unless it is a duplicate
+func (e *JSONExtractor) writeRecord(record []any) error {
+	if e.dedup != nil {
+		added, err := e.dedup.add(record)
+		if err != nil {
+			return fmt.Errorf("error removing duplicate rows: %w", err)
+		}
+		if !added {
+			return nil
+		}
+	}
+	if err := e.writeRow(record); err != nil {
+		return fmt.Errorf("error writing field values: %w", err)
+	}
+	return nil
+}
+
+// matchTarget checks if the path leads to the target field inside an element of the base
+// and returns where the path of the field starts, -1 when it does not match
+// The element is where the base matches, together with the positions of the arrays right below it,
+// so a field pointer can not select an element by its position in the base array
+func matchTarget(base elementPath, target parser.Pointer, path []parser.PathSegment) int {
+	for split := 0; split <= len(path); split++ {
+		if split < len(path) && path[split].Index >= 0 {
+			continue
+		}
+		if base.Match(path[:split]) && target.Match(path[split:]) {
+			return split
+		}
+	}
+	return -1
+}
+
+// elementSplit returns where the path inside the element starts when the path is inside an element of the base,
+// -1 otherwise, like matchTarget
+func elementSplit(base elementPath, path []parser.PathSegment) int {
+	for split := 0; split < len(path); split++ {
+		if path[split].Index < 0 && base.Match(path[:split]) {
+			return split
+		}
+	}
+	return -1
+}
+
 // initValues reinitializes the values map with empty arrays
 func (e *JSONExtractor) initValues() {
-	for _, field := range e.targets {
+	for _, field := range e.fields {
 		absolutePath := getAbsolutePath(e.base, field)
 		e.values[absolutePath] = []any{}
+		if e.tracksPositions() {
+			e.positions[absolutePath] = nil
+		}
+	}
+	clear(e.captures)
+	for i, capture := range e.arrays {
+		e.values[e.arrayPath(capture.field)] = []any{}
+		e.arrays[i].node = nil
 	}
 }
 
@@ -97,62 +572,144 @@ func (e *JSONExtractor) updateValues(nowField string, value any) {
 	// If we find several values that matches with the target field,
 	// we can be sure of this value is in array format
 	// To handle all this data, we append all values.
-	e.values[nowField] = append(e.values[nowField], value)
+	e.collect(nowField, value, e.dottedRelative(e.parser.Path()))
+}
+
+// dottedRelative returns the path inside the element in the dotted mode: what follows the keys of the base
+// and the positions of the element in the base arrays, the arrays on the way being transparent
+func (e *JSONExtractor) dottedRelative(path []parser.PathSegment) []parser.PathSegment {
+	i, matched := 0, 0
+	for i < len(path) && matched < len(e.baseKeys) {
+		if path[i].Index < 0 {
+			matched++
+		}
+		i++
+	}
+	for i < len(path) && path[i].Index >= 0 {
+		i++
+	}
+	return path[i:]
 }
 
 // shouldUpdate checks if the current field should be updated based on target fields
 func (e *JSONExtractor) shouldUpdate(field string) bool {
 	for _, target := range e.targets {
+		if isSynthetic(target) {
+			continue
+		}
 		// field is the absolute path of current pointer
 		// and target is the relative path of target field
 		if field == getAbsolutePath(e.base, target) {
 			return true
 		}
 	}
+	for _, filterField := range e.filterFields {
+		if !isSynthetic(filterField) && field == getAbsolutePath(e.base, filterField) {
+			return true
+		}
+	}
 	return false
 }
 
-// writeCSV writes the collected values to the CSV file using backtracking
-func (e *JSONExtractor) writeCSV(fields []string, values map[string][]any) error {
-	absolutePaths := make([]string, len(fields))
-	for i, field := range fields {
-		absolutePaths[i] = getAbsolutePath(e.base, field)
+// writeCSV writes the collected values to the CSV file using backtracking, or whole to an output.ElementWriter
+// Nothing is written when the element is invalid or the filter does not match it
+func (e *JSONExtractor) writeCSV(values map[string][]any) error {
+	if e.gate != nil && e.gate.invalid {
+		return nil
 	}
-	return e.backtrack(absolutePaths, values, 0, []string{})
+	return e.writeValues(values)
 }
 
-// backtrack generates all possible combinations of field values for CSV rows
-func (e *JSONExtractor) backtrack(keys []string, obj map[string][]any, index int, current []string) error {
-	if index == len(keys) {
-		if err := e.writer.Write(current); err != nil {
+// writeValues writes the values of a valid element, unless the filter does not match it
+func (e *JSONExtractor) writeValues(values map[string][]any) error {
+	if e.filter != nil && !e.filter.Match(e.fieldValues) {
+		return nil
+	}
+	if e.flat != nil {
+		return e.deferElement(values)
+	}
+	return e.composeRows(values)
+}
+
+// composeRows writes the rows of the values of an element, or the values whole to an output.ElementWriter
+func (e *JSONExtractor) composeRows(values map[string][]any) error {
+	if e.writeElement != nil {
+		rawStrings := !e.parser.Options().DecodeStrings
+		slots := make([][]any, 0, len(e.header))
+		for i, column := range e.columns {
+			fieldValues := e.columnFieldValues(values, column)
+			if e.flattens(column) {
+				slots = append(slots, e.elementCells(i, fieldValues)...)
+			} else if column.mode == explodeArray && e.inArray(column.field) {
+				// A field in an array of the input stays an array, even with a single value
+				slots = append(slots, []any{output.Array(slices.Clone(fieldValues))})
+			} else {
+				// The objects stay objects, an output.JSONValue the writer may nest in its documents
+				slots = column.appendSlots(slots, fieldValues, rawStrings)
+			}
+		}
+		if err := e.writeElement(slots); err != nil {
 			return fmt.Errorf("error writing field values: %w", err)
 		}
 		return nil
 	}
 
-	// if target field value is empty, we use ""
-	if len(obj[keys[index]]) == 0 {
-		obj[keys[index]] = append(obj[keys[index]], "")
+	written := 0
+	err := e.combine(e.dimensions(values), 0, make([]any, len(e.header)), &written)
+	if errors.Is(err, errRowLimit) {
+		e.truncated++
+		return nil
 	}
+	return err
+}
+
+// fieldValues returns the values collected for a field of the current element
+func (e *JSONExtractor) fieldValues(field string) []any {
+	return e.values[getAbsolutePath(e.base, field)]
+}
 
-	for _, value := range obj[keys[index]] {
-		stringValue := fmt.Sprintf("%v", value)
-		current = append(current, stringValue)
-		if err := e.backtrack(keys, obj, index+1, current); err != nil {
+// Extract starts the JSON extraction process and writes the rows, the names of the columns as header
+// In the flatten mode of SetContainers, the rows are only written once the input is parsed, after the header
+func (e *JSONExtractor) Extract() error {
+	if e.flat != nil {
+		if err := e.flat.open(); err != nil {
 			return err
 		}
-		current = current[:len(current)-1]
+		defer e.flat.close()
+	} else if err := e.writeHeader(); err != nil {
+		return err
+	}
+	err := e.parser.Parse() // Start to parse the data
+	if err != nil {
+		err = fmt.Errorf("error parsing data: %w", err)
+	} else if e.flat != nil {
+		err = e.writeDeferred()
+	}
+	if err != nil {
+		if e.dedup != nil {
+			e.dedup.close()
+		}
+		return err
+	}
+	if e.rows != nil && e.rows.Report != nil {
+		e.rows.Report(e.truncated)
+	}
+	if e.dedup != nil {
+		return e.dedup.finish()
 	}
 	return nil
 }
 
-// Extract starts the JSON extraction process and writes data to CSV
-func (e *JSONExtractor) Extract() error {
-	if err := e.writer.Write(e.targets); err != nil {
-		return fmt.Errorf("error writing target fields: %w", err)
+// writeHeader writes the names of the columns, where the dedup columns are located
+func (e *JSONExtractor) writeHeader() error {
+	// The header may have changed since SetDedup, e.g. with the ordinality columns of SetRows
+	if e.dedup != nil {
+		if err := e.dedup.locate(e.header); err != nil {
+			return err
+		}
 	}
-	if err := e.parser.Parse(); err != nil { // Start to parse the data
-		return fmt.Errorf("error parsing data: %w", err)
+	if err := e.writer.WriteHeader(e.header); err != nil {
+		return fmt.Errorf("error writing target fields: %w", err)
 	}
 	return nil
 }
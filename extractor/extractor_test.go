@@ -6,6 +6,10 @@ import (
 	"encoding/csv"
 	"strings"
 	"testing"
+
+	"github.com/bluesky0724/jsonstream/encoder"
+	"github.com/bluesky0724/jsonstream/output"
+	"github.com/bluesky0724/jsonstream/parser"
 )
 
 func TestJSONExtractor(t *testing.T) {
@@ -88,6 +92,206 @@ func TestJSONExtractor(t *testing.T) {
 	}
 }
 
+func TestJSONExtractorArrayEnds(t *testing.T) {
+	// The end of an array field is not a value of the field, whether the array comes first or last
+	input := `{"data":[{"id":1,"tags":["a","b"]},{"id":2,"tags":[]},{"tags":["c"],"id":3}]}`
+
+	var rows bytes.Buffer
+	writer := csv.NewWriter(&rows)
+	extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), writer, ".data", []string{"id", "tags"})
+	if err != nil {
+		t.Fatalf("NewJSONExtractor() error = %v", err)
+	}
+	if err := extractor.Extract(); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	writer.Flush()
+	if expected := "id,tags\n1,a\n1,b\n2,\n3,c\n"; rows.String() != expected {
+		t.Errorf("Extract() rows = %q, want %q", rows.String(), expected)
+	}
+
+	// An output.ElementWriter gets the values of each array whole
+	var documents bytes.Buffer
+	bulk, err := output.NewBulkWriter(&documents, output.BulkOptions{})
+	if err != nil {
+		t.Fatalf("NewBulkWriter() error = %v", err)
+	}
+	jsonParser, err := parser.NewParser(strings.NewReader(input), parser.Options{DecodeStrings: true, NumberMode: parser.NumberInt64}, nil)
+	if err != nil {
+		t.Fatalf("NewParser() error = %v", err)
+	}
+	extractor, err = NewJSONExtractorWithWriter(jsonParser, bulk, ".data", []string{"id", "tags"})
+	if err != nil {
+		t.Fatalf("NewJSONExtractorWithWriter() error = %v", err)
+	}
+	if err := extractor.Extract(); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	expected := `{"index":{}}` + "\n" + `{"id":1,"tags":["a","b"]}` + "\n" +
+		`{"index":{}}` + "\n" + `{"id":2}` + "\n" +
+		`{"index":{}}` + "\n" + `{"id":3,"tags":["c"]}` + "\n"
+	if documents.String() != expected {
+		t.Errorf("Extract() documents = %q, want %q", documents.String(), expected)
+	}
+}
+
+func TestJSONExtractorPointers(t *testing.T) {
+	input := `{"data":[
+		{"id":1,"a.b":"dot","x/y":"slash","":"empty","tags":["t1","t2"],"user":{"name":"John"}},
+		{"id":2,"tags":["t3"],"user":{"name":"Jane"}}
+	]}`
+
+	tests := []struct {
+		name     string
+		base     string
+		fields   []string
+		expected string
+	}{
+		{
+			name:     "pointer base and fields",
+			base:     "/data",
+			fields:   []string{"/id", "/user/name"},
+			expected: "/id,/user/name\n1,John\n2,Jane\n",
+		},
+		{
+			name:     "keys with dot, slash and empty keys",
+			base:     "/data",
+			fields:   []string{"/a.b", "/x~1y", "/"},
+			expected: "/a.b,/x~1y,/\ndot,slash,empty\n,,\n",
+		},
+		{
+			name:     "array positions",
+			base:     "/data",
+			fields:   []string{"/id", "/tags/0"},
+			expected: "/id,/tags/0\n1,t1\n2,t3\n",
+		},
+		{
+			name:     "whole arrays stay transparent",
+			base:     "/data",
+			fields:   []string{"/id", "/tags"},
+			expected: "/id,/tags\n1,t1\n1,t2\n2,t3\n",
+		},
+		{
+			name:     "one element of the base",
+			base:     "/data/1",
+			fields:   []string{"/id"},
+			expected: "/id\n2\n",
+		},
+		{
+			name:     "dotted field with a pointer base",
+			base:     "/data",
+			fields:   []string{"user.name"},
+			expected: "user.name\nJohn\nJane\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			writer := csv.NewWriter(&output)
+			extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), writer, tt.base, tt.fields)
+			if err != nil {
+				t.Fatalf("NewJSONExtractor() error = %v", err)
+			}
+			if err := extractor.Extract(); err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			writer.Flush()
+			if output.String() != tt.expected {
+				t.Errorf("Extract() output = %q, want %q", output.String(), tt.expected)
+			}
+		})
+	}
+
+	if _, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), nil, "/data", []string{"/a~2"}); err == nil {
+		t.Errorf("NewJSONExtractor() expected an error for an invalid pointer")
+	}
+}
+
+func TestJSONExtractorJSONPath(t *testing.T) {
+	input := `{"dataset":[
+		{"id":1,"accessLevel":"public","keyword":["k1","k2"],"distribution":[{"downloadURL":"a","mediaType":"csv"},{"downloadURL":"b"}]},
+		{"id":2,"accessLevel":"private","distribution":[{"downloadURL":"c","mediaType":"csv"}]},
+		{"distribution":[],"accessLevel":"public","id":3}
+	]}`
+
+	tests := []struct {
+		name     string
+		base     string
+		fields   []string
+		expected string
+	}{
+		{
+			name:     "wildcard base with dotted fields",
+			base:     "$.dataset[*]",
+			fields:   []string{"id", "keyword"},
+			expected: "id,keyword\n1,k1\n1,k2\n2,\n3,\n",
+		},
+		{
+			name:     "filtered base",
+			base:     "$.dataset[?(@.accessLevel=='public')]",
+			fields:   []string{"id"},
+			expected: "id\n1\n3\n",
+		},
+		{
+			name:     "sliced base",
+			base:     "$.dataset[1:]",
+			fields:   []string{"/id"},
+			expected: "/id\n2\n3\n",
+		},
+		{
+			name:     "JSONPath fields",
+			base:     "$.dataset[*]",
+			fields:   []string{"@.id", "$.distribution[?(@.mediaType=='csv')].downloadURL"},
+			expected: "@.id,$.distribution[?(@.mediaType=='csv')].downloadURL\n1,a\n2,c\n3,\n",
+		},
+		{
+			name:     "recursive descent field",
+			base:     "$.dataset[0]",
+			fields:   []string{"$..downloadURL"},
+			expected: "$..downloadURL\na\nb\n",
+		},
+		{
+			name:     "recursive descent base",
+			base:     "$..dataset[?(@.id)]",
+			fields:   []string{"id"},
+			expected: "id\n1\n2\n3\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			writer := csv.NewWriter(&output)
+			extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), writer, tt.base, tt.fields)
+			if err != nil {
+				t.Fatalf("NewJSONExtractor() error = %v", err)
+			}
+			if err := extractor.Extract(); err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			writer.Flush()
+			if output.String() != tt.expected {
+				t.Errorf("Extract() output = %q, want %q", output.String(), tt.expected)
+			}
+		})
+	}
+
+	invalid := []struct {
+		base   string
+		fields []string
+	}{
+		{"$.dataset[-1]", []string{"id"}},
+		{"$.dataset[*]", []string{"$.a["}},
+		{".dataset", []string{"$.id"}},
+	}
+	for _, tt := range invalid {
+		if _, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), nil, tt.base, tt.fields); err == nil {
+			t.Errorf("NewJSONExtractor(%q, %q) expected an error", tt.base, tt.fields)
+		}
+	}
+}
+
 func TestGetAbsolutePath(t *testing.T) {
 	tests := []struct {
 		base     string
@@ -130,3 +334,256 @@ func TestShouldUpdate(t *testing.T) {
 		}
 	}
 }
+
+func TestNewJSONExtractorWithParser(t *testing.T) {
+	input := []byte(`{"data":[{"id":1,"user":{"name":"John"}},{"id":2}]}`)
+	jsonParser, err := parser.NewBytesParser(input, parser.Options{ZeroCopy: true}, nil)
+	if err != nil {
+		t.Fatalf("NewBytesParser() error = %v", err)
+	}
+
+	var output bytes.Buffer
+	writer := csv.NewWriter(&output)
+	extractor, err := NewJSONExtractorWithParser(jsonParser, writer, ".data", []string{"id", "user.name"})
+	if err != nil {
+		t.Fatalf("NewJSONExtractorWithParser() error = %v", err)
+	}
+	if err := extractor.Extract(); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	writer.Flush()
+
+	expected := "id,user.name\n1,John\n2,\n"
+	if output.String() != expected {
+		t.Errorf("Extract() output = %q, want %q", output.String(), expected)
+	}
+}
+
+func TestNewJSONExtractorWithWriter(t *testing.T) {
+	input := `{"data":[{"id":1,"name":"Jo\"hn","tags":["a","b"],"active":true},{"id":2.5,"name":null}]}`
+
+	tests := []struct {
+		name     string
+		base     string
+		fields   []string
+		expected string
+	}{
+		{
+			name:   "dotted",
+			base:   ".data",
+			fields: []string{"id", "name", "active"},
+			expected: "{\"id\":1,\"name\":\"Jo\\\"hn\",\"active\":true}\n" +
+				"{\"id\":2.5,\"name\":null}\n",
+		},
+		{
+			name:   "JSONPath",
+			base:   "$.data[?(@.active)]",
+			fields: []string{"id", "/tags"},
+			expected: "{\"id\":1,\"/tags\":\"a\"}\n" +
+				"{\"id\":1,\"/tags\":\"b\"}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonParser, err := parser.NewParser(strings.NewReader(input), parser.Options{DecodeStrings: true, NumberMode: parser.NumberInt64}, nil)
+			if err != nil {
+				t.Fatalf("NewParser() error = %v", err)
+			}
+			var buffer bytes.Buffer
+			writer := output.NewNDJSONWriter(&buffer, encoder.Options{})
+			extractor, err := NewJSONExtractorWithWriter(jsonParser, writer, tt.base, tt.fields)
+			if err != nil {
+				t.Fatalf("NewJSONExtractorWithWriter() error = %v", err)
+			}
+			if err := extractor.Extract(); err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if buffer.String() != tt.expected {
+				t.Errorf("Extract() output = %q, want %q", buffer.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestJSONExtractorNulls(t *testing.T) {
+	input := `{"data":[{"id":1,"name":null,"tags":[]},{"id":2,"name":""},{"id":3},{"id":4,"name":""}]}`
+
+	var buffer bytes.Buffer
+	writer, err := output.NewWriter(&buffer, output.Options{Nulls: output.Nulls{Missing: "-", Null: `\N`}})
+	if err != nil {
+		t.Fatalf("NewWriter() error = %v", err)
+	}
+	jsonParser, err := parser.NewParser(strings.NewReader(input), parser.Options{}, nil)
+	if err != nil {
+		t.Fatalf("NewParser() error = %v", err)
+	}
+	extractor, err := NewJSONExtractorWithWriter(jsonParser, writer, "/data", []string{"id", "name", "tags", "tags|json"})
+	if err != nil {
+		t.Fatalf("NewJSONExtractorWithWriter() error = %v", err)
+	}
+	// The null, the missing name and the empty name are different values
+	if err := extractor.SetDedup(&DedupOptions{Columns: []string{"name"}}); err != nil {
+		t.Fatalf("SetDedup() error = %v", err)
+	}
+	if err := extractor.Extract(); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// A json column is missing without the field, and an empty array otherwise
+	expected := "id,name,tags,tags_json\n1,\\N,-,[]\n2,,-,-\n3,-,-,-\n"
+	if buffer.String() != expected {
+		t.Errorf("Extract() output = %q, want %q", buffer.String(), expected)
+	}
+}
+
+func TestJSONExtractorElements(t *testing.T) {
+	input := `{"data":[{"id":1,"tags":["a","b"],"user":{"name":"John"}},{"id":2,"tags":[]},{"id":3,"tags":["c"]}]}`
+
+	tests := []struct {
+		name     string
+		base     string
+		fields   []string
+		expected string
+	}{
+		{
+			name:   "dotted",
+			base:   ".data",
+			fields: []string{"id", "tags", "user.name"},
+			expected: `{"index":{}}` + "\n" + `{"id":1,"tags":["a","b"],"user":{"name":"John"}}` + "\n" +
+				`{"index":{}}` + "\n" + `{"id":2}` + "\n" +
+				`{"index":{}}` + "\n" + `{"id":3,"tags":["c"]}` + "\n",
+		},
+		{
+			name:   "JSONPath",
+			base:   "$.data[?(@.id>1)]",
+			fields: []string{"id", "/tags"},
+			expected: `{"index":{}}` + "\n" + `{"id":2}` + "\n" +
+				`{"index":{}}` + "\n" + `{"id":3,"tags":["c"]}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonParser, err := parser.NewParser(strings.NewReader(input), parser.Options{DecodeStrings: true, NumberMode: parser.NumberInt64}, nil)
+			if err != nil {
+				t.Fatalf("NewParser() error = %v", err)
+			}
+			var buffer bytes.Buffer
+			writer, err := output.NewBulkWriter(&buffer, output.BulkOptions{})
+			if err != nil {
+				t.Fatalf("NewBulkWriter() error = %v", err)
+			}
+			extractor, err := NewJSONExtractorWithWriter(jsonParser, writer, tt.base, tt.fields)
+			if err != nil {
+				t.Fatalf("NewJSONExtractorWithWriter() error = %v", err)
+			}
+			if err := extractor.Extract(); err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if buffer.String() != tt.expected {
+				t.Errorf("Extract() output = %q, want %q", buffer.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestJSONExtractorArrayModes(t *testing.T) {
+	input := `{"data":[{"id":1,"keyword":["a","b","c"],"contacts":[{"email":"x@a"},{"email":"y@a"}]},{"id":2,"keyword":["d"]}]}`
+
+	tests := []struct {
+		name     string
+		base     string
+		fields   []string
+		expected string
+	}{
+		{
+			name:     "dotted",
+			base:     ".data",
+			fields:   []string{"id", "keyword|join(;)", "contacts.email|count", "contacts.email|first"},
+			expected: "id,keyword,contacts.email,contacts.email_first\n1,a;b;c,2,x@a\n2,d,0,\n",
+		},
+		{
+			name:     "same field",
+			base:     ".data",
+			fields:   []string{"keyword|join(,)", "keyword|count", "keyword|spread(1)", "id"},
+			expected: "keyword,keyword_count,keyword_1,id\n\"a,b,c\",3,a,1\nd,1,d,2\n",
+		},
+		{
+			name:     "pointers",
+			base:     "/data",
+			fields:   []string{"/id", "/keyword|spread(2)", "/contacts/email|last"},
+			expected: "/id,/keyword_1,/keyword_2,/contacts/email\n1,a,b,y@a\n2,d,,\n",
+		},
+		{
+			name:     "JSONPath",
+			base:     "$.data[*]",
+			fields:   []string{"id", "$.keyword|json", "contacts.email"},
+			expected: "id,$.keyword,contacts.email\n1,\"[\"\"a\"\",\"\"b\"\",\"\"c\"\"]\",x@a\n1,\"[\"\"a\"\",\"\"b\"\",\"\"c\"\"]\",y@a\n2,\"[\"\"d\"\"]\",\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			writer := csv.NewWriter(&output)
+			extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), writer, tt.base, tt.fields)
+			if err != nil {
+				t.Fatalf("NewJSONExtractor() error = %v", err)
+			}
+			if err := extractor.Extract(); err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			writer.Flush()
+			if output.String() != tt.expected {
+				t.Errorf("Extract() output = %q, want %q", output.String(), tt.expected)
+			}
+		})
+	}
+
+	if _, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), nil, ".data", []string{"keyword|spread"}); err == nil {
+		t.Errorf("NewJSONExtractor() expected an error for spread without a number of columns")
+	}
+	// The third join of keyword has no name left
+	if _, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), nil, ".data", []string{"keyword|join", "keyword|join(,)", "keyword|join(;)"}); err == nil {
+		t.Errorf("NewJSONExtractor() expected an error for a column name written twice")
+	}
+}
+
+func TestJSONExtractorJSONColumns(t *testing.T) {
+	input := `{"data":[` +
+		`{"id":1,"keyword":[["a","b"],["c"]],"contacts":[{"email":"x@a"},{"email":["y@a","z@a"]}]},` +
+		`{"id":2,"keyword":"d","contacts":{"email":"w@a"}},` +
+		`{"id":3,"keyword":[],"contacts":[]},` +
+		`{"id":4}]}`
+	// The field is written as it is in the input, the values in an array of the element as an array
+	expected := "id,keyword,contacts.email\n" +
+		`1,"[[""a"",""b""],[""c""]]","[""x@a"",[""y@a"",""z@a""]]"` + "\n" +
+		`2,"""d""","""w@a"""` + "\n" +
+		"3,[],\n" +
+		"4,,\n"
+
+	for _, base := range []string{".data", "/data", "$.data[*]"} {
+		t.Run(base, func(t *testing.T) {
+			var output bytes.Buffer
+			writer := csv.NewWriter(&output)
+			extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), writer, base, []string{"id", "keyword|json", "contacts.email|json"})
+			if err != nil {
+				t.Fatalf("NewJSONExtractor() error = %v", err)
+			}
+			if err := extractor.Extract(); err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			writer.Flush()
+			if output.String() != expected {
+				t.Errorf("Extract() output = %q, want %q", output.String(), expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package extractor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// RowFilter is a condition on the values of a base element, only the elements it matches are written
+// The grammar is:
+//
+//	expression := and (("OR" | "||") and)*
+//	and        := not (("AND" | "&&") not)*
+//	not        := ("NOT" | "!") not | "(" expression ")" | field operator literal
+//	operator   := "==" | "=" | "!=" | "<" | "<=" | ">" | ">=" | "contains"
+//	literal    := "string" | 'string' | number | true | false | null
+//
+// Keywords are case-insensitive. A field is written like the extracted fields, relative to the base,
+// e.g. accessLevel, publisher.name or /keyword; fields holding spaces or operators are quoted in backticks
+// A field may be missing or hold several values (an array): a comparison holds when one of the values
+// satisfies it, and "!=" holds when "==" does not, so a missing field is != anything
+// Comparisons are type-aware: numbers compare numerically, strings lexicographically (ISO dates sort
+// as dates), booleans and null only compare for equality, and values of different types never match
+// "contains" holds when a string value contains the literal, or a value equals it
+type RowFilter struct {
+	expression string
+	root       rowCondition
+	fields     []string // the fields the filter reads, in order of first use
+}
+
+// rowCondition is a node of a filter expression, evaluated against the values of the fields
+type rowCondition interface {
+	match(values func(field string) []any) bool
+}
+
+type orCondition struct{ left, right rowCondition }
+type andCondition struct{ left, right rowCondition }
+type notCondition struct{ condition rowCondition }
+type compareCondition struct {
+	field    string
+	operator string
+	literal  any
+}
+
+func (c orCondition) match(values func(string) []any) bool {
+	return c.left.match(values) || c.right.match(values)
+}
+
+func (c andCondition) match(values func(string) []any) bool {
+	return c.left.match(values) && c.right.match(values)
+}
+
+func (c notCondition) match(values func(string) []any) bool {
+	return !c.condition.match(values)
+}
+
+func (c compareCondition) match(values func(string) []any) bool {
+	if c.operator == "!=" {
+		return !compareCondition{field: c.field, operator: "==", literal: c.literal}.match(values)
+	}
+	for _, value := range values(c.field) {
+		if matchValue(value, c.operator, c.literal) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchValue compares one extracted value with a literal
+func matchValue(value any, operator string, literal any) bool {
+	if operator == "contains" {
+		if text, ok := value.(string); ok {
+			if substring, ok := literal.(string); ok {
+				return strings.Contains(text, substring)
+			}
+		}
+		operator = "=="
+	}
+
+	order, ordered, equal := compareLiteral(value, literal)
+	switch operator {
+	case "==":
+		return equal
+	case "<":
+		return ordered && order < 0
+	case "<=":
+		return ordered && order <= 0
+	case ">":
+		return ordered && order > 0
+	case ">=":
+		return ordered && order >= 0
+	}
+	return false
+}
+
+// compareLiteral compares a value with a literal of the same type
+// It returns the order of the value, whether the type is ordered (numbers and strings), and whether they are equal
+func compareLiteral(value any, literal any) (int, bool, bool) {
+	switch literal := literal.(type) {
+	case float64:
+		var number float64
+		switch value := value.(type) {
+		case float64:
+			number = value
+		case int64:
+			number = float64(value)
+		case json.Number:
+			parsed, err := value.Float64()
+			if err != nil {
+				return 0, false, false
+			}
+			number = parsed
+		default:
+			return 0, false, false
+		}
+		switch {
+		case number < literal:
+			return -1, true, false
+		case number > literal:
+			return 1, true, false
+		}
+		return 0, true, true
+	case string:
+		text, ok := value.(string)
+		if !ok {
+			return 0, false, false
+		}
+		order := strings.Compare(text, literal)
+		return order, true, order == 0
+	case bool:
+		boolean, ok := value.(bool)
+		return 0, false, ok && boolean == literal
+	case nil:
+		return 0, false, value == nil
+	}
+	return 0, false, false
+}
+
+// ParseRowFilter parses a filter expression, e.g. `accessLevel == "public" AND modified >= "2023-01-01"`
+func ParseRowFilter(expression string) (*RowFilter, error) {
+	p := &filterParser{input: expression}
+	root, err := p.parseOr()
+	if err == nil && p.skipSpaces() < len(p.input) {
+		err = fmt.Errorf("unexpected %q at position %d", p.input[p.pos:], p.pos)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %w", expression, err)
+	}
+	return &RowFilter{expression: expression, root: root, fields: p.fields}, nil
+}
+
+// String returns the expression the filter was parsed from
+func (f *RowFilter) String() string {
+	return f.expression
+}
+
+// Fields returns the fields the filter reads
+func (f *RowFilter) Fields() []string {
+	return f.fields
+}
+
+// Match checks the filter against the values of the fields of one element
+func (f *RowFilter) Match(values func(field string) []any) bool {
+	return f.root.match(values)
+}
+
+// filterParser reads a filter expression from left to right
+type filterParser struct {
+	input  string
+	pos    int
+	fields []string
+}
+
+func (p *filterParser) parseOr() (rowCondition, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") || p.symbol("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (rowCondition, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") || p.symbol("&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andCondition{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (rowCondition, error) {
+	if p.keyword("NOT") || (!p.peekSymbol("!=") && p.symbol("!")) {
+		condition, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notCondition{condition}, nil
+	}
+	if p.symbol("(") {
+		condition, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.symbol(")") {
+			return nil, fmt.Errorf("expected ')' at position %d", p.pos)
+		}
+		return condition, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses field operator literal
+func (p *filterParser) parseComparison() (rowCondition, error) {
+	field, err := p.parseField()
+	if err != nil {
+		return nil, err
+	}
+
+	var operator string
+	for _, symbol := range []string{"==", "!=", "<=", ">=", "=", "<", ">"} {
+		if p.symbol(symbol) {
+			operator = symbol
+			break
+		}
+	}
+	if operator == "=" {
+		operator = "=="
+	}
+	if operator == "" && p.keyword("contains") {
+		operator = "contains"
+	}
+	if operator == "" {
+		return nil, fmt.Errorf("expected an operator after %q at position %d", field, p.pos)
+	}
+
+	literal, err := p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
+
+	found := false
+	for _, existing := range p.fields {
+		found = found || existing == field
+	}
+	if !found {
+		p.fields = append(p.fields, field)
+	}
+	return compareCondition{field: field, operator: operator, literal: literal}, nil
+}
+
+// parseField parses a field name, bare or in backticks
+func (p *filterParser) parseField() (string, error) {
+	p.skipSpaces()
+	if p.pos < len(p.input) && p.input[p.pos] == '`' {
+		end := strings.IndexByte(p.input[p.pos+1:], '`')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated field at position %d", p.pos)
+		}
+		field := p.input[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return field, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && !unicode.IsSpace(rune(p.input[p.pos])) && !strings.ContainsRune("()=!<>&|\"'", rune(p.input[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", fmt.Errorf("expected a field at position %d", start)
+	}
+	return p.input[start:p.pos], nil
+}
+
+// parseLiteral parses a string, number, boolean or null
+func (p *filterParser) parseLiteral() (any, error) {
+	p.skipSpaces()
+	if p.pos < len(p.input) && (p.input[p.pos] == '"' || p.input[p.pos] == '\'') {
+		return p.parseString()
+	}
+	switch {
+	case p.keyword("true"):
+		return true, nil
+	case p.keyword("false"):
+		return false, nil
+	case p.keyword("null"):
+		return nil, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && strings.ContainsRune("+-.0123456789eE", rune(p.input[p.pos])) {
+		p.pos++
+	}
+	number, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+	if err != nil {
+		return nil, fmt.Errorf("expected a string, number, boolean or null at position %d", start)
+	}
+	return number, nil
+}
+
+// parseString parses a quoted string with backslash escapes
+func (p *filterParser) parseString() (string, error) {
+	quote := p.input[p.pos]
+	var builder strings.Builder
+	for p.pos++; p.pos < len(p.input); p.pos++ {
+		char := p.input[p.pos]
+		if char == quote {
+			p.pos++
+			return builder.String(), nil
+		}
+		if char == '\\' && p.pos+1 < len(p.input) {
+			p.pos++
+			char = p.input[p.pos]
+		}
+		builder.WriteByte(char)
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// keyword consumes a case-insensitive word followed by a non-word character
+func (p *filterParser) keyword(word string) bool {
+	p.skipSpaces()
+	end := p.pos + len(word)
+	if end > len(p.input) || !strings.EqualFold(p.input[p.pos:end], word) {
+		return false
+	}
+	if end < len(p.input) && (unicode.IsLetter(rune(p.input[end])) || unicode.IsDigit(rune(p.input[end])) || p.input[end] == '_') {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+// symbol consumes an operator or parenthesis
+func (p *filterParser) symbol(symbol string) bool {
+	if !p.peekSymbol(symbol) {
+		return false
+	}
+	p.pos += len(symbol)
+	return true
+}
+
+// peekSymbol checks if the input continues with an operator or parenthesis
+func (p *filterParser) peekSymbol(symbol string) bool {
+	p.skipSpaces()
+	return strings.HasPrefix(p.input[p.pos:], symbol)
+}
+
+// skipSpaces moves past whitespace and returns the new position
+func (p *filterParser) skipSpaces() int {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	return p.pos
+}
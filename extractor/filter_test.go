@@ -0,0 +1,146 @@
+package extractor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRowFilter(t *testing.T) {
+	valid := []struct {
+		expression string
+		fields     []string
+	}{
+		{`accessLevel == "public"`, []string{"accessLevel"}},
+		{`accessLevel = 'public' AND modified >= "2023-01-01"`, []string{"accessLevel", "modified"}},
+		{`keyword contains "health" or not (size < 10) && size != null`, []string{"keyword", "size"}},
+		{`!(publisher.name == "GSA") || /a~1b == true`, []string{"publisher.name", "/a~1b"}},
+		{"`$.distribution[?(@.mediaType == 'csv')].downloadURL` != null", []string{"$.distribution[?(@.mediaType == 'csv')].downloadURL"}},
+	}
+	for _, tt := range valid {
+		filter, err := ParseRowFilter(tt.expression)
+		if err != nil {
+			t.Errorf("ParseRowFilter(%q) error = %v", tt.expression, err)
+			continue
+		}
+		if !reflect.DeepEqual(filter.Fields(), tt.fields) {
+			t.Errorf("ParseRowFilter(%q).Fields() = %q, want %q", tt.expression, filter.Fields(), tt.fields)
+		}
+	}
+
+	invalid := []string{
+		``,
+		`accessLevel`,
+		`accessLevel ==`,
+		`accessLevel == public`,
+		`accessLevel == "public`,
+		`(a == 1`,
+		`a == 1 b == 2`,
+		"`a == 1",
+	}
+	for _, expression := range invalid {
+		if _, err := ParseRowFilter(expression); err == nil {
+			t.Errorf("ParseRowFilter(%q) expected an error", expression)
+		}
+	}
+}
+
+func TestRowFilterMatch(t *testing.T) {
+	values := map[string][]any{
+		"accessLevel": {"public"},
+		"modified":    {"2023-05-01"},
+		"keyword":     {"public health", "covid"},
+		"size":        {float64(42)},
+		"count":       {json.Number("7")},
+		"total":       {int64(100)},
+		"draft":       {false},
+		"note":        {nil},
+	}
+	lookup := func(field string) []any { return values[field] }
+
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{`accessLevel == "public" AND modified >= "2023-01-01"`, true},
+		{`accessLevel == "public" AND modified < "2023-01-01"`, false},
+		{`keyword contains "health"`, true},
+		{`keyword contains "flu"`, false},
+		{`keyword == "covid"`, true},
+		{`size > 40 and size <= 42`, true},
+		{`size == "42"`, false},
+		{`count >= 7 AND total == 100`, true},
+		{`draft == false`, true},
+		{`draft == 0`, false},
+		{`note == null`, true},
+		{`accessLevel == null`, false},
+		{`missing == null`, false},
+		{`missing != "x"`, true},
+		{`accessLevel != "public"`, false},
+		{`NOT accessLevel == "public" OR size == 42`, true},
+		{`NOT (accessLevel == "public" OR size == 42)`, false},
+		{`size contains 42`, true},
+	}
+
+	for _, tt := range tests {
+		filter, err := ParseRowFilter(tt.expression)
+		if err != nil {
+			t.Fatalf("ParseRowFilter(%q) error = %v", tt.expression, err)
+		}
+		if result := filter.Match(lookup); result != tt.expected {
+			t.Errorf("%q = %v, want %v", tt.expression, result, tt.expected)
+		}
+	}
+}
+
+func TestJSONExtractorFilter(t *testing.T) {
+	input := `{"dataset":[
+		{"id":1,"accessLevel":"public","modified":"2023-03-01","keyword":["health","data"]},
+		{"id":2,"accessLevel":"private","modified":"2024-01-01","keyword":["health"]},
+		{"id":3,"accessLevel":"public","modified":"2022-12-31","keyword":["health"]},
+		{"id":4,"accessLevel":"public","modified":"2023-06-01","keyword":["finance"]}
+	]}`
+	filter := `accessLevel == "public" AND modified >= "2023-01-01"`
+
+	tests := []struct {
+		name     string
+		base     string
+		fields   []string
+		filter   string
+		expected string
+	}{
+		{"dotted paths", ".dataset", []string{"id"}, filter, "id\n1\n4\n"},
+		{"pointers", "/dataset", []string{"/id"}, filter, "/id\n1\n4\n"},
+		{"JSONPath", "$.dataset[*]", []string{"@.id"}, filter, "@.id\n1\n4\n"},
+		{"extracted field", "/dataset", []string{"/id", "/keyword"}, `/keyword contains "health" AND /id > 1`, "/id,/keyword\n2,health\n3,health\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			writer := csv.NewWriter(&output)
+			extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), writer, tt.base, tt.fields)
+			if err != nil {
+				t.Fatalf("NewJSONExtractor() error = %v", err)
+			}
+			rowFilter, err := ParseRowFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("ParseRowFilter() error = %v", err)
+			}
+			if err := extractor.SetFilter(rowFilter); err != nil {
+				t.Fatalf("SetFilter() error = %v", err)
+			}
+			if err := extractor.Extract(); err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			writer.Flush()
+			if output.String() != tt.expected {
+				t.Errorf("Extract() output = %q, want %q", output.String(), tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package extractor
+
+import (
+	"github.com/bluesky0724/jsonstream/jsonpath"
+	"github.com/bluesky0724/jsonstream/parser"
+	"github.com/bluesky0724/jsonstream/schema"
+)
+
+// schemaGate validates each base element against a schema while it is parsed
+// The starts of the values come from the events of the parser, the ends from the parse handler,
+// so that an element is fully checked by the time its rows are composed
+type schemaGate struct {
+	validator *schema.Validator
+	matcher   *jsonpath.Matcher // finds the elements of a JSONPath base, nil in the other modes
+	depth     int               // the length of the path of the current element, -1 outside of elements
+	invalid   bool              // whether the current element has violations
+}
+
+// SetSchema validates each base element against the schema and only writes the valid ones, nil writes them all again
+// report, which may be nil, is called with every violation, including those of the elements the filter drops
+// Without parser.Options.DecodeStrings, strings are checked as they are written between the quotes
+// The event handler of the parser is replaced by the extractor's one. It must be called before Extract
+func (e *JSONExtractor) SetSchema(elementSchema *schema.Schema, report func(schema.Violation) error) error {
+	if elementSchema == nil {
+		e.gate = nil
+		e.parser.SetEventHandler(nil)
+		return nil
+	}
+
+	gate := &schemaGate{depth: -1}
+	gate.validator = elementSchema.NewValidator(func(violation schema.Violation) error {
+		gate.invalid = true
+		if report == nil {
+			return nil
+		}
+		return report(violation)
+	})
+	if e.baseMatcher != nil {
+		base, err := jsonpath.Compile(e.base)
+		if err != nil {
+			return err
+		}
+		gate.matcher = jsonpath.NewMatcher(base, nil)
+	}
+	e.gate = gate
+	e.parser.SetEventHandler(e.gateEvent)
+	return nil
+}
+
+// gateEvent starts the elements and their values in the validator
+func (e *JSONExtractor) gateEvent(event parser.Event) error {
+	if event.Kind != parser.ObjectStart && event.Kind != parser.ArrayStart && event.Kind != parser.Value {
+		return nil
+	}
+	gate := e.gate
+	path := e.parser.Path()
+	if gate.depth < 0 {
+		if !e.atElement(event.Kind, path) {
+			return nil
+		}
+		gate.depth = len(path)
+		gate.invalid = false
+		gate.validator.Reset()
+	}
+	return gate.validator.Start(path, event.Kind, event.Value, e.parser.Offset())
+}
+
+// atElement checks if the value starting at path is a base element
+func (e *JSONExtractor) atElement(kind parser.EventKind, path []parser.PathSegment) bool {
+	switch {
+	case e.gate.matcher != nil:
+		return e.gate.matcher.Enter(path) == len(path)
+	case e.basePointer != nil:
+		return e.elements[len(e.elements)-1].at(kind, path)
+	case kind == parser.ArrayStart:
+		return false
+	}
+	// The event comes before the parser adds "." for the object, a primitive element has the path of the base
+	return e.parser.NowField == e.base
+}
+
+// gateEnd ends the objects and arrays in the validator, it is called by the parse handler before composing the rows
+func (e *JSONExtractor) gateEnd() error {
+	gate := e.gate
+	if gate.depth < 0 {
+		return nil
+	}
+	path := e.parser.Path()
+	if e.parser.Ending() != parser.Value {
+		if err := gate.validator.End(path); err != nil {
+			return err
+		}
+	}
+	if len(path) == gate.depth {
+		gate.depth = -1
+	}
+	return nil
+}
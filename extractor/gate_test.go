@@ -0,0 +1,86 @@
+package extractor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bluesky0724/jsonstream/schema"
+)
+
+func TestJSONExtractorSchema(t *testing.T) {
+	input := `{"dataset":[
+		{"id":1,"title":"a","keyword":["x"]},
+		{"id":2,"keyword":["x"]},
+		{"id":"3","title":"c","keyword":[]},
+		{"id":4,"title":"d","keyword":["y", 5]}
+	]}`
+	elementSchema, err := schema.CompileSchema([]byte(`{
+		"type": "object",
+		"required": ["id", "title"],
+		"properties": {"id": {"type": "integer"}, "keyword": {"type": "array", "items": {"type": "string"}}}
+	}`))
+	if err != nil {
+		t.Fatalf("CompileSchema() error = %v", err)
+	}
+	violations := []string{
+		`/dataset/1 (offset 55): required: missing property "title"`,
+		"/dataset/2/id (offset 89): type: expected integer, found string",
+		"/dataset/3/keyword/1 (offset 158): type: expected string, found integer",
+	}
+
+	tests := []struct {
+		name     string
+		base     string
+		fields   []string
+		filter   string
+		expected string
+	}{
+		{"dotted paths", ".dataset", []string{"id"}, "", "id\n1\n"},
+		{"pointers", "/dataset", []string{"/id"}, "", "/id\n1\n"},
+		{"JSONPath", "$.dataset[*]", []string{"@.id"}, "", "@.id\n1\n"},
+		{"JSONPath filter", "$.dataset[?(@.id > 0)]", []string{"@.title"}, "", "@.title\na\n"},
+		{"with filter", "/dataset", []string{"/id"}, "/id != 1", "/id\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			writer := csv.NewWriter(&output)
+			extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), writer, tt.base, tt.fields)
+			if err != nil {
+				t.Fatalf("NewJSONExtractor() error = %v", err)
+			}
+			if tt.filter != "" {
+				rowFilter, err := ParseRowFilter(tt.filter)
+				if err != nil {
+					t.Fatalf("ParseRowFilter() error = %v", err)
+				}
+				if err := extractor.SetFilter(rowFilter); err != nil {
+					t.Fatalf("SetFilter() error = %v", err)
+				}
+			}
+			var reported []string
+			err = extractor.SetSchema(elementSchema, func(violation schema.Violation) error {
+				reported = append(reported, violation.String())
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("SetSchema() error = %v", err)
+			}
+			if err := extractor.Extract(); err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			writer.Flush()
+			if output.String() != tt.expected {
+				t.Errorf("Extract() output = %q, want %q", output.String(), tt.expected)
+			}
+			if !reflect.DeepEqual(reported, violations) {
+				t.Errorf("violations = %q, want %q", reported, violations)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+//go:build linux
+
+package extractor
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mapTable maps the hash table file read-write with mmap(2), so that probing a slot is a memory access
+func mapTable(file *os.File, size int64) ([]byte, error) {
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("dedup file too large to map: %d bytes", size)
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("error mapping dedup file: %w", err)
+	}
+	return data, nil
+}
+
+// unmapTable releases a mapping created by mapTable
+func unmapTable(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package extractor
+
+import "os"
+
+// mapTable does not map the file where mmap is not used: every probe reads the file, every insert writes it
+func mapTable(file *os.File, size int64) ([]byte, error) {
+	return nil, nil
+}
+
+// unmapTable is never called since the tables are not mapped
+func unmapTable(data []byte) error {
+	return nil
+}
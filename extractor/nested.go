@@ -0,0 +1,181 @@
+package extractor
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bluesky0724/jsonstream/jsonpath"
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+const (
+	// levelSeparator ends each level of a nested base, e.g. ".dataset[*].distribution[*]"
+	levelSeparator = "[*]"
+	// ancestorPrefix starts a field of an ancestor of the base elements, once per level up,
+	// e.g. "^identifier" for the identifier of the dataset of each distribution
+	ancestorPrefix = "^"
+)
+
+// nestedBase is a base of several levels of arrays: the elements of the last level are the base elements,
+// and their rows also carry the fields of their ancestors
+// Since an ancestor may have fields after the array of its children, the base elements wait with their values
+// until their outermost ancestor ends, so that every element of one outermost element is held in memory
+type nestedBase struct {
+	levels      []parser.Pointer // the paths of the elements of each level, the outermost first
+	fieldLevels []int            // the level of each collected field, in the order of fields
+	pending     []nestedElement  // the base elements of the current outermost element
+	marks       []int            // for each level, the first pending element of its current element
+}
+
+// nestedElement is a base element waiting for the fields of its ancestors
+type nestedElement struct {
+	values    map[string][]any
+	positions map[string][]arrayPosition
+}
+
+// isNestedBase tells whether the base has levels
+func isNestedBase(base string) bool {
+	return !jsonpath.IsPath(base) && strings.Contains(base, levelSeparator)
+}
+
+// parseNestedBase switches to the nested mode, a pointer mode, when the base has levels
+// Each level is a dotted path or a JSON Pointer relative to the elements of the previous level,
+// the first one may be empty for the elements of a root array
+func (e *JSONExtractor) parseNestedBase() error {
+	e.nested = nil
+	if !isNestedBase(e.base) {
+		for _, field := range e.fields {
+			if strings.HasPrefix(field, ancestorPrefix) {
+				return fmt.Errorf("invalid target field %q: ancestor fields need a nested base", field)
+			}
+		}
+		return nil
+	}
+
+	nested := &nestedBase{}
+	parts := strings.Split(e.base, levelSeparator)
+	if parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	var path parser.Pointer
+	for i, part := range parts {
+		part = strings.TrimPrefix(part, ".")
+		if part == "" && i > 0 {
+			return fmt.Errorf("invalid base field %q: level %d has no path", e.base, i+1)
+		}
+		pointer, err := toPointer(part)
+		if err != nil {
+			return fmt.Errorf("invalid base field: %w", err)
+		}
+		path = append(slices.Clip(path), pointer...)
+		nested.levels = append(nested.levels, path)
+	}
+
+	last := len(nested.levels) - 1
+	for _, field := range e.fields {
+		up := len(field) - len(strings.TrimLeft(field, ancestorPrefix))
+		if up > last {
+			return fmt.Errorf("invalid target field %q: the base elements have %d levels of ancestors", field, last)
+		}
+		nested.fieldLevels = append(nested.fieldLevels, last-up)
+	}
+	nested.marks = make([]int, len(nested.levels))
+	e.nested = nested
+	return nil
+}
+
+// levelOf returns the level whose elements end at the path, -1 when none does
+// The base elements may be objects or primitive values, their ancestors are objects
+func (n *nestedBase) levelOf(path []parser.PathSegment, ending parser.EventKind) int {
+	last := len(n.levels) - 1
+	if ending == parser.Value {
+		if n.levels[last].Match(path) {
+			return last
+		}
+		return -1
+	}
+	if ending != parser.ObjectEnd {
+		return -1
+	}
+	for level := last; level >= 0; level-- {
+		if n.levels[level].Match(path) {
+			return level
+		}
+	}
+	return -1
+}
+
+// fieldLevel returns the level of the elements a collected field is read from, 0 outside of the nested mode
+func (e *JSONExtractor) fieldLevel(i int) int {
+	if e.nested == nil {
+		return 0
+	}
+	return e.nested.fieldLevels[i]
+}
+
+// fieldBase returns the elements a collected field is read from in the pointer mode
+func (e *JSONExtractor) fieldBase(i int) elementPath {
+	return e.elements[e.fieldLevel(i)]
+}
+
+// endNested ends an element of a level: a valid base element starts waiting, and the values of the fields
+// of the level are given to the waiting elements inside the ended one
+// The rows of the waiting elements are written when their outermost ancestor ends
+func (e *JSONExtractor) endNested(level int) error {
+	n := e.nested
+	if level == len(n.levels)-1 && (e.gate == nil || !e.gate.invalid) {
+		n.pending = append(n.pending, nestedElement{
+			values:    make(map[string][]any, len(e.fields)),
+			positions: make(map[string][]arrayPosition),
+		})
+	}
+	for i, field := range e.fields {
+		if n.fieldLevels[i] != level {
+			continue
+		}
+		absolutePath := getAbsolutePath(e.base, field)
+		for _, element := range n.pending[n.marks[level]:] {
+			element.values[absolutePath] = e.values[absolutePath]
+			if e.tracksPositions() {
+				element.positions[absolutePath] = e.positions[absolutePath]
+			}
+		}
+		e.values[absolutePath] = []any{}
+		if e.tracksPositions() {
+			e.positions[absolutePath] = nil
+		}
+		if i < len(e.captures) {
+			e.captures[i] = nil
+		}
+	}
+	for i, capture := range e.arrays {
+		if e.arrayLevel(capture) != level {
+			continue
+		}
+		arrayPath := e.arrayPath(capture.field)
+		for _, element := range n.pending[n.marks[level]:] {
+			element.values[arrayPath] = e.values[arrayPath]
+		}
+		e.values[arrayPath] = []any{}
+		e.arrays[i].node = nil
+	}
+	n.marks[level] = len(n.pending)
+	if level > 0 {
+		return nil
+	}
+
+	pending := n.pending
+	n.pending = nil
+	clear(n.marks)
+	// The filter reads the values of the element from the extractor
+	values, positions := e.values, e.positions
+	defer func() { e.values, e.positions = values, positions }()
+	for _, element := range pending {
+		e.values, e.positions = element.values, element.positions
+		if err := e.writeValues(element.values); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package extractor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestJSONExtractorNestedBase(t *testing.T) {
+	// The title of a dataset comes after its distributions
+	input := `{"catalog":"c","dataset":[
+		{"identifier":"d1","distribution":[{"mediaType":"csv","tags":["a","b"]},{"mediaType":"json"}],"title":"T1"},
+		{"identifier":"d2","distribution":[],"title":"T2"},
+		{"distribution":{"mediaType":"xml"},"identifier":"d3"}
+	]}`
+
+	tests := []struct {
+		name     string
+		input    string
+		base     string
+		fields   []string
+		filter   string
+		expected string
+	}{
+		{
+			name:   "dotted",
+			input:  input,
+			base:   ".dataset[*].distribution[*]",
+			fields: []string{"^identifier", "^title", "mediaType", "tags"},
+			expected: "^identifier,^title,mediaType,tags\n" +
+				"d1,T1,csv,a\nd1,T1,csv,b\nd1,T1,json,\nd3,,xml,\n",
+		},
+		{
+			name:   "pointers",
+			input:  input,
+			base:   "/dataset[*]/distribution",
+			fields: []string{"/mediaType", "^/title", "tags|join"},
+			expected: "/mediaType,^/title,tags\n" +
+				"csv,T1,a;b\njson,T1,\nxml,,\n",
+		},
+		{
+			name:   "filter on an ancestor",
+			input:  input,
+			base:   ".dataset[*].distribution[*]",
+			fields: []string{"^identifier", "mediaType"},
+			filter: "`^title` == \"T1\" and mediaType != \"csv\"",
+			expected: "^identifier,mediaType\n" +
+				"d1,json\n",
+		},
+		{
+			name: "three levels from a root array",
+			input: `[
+				{"dataset":[{"distribution":[{"url":"u1"}],"id":1},{"distribution":[{"url":"u2"},{"url":"u3"}],"id":2}],"name":"a"},
+				{"name":"b","dataset":[{"id":3,"distribution":[{"url":"u4"}]}]}
+			]`,
+			base:   "[*].dataset[*].distribution[*]",
+			fields: []string{"^^name", "^id", "url"},
+			expected: "^^name,^id,url\n" +
+				"a,1,u1\na,2,u2\na,2,u3\nb,3,u4\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			writer := csv.NewWriter(&output)
+			extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(tt.input)), writer, tt.base, tt.fields)
+			if err != nil {
+				t.Fatalf("NewJSONExtractor() error = %v", err)
+			}
+			if tt.filter != "" {
+				filter, err := ParseRowFilter(tt.filter)
+				if err != nil {
+					t.Fatalf("ParseRowFilter() error = %v", err)
+				}
+				if err := extractor.SetFilter(filter); err != nil {
+					t.Fatalf("SetFilter() error = %v", err)
+				}
+			}
+			if err := extractor.Extract(); err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			writer.Flush()
+			if output.String() != tt.expected {
+				t.Errorf("Extract() output = %q, want %q", output.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestJSONExtractorNestedBaseErrors(t *testing.T) {
+	tests := []struct {
+		base   string
+		fields []string
+	}{
+		{".dataset", []string{"^identifier"}},
+		{".dataset[*].distribution[*]", []string{"^^identifier"}},
+		{".dataset[*][*]", []string{"id"}},
+	}
+
+	for _, tt := range tests {
+		_, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(`{}`)), csv.NewWriter(&bytes.Buffer{}), tt.base, tt.fields)
+		if err == nil {
+			t.Errorf("NewJSONExtractor(%q, %q) expected an error", tt.base, tt.fields)
+		}
+	}
+}
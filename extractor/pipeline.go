@@ -0,0 +1,312 @@
+package extractor
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/bluesky0724/jsonstream/output"
+	"github.com/bluesky0724/jsonstream/parser"
+	"github.com/bluesky0724/jsonstream/schema"
+)
+
+// PipelineOptions configures the pipelined extraction mode
+type PipelineOptions struct {
+	ChunkSize  int // size of each chunk prefetched from the input: parser.ChunkSize by default
+	QueueDepth int // number of prefetched chunks waiting for the parser: 16 by default
+	RowBuffer  int // number of rows waiting for the writer: 256 by default
+
+	// Parser configures the parser, e.g. DecodeStrings for the outputs that write decoded strings
+	Parser parser.Options
+
+	// Filter keeps only the base elements it matches, see JSONExtractor.SetFilter
+	Filter *RowFilter
+	// Schema keeps only the base elements satisfying it, see JSONExtractor.SetSchema
+	// OnViolation is called with the violations on the parsing goroutine
+	Schema      *schema.Schema
+	OnViolation func(schema.Violation) error
+	// Dedup drops the duplicate rows, see JSONExtractor.SetDedup
+	Dedup *DedupOptions
+	// Rows zips and numbers the exploded fields and caps the rows of an element, see JSONExtractor.SetRows
+	Rows *RowOptions
+	// Containers writes the targets whose values are objects as JSON or sub-columns, see JSONExtractor.SetContainers
+	Containers *ContainerOptions
+}
+
+// withDefaults fills the zero fields of the options with the default values
+func (o PipelineOptions) withDefaults() PipelineOptions {
+	if o.ChunkSize <= 0 {
+		o.ChunkSize = parser.ChunkSize
+	}
+	if o.QueueDepth <= 0 {
+		o.QueueDepth = 16
+	}
+	if o.RowBuffer <= 0 {
+		o.RowBuffer = 256
+	}
+	return o
+}
+
+// ExtractPipelined extracts the target fields like Extract, but runs the work in three stages:
+//
+//	reader: prefetches chunks of the input into a bounded queue
+//	parser: parses the chunks and composes the rows
+//	writer: writes the rows with the CSV writer and flushes it at the end
+//
+// Every stage blocks when the queue in front of it is full, so a slow writer
+// slows down the parser and the parser slows down the reader.
+// The first error of any stage cancels the other stages and is returned,
+// and cancelling ctx stops all the stages with the context error.
+// When reader is an io.Closer, it is closed on cancellation so that a read blocked on a slow input,
+// like an HTTP body, returns too; the caller still closes it after a successful run.
+func ExtractPipelined(ctx context.Context, reader io.Reader, writer *csv.Writer, baseField string, fields []string, opts PipelineOptions) error {
+	return ExtractPipelinedTo(ctx, reader, output.NewCSVWriter(writer), baseField, fields, opts)
+}
+
+// ExtractPipelinedTo runs the pipelined extraction like ExtractPipelined, writing the rows to any RowWriter
+// The writer stage flushes the writer at the end but does not close it
+func ExtractPipelinedTo(ctx context.Context, reader io.Reader, writer output.RowWriter, baseField string, fields []string, opts PipelineOptions) error {
+	opts = opts.withDefaults()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	// Registered after cancel, stop runs first and a successful run leaves the reader open
+	if closer, ok := reader.(io.Closer); ok {
+		stop := context.AfterFunc(ctx, func() { closer.Close() })
+		defer stop()
+	}
+
+	chunks := make(chan []byte, opts.QueueDepth)
+	records := make(chan queuedRow, opts.RowBuffer)
+	parsed := make(chan struct{}) // closed when the parser needs no more input
+
+	// An ElementWriter gets whole elements through the queue too
+	var queue output.RowWriter = &queueWriter{ctx: ctx, records: records}
+	if _, ok := writer.(output.ElementWriter); ok {
+		queue = &queueElementWriter{queueWriter{ctx: ctx, records: records}}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+
+	// Reader stage: the parser gets the chunks through a chunkReader
+	go func() {
+		defer wg.Done()
+		if err := prefetch(ctx, reader, chunks, parsed, opts.ChunkSize); err != nil {
+			cancel(err)
+		}
+		// Closing after the cancellation lets the parser tell a failure from the end of input
+		close(chunks)
+	}()
+
+	// Parser stage: the extractor sends copies of the rows to the writer stage
+	go func() {
+		defer wg.Done()
+		defer close(records)
+		defer close(parsed)
+		if err := parseChunks(ctx, chunks, queue, baseField, fields, opts); err != nil {
+			cancel(err)
+		}
+	}()
+
+	// Writer stage: the only goroutine touching the writer
+	go func() {
+		defer wg.Done()
+		if err := writeRecords(ctx, writer, records); err != nil {
+			cancel(err)
+		}
+	}()
+
+	wg.Wait()
+	return context.Cause(ctx)
+}
+
+// prefetch reads the input chunk by chunk and queues the chunks for the parser
+// It stops at the end of input, or as soon as the parser is done or the pipeline is cancelled
+func prefetch(ctx context.Context, reader io.Reader, chunks chan<- []byte, parsed <-chan struct{}, chunkSize int) error {
+	for {
+		// Every chunk has its own backing array since the parser may still hold the previous one
+		chunk := make([]byte, chunkSize)
+		n, err := reader.Read(chunk)
+		if n > 0 {
+			select {
+			case chunks <- chunk[:n]:
+			case <-parsed:
+				return nil
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading input: %w", err)
+		}
+	}
+}
+
+// parseChunks runs the extractor over the queued chunks
+func parseChunks(ctx context.Context, chunks <-chan []byte, queue output.RowWriter, baseField string, fields []string, opts PipelineOptions) error {
+	input := bufio.NewReader(&chunkReader{ctx: ctx, chunks: chunks})
+
+	jsonParser, err := parser.NewParser(input, opts.Parser, nil)
+	if err != nil {
+		return err
+	}
+	extractor, err := NewJSONExtractorWithWriter(jsonParser, queue, baseField, fields)
+	if err != nil {
+		return err
+	}
+	if opts.Filter != nil {
+		if err := extractor.SetFilter(opts.Filter); err != nil {
+			return err
+		}
+	}
+	if opts.Schema != nil {
+		if err := extractor.SetSchema(opts.Schema, opts.OnViolation); err != nil {
+			return err
+		}
+	}
+	if err := extractor.SetRows(opts.Rows); err != nil {
+		return err
+	}
+	if err := extractor.SetContainers(opts.Containers); err != nil {
+		return err
+	}
+	if err := extractor.SetDedup(opts.Dedup); err != nil {
+		return err
+	}
+	if err := extractor.Extract(); err != nil {
+		// The parser only sees the cancellation through the chunkReader or writeRow,
+		// so report the original cause rather than the wrapped copy
+		if cause := context.Cause(ctx); cause != nil {
+			return cause
+		}
+		return err
+	}
+	return nil
+}
+
+// queuedRow is the header, when not nil, the columns of an element, when not nil, or a row queued for the writer stage
+type queuedRow struct {
+	header  []string
+	element [][]any
+	values  []any
+}
+
+// queueWriter is the RowWriter of the parser stage, it queues copies of the rows for the writer stage
+type queueWriter struct {
+	ctx     context.Context
+	records chan<- queuedRow
+}
+
+// WriteHeader queues the column names
+func (q *queueWriter) WriteHeader(columns []string) error {
+	return q.queue(queuedRow{header: append([]string{}, columns...)})
+}
+
+// WriteRow queues a copy of the row, since backtrack reuses the slice
+func (q *queueWriter) WriteRow(values []any) error {
+	return q.queue(queuedRow{values: append([]any(nil), values...)})
+}
+
+// Flush does nothing, the writer stage flushes the writer
+func (q *queueWriter) Flush() error { return nil }
+
+// Close does nothing, the writer stage flushes the writer
+func (q *queueWriter) Close() error { return nil }
+
+// queueElementWriter is the queueWriter of an output.ElementWriter, it also queues whole elements
+type queueElementWriter struct {
+	queueWriter
+}
+
+// WriteElement queues a copy of the columns of an element
+func (q *queueElementWriter) WriteElement(columns [][]any) error {
+	element := make([][]any, len(columns))
+	for i, values := range columns {
+		element[i] = append([]any{}, values...)
+	}
+	return q.queue(queuedRow{element: element})
+}
+
+// queue waits for room in the queue unless the pipeline is cancelled
+func (q *queueWriter) queue(row queuedRow) error {
+	select {
+	case q.records <- row:
+		return nil
+	case <-q.ctx.Done():
+		return context.Cause(q.ctx)
+	}
+}
+
+// writeRecords writes the queued rows until the parser stage closes the channel
+// The writer is only flushed when every stage succeeded, a failed pipeline leaves it to the caller
+func writeRecords(ctx context.Context, writer output.RowWriter, records <-chan queuedRow) error {
+	for {
+		select {
+		case record, ok := <-records:
+			if !ok {
+				// The parser stage also closes the channel after a failure, which the select may see first
+				if context.Cause(ctx) != nil {
+					return nil
+				}
+				if err := writer.Flush(); err != nil {
+					return fmt.Errorf("error flushing rows: %w", err)
+				}
+				return nil
+			}
+			var err error
+			if record.header != nil {
+				err = writer.WriteHeader(record.header)
+			} else if record.element != nil {
+				err = writer.(output.ElementWriter).WriteElement(record.element)
+			} else {
+				err = writer.WriteRow(record.values)
+			}
+			if err != nil {
+				return fmt.Errorf("error writing field values: %w", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// chunkReader is an io.Reader over the chunks queued by the reader stage
+type chunkReader struct {
+	ctx     context.Context
+	chunks  <-chan []byte
+	current []byte // the rest of the chunk being read
+}
+
+// errPipelineStopped is returned by chunkReader when another stage cancelled the pipeline
+var errPipelineStopped = errors.New("pipeline stopped")
+
+// Read copies the queued chunks into p, waiting for the next chunk when needed
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.current) == 0 {
+		select {
+		case chunk, ok := <-r.chunks:
+			if !ok {
+				// The reader stage closes the channel at the end of input, but also when it
+				// stops on cancellation, in which case the parser must not see a clean EOF
+				if r.ctx.Err() != nil {
+					return 0, errPipelineStopped
+				}
+				return 0, io.EOF
+			}
+			r.current = chunk
+		case <-r.ctx.Done():
+			return 0, errPipelineStopped
+		}
+	}
+	n := copy(p, r.current)
+	r.current = r.current[n:]
+	return n, nil
+}
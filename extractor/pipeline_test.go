@@ -0,0 +1,240 @@
+package extractor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bluesky0724/jsonstream/encoder"
+	"github.com/bluesky0724/jsonstream/output"
+	"github.com/bluesky0724/jsonstream/parser"
+	"github.com/bluesky0724/jsonstream/schema"
+)
+
+// extractSequential runs the plain extractor to get the output the pipelined mode must reproduce
+func extractSequential(t *testing.T, input string, base string, fields []string) string {
+	t.Helper()
+	var output bytes.Buffer
+	writer := csv.NewWriter(&output)
+	extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), writer, base, fields)
+	if err != nil {
+		t.Fatalf("NewJSONExtractor() error = %v", err)
+	}
+	if err := extractor.Extract(); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	writer.Flush()
+	return output.String()
+}
+
+func TestExtractPipelined(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		base   string
+		fields []string
+		opts   PipelineOptions
+	}{
+		{
+			name:   "default options",
+			input:  `{"data":[{"id":1,"name":"John"},{"id":2,"name":"Jane"}]}`,
+			base:   ".data",
+			fields: []string{"id", "name"},
+		},
+		{
+			name:   "tiny chunks and queues",
+			input:  `{"data":[{"id":1,"tags":["a","b"]},{"id":2,"tags":["c"]},{"id":3}]}`,
+			base:   ".data",
+			fields: []string{"id", "tags"},
+			opts:   PipelineOptions{ChunkSize: 3, QueueDepth: 1, RowBuffer: 1},
+		},
+		{
+			name:   "trailing data after the document",
+			input:  `{"data":[{"id":1}]}` + strings.Repeat(" ", 4096),
+			base:   ".data",
+			fields: []string{"id"},
+			opts:   PipelineOptions{ChunkSize: 8, QueueDepth: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			writer := csv.NewWriter(&output)
+			err := ExtractPipelined(context.Background(), strings.NewReader(tt.input), writer, tt.base, tt.fields, tt.opts)
+			if err != nil {
+				t.Fatalf("ExtractPipelined() error = %v", err)
+			}
+
+			expected := extractSequential(t, tt.input, tt.base, tt.fields)
+			if output.String() != expected {
+				t.Errorf("ExtractPipelined() output = %q, want %q", output.String(), expected)
+			}
+		})
+	}
+}
+
+func TestExtractPipelinedFilter(t *testing.T) {
+	input := `{"data":[{"id":1,"level":"public"},{"id":2,"level":"private"},{"id":3,"level":"public"}]}`
+	filter, err := ParseRowFilter(`level == "public"`)
+	if err != nil {
+		t.Fatalf("ParseRowFilter() error = %v", err)
+	}
+
+	var output bytes.Buffer
+	writer := csv.NewWriter(&output)
+	err = ExtractPipelined(context.Background(), strings.NewReader(input), writer, ".data", []string{"id"}, PipelineOptions{ChunkSize: 5, Filter: filter})
+	if err != nil {
+		t.Fatalf("ExtractPipelined() error = %v", err)
+	}
+	if expected := "id\n1\n3\n"; output.String() != expected {
+		t.Errorf("ExtractPipelined() output = %q, want %q", output.String(), expected)
+	}
+}
+
+func TestExtractPipelinedSchema(t *testing.T) {
+	input := `{"data":[{"id":1,"level":"public"},{"id":2},{"id":3,"level":"public"}]}`
+	elementSchema, err := schema.CompileSchema([]byte(`{"required": ["level"]}`))
+	if err != nil {
+		t.Fatalf("CompileSchema() error = %v", err)
+	}
+
+	var output bytes.Buffer
+	var violations []string
+	writer := csv.NewWriter(&output)
+	err = ExtractPipelined(context.Background(), strings.NewReader(input), writer, ".data", []string{"id"}, PipelineOptions{
+		ChunkSize: 5,
+		Schema:    elementSchema,
+		OnViolation: func(violation schema.Violation) error {
+			violations = append(violations, violation.Pointer)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("ExtractPipelined() error = %v", err)
+	}
+	if expected := "id\n1\n3\n"; output.String() != expected {
+		t.Errorf("ExtractPipelined() output = %q, want %q", output.String(), expected)
+	}
+	if len(violations) != 1 || violations[0] != "/data/1" {
+		t.Errorf("violations = %q, want [/data/1]", violations)
+	}
+}
+
+func TestExtractPipelinedTo(t *testing.T) {
+	input := `{"data":[{"id":1,"name":"caf\u00e9"},{"id":2}]}`
+	var buffer bytes.Buffer
+	writer := output.NewJSONWriter(&buffer, encoder.Options{})
+	err := ExtractPipelinedTo(context.Background(), strings.NewReader(input), writer, ".data", []string{"id", "name"}, PipelineOptions{
+		ChunkSize: 4,
+		RowBuffer: 1,
+		Parser:    parser.Options{DecodeStrings: true},
+	})
+	if err != nil {
+		t.Fatalf("ExtractPipelinedTo() error = %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if expected := `[{"id":1,"name":"café"},{"id":2}]`; buffer.String() != expected {
+		t.Errorf("ExtractPipelinedTo() output = %q, want %q", buffer.String(), expected)
+	}
+}
+
+func TestExtractPipelinedElements(t *testing.T) {
+	input := `{"data":[{"id":1,"tags":["a","b"]},{"id":2,"tags":[]}]}`
+	var buffer bytes.Buffer
+	writer, err := output.NewBulkWriter(&buffer, output.BulkOptions{IDField: "id"})
+	if err != nil {
+		t.Fatalf("NewBulkWriter() error = %v", err)
+	}
+	err = ExtractPipelinedTo(context.Background(), strings.NewReader(input), writer, ".data", []string{"id", "tags"}, PipelineOptions{
+		ChunkSize: 4,
+		RowBuffer: 1,
+		Parser:    parser.Options{DecodeStrings: true},
+	})
+	if err != nil {
+		t.Fatalf("ExtractPipelinedTo() error = %v", err)
+	}
+	expected := `{"index":{"_id":"1"}}` + "\n" + `{"id":1,"tags":["a","b"]}` + "\n" +
+		`{"index":{"_id":"2"}}` + "\n" + `{"id":2}` + "\n"
+	if buffer.String() != expected {
+		t.Errorf("ExtractPipelinedTo() output = %q, want %q", buffer.String(), expected)
+	}
+}
+
+// failingReader returns its data and then fails
+type failingReader struct {
+	data io.Reader
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		return n, r.err
+	}
+	return n, err
+}
+
+// failingWriter fails every write
+type failingWriter struct{ err error }
+
+func (w failingWriter) Write(p []byte) (int, error) { return 0, w.err }
+
+func TestExtractPipelinedErrors(t *testing.T) {
+	readErr := errors.New("connection reset")
+	writeErr := errors.New("disk full")
+	input := `{"data":[{"id":1},{"id":2},{"id":3}]}`
+
+	t.Run("reader error", func(t *testing.T) {
+		reader := &failingReader{data: strings.NewReader(input[:15]), err: readErr}
+		writer := csv.NewWriter(io.Discard)
+		err := ExtractPipelined(context.Background(), reader, writer, ".data", []string{"id"}, PipelineOptions{ChunkSize: 4})
+		if !errors.Is(err, readErr) {
+			t.Errorf("ExtractPipelined() error = %v, want %v", err, readErr)
+		}
+	})
+
+	t.Run("writer error", func(t *testing.T) {
+		writer := csv.NewWriter(failingWriter{err: writeErr})
+		err := ExtractPipelined(context.Background(), strings.NewReader(input), writer, ".data", []string{"id"}, PipelineOptions{})
+		if !errors.Is(err, writeErr) {
+			t.Errorf("ExtractPipelined() error = %v, want %v", err, writeErr)
+		}
+	})
+
+	t.Run("parser error while the reader waits", func(t *testing.T) {
+		// The pipe is never closed by the writer, the read only returns when the pipeline closes it
+		reader, source := io.Pipe()
+		go source.Write([]byte(`{"data":[{"id":1} oops`))
+		done := make(chan error, 1)
+		go func() {
+			done <- ExtractPipelined(context.Background(), reader, csv.NewWriter(io.Discard), ".data", []string{"id"}, PipelineOptions{})
+		}()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Error("ExtractPipelined() expected a parse error")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("ExtractPipelined() did not return after the parse error")
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		writer := csv.NewWriter(io.Discard)
+		err := ExtractPipelined(ctx, strings.NewReader(input), writer, ".data", []string{"id"}, PipelineOptions{})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ExtractPipelined() error = %v, want %v", err, context.Canceled)
+		}
+	})
+}
@@ -0,0 +1,425 @@
+package extractor
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/bluesky0724/jsonstream/encoder"
+	"github.com/bluesky0724/jsonstream/jsonpath"
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// ProjectionLayout defines the shape of the document written by JSONProjector
+type ProjectionLayout int
+
+const (
+	// ProjectNested keeps the original nesting: the path to the base array, the base elements,
+	// and inside each element only the selected fields and the containers leading to them
+	ProjectNested ProjectionLayout = iota
+	// ProjectFlat writes a flat array with one object per base element, keyed by the field names
+	// A field found once holds its value, a field found several times (e.g. in an array) holds
+	// the array of its values, and a field not found is left out
+	ProjectFlat
+)
+
+// ProjectOptions configures a JSONProjector
+type ProjectOptions struct {
+	Layout  ProjectionLayout
+	Encoder encoder.Options // the formatting of the output, e.g. the indentation
+	Parser  parser.Options  // the parser options, strings are always decoded and numbers kept as text
+}
+
+// role is what a value of the input is to the projection
+type role int
+
+const (
+	skipped      role = iota // nothing of the value is written
+	skeleton                 // the value is on the path to the base
+	baseArray                // the value is the base array, or an array inside it
+	element                  // the value is a base element
+	intermediate             // the value leads to a selected field, written only if the field is found
+	selected                 // the value is a selected field, written as a whole
+)
+
+// projectionFrame is an open object or array of the input
+type projectionFrame struct {
+	role    role
+	isArray bool
+	path    []parser.PathSegment // the path of the container in the document
+	rel     []parser.PathSegment // the path of the container inside the element
+	key     string               // the key of the container in its parent object
+	hasKey  bool                 // whether the container is a value of an object
+	written bool                 // whether the container is opened in the output
+	nextKey string               // the key of the next value, for objects
+	length  int                  // the number of values so far, for arrays
+}
+
+// JSONProjector streams a JSON document keeping only the selected fields of the base elements
+// It takes the same base and fields as JSONExtractor, but writes JSON instead of CSV
+// Only the current base element is kept in memory, and only in the flat layout
+type JSONProjector struct {
+	parser  *parser.JSONParser
+	encoder *encoder.JSONEncoder
+	base    parser.Pointer
+	targets []parser.Pointer // the fields as pointers, matched like in the pointer mode of JSONExtractor
+	names   []string         // the fields as given, the keys of the flat layout
+	opts    ProjectOptions
+
+	stack     []projectionFrame
+	skipDepth int // the number of open containers of a skipped value
+
+	// In the flat layout, the events of each match of each field of the current element
+	matches [][][]parser.Event
+	capture []parser.Event // the events of the selected value being captured
+	fields  []int          // the fields matching the value being captured
+}
+
+// NewJSONProjector creates a new JSONProjector reading from reader and writing to writer
+// The base and fields are dotted paths or JSON Pointers, array positions included
+func NewJSONProjector(reader io.Reader, writer io.Writer, baseField string, fields []string, opts ProjectOptions) (*JSONProjector, error) {
+	if _, members := membersBase(baseField); members {
+		return nil, fmt.Errorf("invalid base field: members base %q is not supported here", baseField)
+	}
+	base, err := projectionPath(strings.TrimPrefix(baseField, "."))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base field: %w", err)
+	}
+	targets := make([]parser.Pointer, len(fields))
+	for i, field := range fields {
+		if targets[i], err = projectionPath(field); err != nil {
+			return nil, fmt.Errorf("invalid target field: %w", err)
+		}
+	}
+
+	jsonParser, err := parser.NewParser(reader, encoder.PipeOptions(opts.Parser), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	projector := &JSONProjector{
+		parser:  jsonParser,
+		encoder: encoder.NewJSONEncoder(writer, opts.Encoder),
+		base:    base,
+		targets: targets,
+		names:   fields,
+		opts:    opts,
+		matches: make([][][]parser.Event, len(fields)),
+	}
+	jsonParser.SetEventHandler(projector.eventHandler)
+
+	return projector, nil
+}
+
+// Project parses the whole input and writes the projection
+func (p *JSONProjector) Project() error {
+	if p.opts.Layout == ProjectFlat {
+		if err := p.encoder.BeginArray(); err != nil {
+			return err
+		}
+	}
+	if err := p.parser.Parse(); err != nil {
+		return fmt.Errorf("error parsing data: %w", err)
+	}
+	if p.opts.Layout == ProjectFlat {
+		if err := p.encoder.EndArray(); err != nil {
+			return err
+		}
+	}
+	if err := p.encoder.Close(); err != nil {
+		return fmt.Errorf("error writing JSON: %w", err)
+	}
+	return nil
+}
+
+// eventHandler follows the structure of the input and writes the parts of it to keep
+func (p *JSONProjector) eventHandler(event parser.Event) error {
+	// Ignore everything inside a skipped container
+	if p.skipDepth > 0 {
+		switch event.Kind {
+		case parser.ObjectStart, parser.ArrayStart:
+			p.skipDepth++
+		case parser.ObjectEnd, parser.ArrayEnd:
+			p.skipDepth--
+		}
+		return nil
+	}
+
+	switch event.Kind {
+	case parser.Key:
+		p.stack[len(p.stack)-1].nextKey = event.Key
+		return nil
+	case parser.ObjectEnd, parser.ArrayEnd:
+		return p.closeContainer(event)
+	}
+
+	// The event starts a value: find out what it is to the projection
+	isContainer := event.Kind == parser.ObjectStart || event.Kind == parser.ArrayStart
+	frame := p.childFrame(event.Kind == parser.ArrayStart)
+	frame.role = p.decide(frame, event.Kind)
+
+	if frame.role == skipped {
+		if isContainer {
+			p.skipDepth = 1
+		}
+		return nil
+	}
+	if !isContainer {
+		// Only selected values are written, scalars anywhere else are left out
+		return p.writeSelected(frame, event)
+	}
+
+	if frame.role == selected {
+		if err := p.writeSelected(frame, event); err != nil {
+			return err
+		}
+		frame.written = true
+	} else if (frame.role == baseArray || frame.role == element || len(p.stack) == 0) && p.opts.Layout == ProjectNested {
+		// The root, the base arrays and the elements are always written, the path to them once it leads to one
+		if err := p.open(&frame); err != nil {
+			return err
+		}
+	}
+	if frame.role == element {
+		clear(p.matches)
+	}
+	p.stack = append(p.stack, frame)
+	return nil
+}
+
+// childFrame creates the frame of a value of the current container, or of the root value
+func (p *JSONProjector) childFrame(isArray bool) projectionFrame {
+	frame := projectionFrame{isArray: isArray}
+	if len(p.stack) == 0 {
+		return frame // the root value, at the field ""
+	}
+	parent := &p.stack[len(p.stack)-1]
+	segment := parser.PathSegment{Key: parent.nextKey, Index: -1}
+	if parent.isArray {
+		segment = parser.PathSegment{Index: parent.length}
+		parent.length++
+	} else {
+		frame.key, frame.hasKey = parent.nextKey, true
+	}
+	frame.path = append(slices.Clip(parent.path), segment)
+	frame.rel = parent.rel
+	if parent.role != skeleton && parent.role != baseArray {
+		frame.rel = append(slices.Clip(parent.rel), segment)
+	}
+	return frame
+}
+
+// decide finds the role of a value from the role of its container
+func (p *JSONProjector) decide(frame projectionFrame, kind parser.EventKind) role {
+	parentRole := skeleton
+	if len(p.stack) > 0 {
+		parentRole = p.stack[len(p.stack)-1].role
+	}
+
+	switch parentRole {
+	case skeleton:
+		if p.base.Match(frame.path) {
+			switch kind {
+			case parser.ArrayStart:
+				return baseArray
+			case parser.ObjectStart:
+				return element // a lone object is a single element
+			}
+			return skipped
+		}
+		if kind != parser.Value && leadsTo(p.base, frame.path) {
+			return skeleton
+		}
+	case baseArray:
+		switch kind {
+		case parser.ArrayStart:
+			return baseArray
+		case parser.ObjectStart:
+			return element
+		}
+	case element, intermediate:
+		for _, target := range p.targets {
+			if target.Match(frame.rel) {
+				return selected
+			}
+		}
+		if kind == parser.Value {
+			return skipped
+		}
+		// Arrays are transparent, so the values of an intermediate array lead to the same fields
+		for _, target := range p.targets {
+			if leadsTo(target, frame.rel) {
+				return intermediate
+			}
+		}
+	case selected:
+		return selected
+	}
+	return skipped
+}
+
+// writeSelected writes, or captures in the flat layout, the start of a selected value
+func (p *JSONProjector) writeSelected(frame projectionFrame, event parser.Event) error {
+	if p.opts.Layout == ProjectFlat {
+		inSelected := p.stack[len(p.stack)-1].role == selected
+		if !inSelected {
+			// The start of a new match of the fields, their names are its keys in the flat object
+			p.fields = p.fields[:0]
+			for i, target := range p.targets {
+				if target.Match(frame.rel) {
+					p.fields = append(p.fields, i)
+				}
+			}
+			p.capture = nil
+		} else if frame.hasKey {
+			p.capture = append(p.capture, parser.Event{Kind: parser.Key, Key: frame.key})
+		}
+		p.capture = append(p.capture, event)
+		if event.Kind == parser.Value && !inSelected {
+			p.endCapture()
+		}
+		return nil
+	}
+
+	if err := p.openAncestors(); err != nil {
+		return err
+	}
+	if frame.hasKey {
+		if err := p.encoder.Key(frame.key); err != nil {
+			return err
+		}
+	}
+	return p.encoder.WriteEvent(event)
+}
+
+// endCapture stores the captured value as a match of its fields
+func (p *JSONProjector) endCapture() {
+	for _, field := range p.fields {
+		p.matches[field] = append(p.matches[field], p.capture)
+	}
+	p.capture = nil
+}
+
+// open writes the start of a container, and of the containers around it that are not written yet
+func (p *JSONProjector) open(frame *projectionFrame) error {
+	if err := p.openAncestors(); err != nil {
+		return err
+	}
+	if frame.hasKey {
+		if err := p.encoder.Key(frame.key); err != nil {
+			return err
+		}
+	}
+	frame.written = true
+	if frame.isArray {
+		return p.encoder.BeginArray()
+	}
+	return p.encoder.BeginObject()
+}
+
+// openAncestors writes the start of the intermediate containers that now have something to hold
+func (p *JSONProjector) openAncestors() error {
+	for i := range p.stack {
+		frame := &p.stack[i]
+		if frame.written {
+			continue
+		}
+		if frame.hasKey {
+			if err := p.encoder.Key(frame.key); err != nil {
+				return err
+			}
+		}
+		frame.written = true
+		var err error
+		if frame.isArray {
+			err = p.encoder.BeginArray()
+		} else {
+			err = p.encoder.BeginObject()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closeContainer handles the end of an object or array
+func (p *JSONProjector) closeContainer(event parser.Event) error {
+	frame := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+
+	if p.opts.Layout == ProjectFlat {
+		switch frame.role {
+		case selected:
+			p.capture = append(p.capture, event)
+			if len(p.stack) == 0 || p.stack[len(p.stack)-1].role != selected {
+				p.endCapture()
+			}
+		case element:
+			return p.writeFlatElement()
+		}
+		return nil
+	}
+
+	if !frame.written {
+		return nil
+	}
+	return p.encoder.WriteEvent(event)
+}
+
+// writeFlatElement writes the fields matched in the current element as one flat object
+func (p *JSONProjector) writeFlatElement() error {
+	if err := p.encoder.BeginObject(); err != nil {
+		return err
+	}
+	for i, matches := range p.matches {
+		if len(matches) == 0 {
+			continue
+		}
+		if err := p.encoder.Key(p.names[i]); err != nil {
+			return err
+		}
+		if len(matches) > 1 {
+			if err := p.encoder.BeginArray(); err != nil {
+				return err
+			}
+		}
+		for _, events := range matches {
+			for _, event := range events {
+				if err := p.encoder.WriteEvent(event); err != nil {
+					return err
+				}
+			}
+		}
+		if len(matches) > 1 {
+			if err := p.encoder.EndArray(); err != nil {
+				return err
+			}
+		}
+	}
+	return p.encoder.EndObject()
+}
+
+// projectionPath parses a field or the base without its leading '.', a JSON Pointer or a dotted path
+// JSONPath expressions and nested bases are rejected
+func projectionPath(path string) (parser.Pointer, error) {
+	if jsonpath.IsPath(path) {
+		return nil, fmt.Errorf("JSONPath %q is not supported here", path)
+	}
+	if isNestedBase(path) {
+		return nil, fmt.Errorf("nested path %q is not supported here", path)
+	}
+	return toPointer(path)
+}
+
+// leadsTo tells whether a value at the path may hold values the pointer refers to:
+// a part of the pointer refers to it, the arrays left out being transparent
+func leadsTo(pointer parser.Pointer, path []parser.PathSegment) bool {
+	for i := range pointer {
+		if pointer[:i].Match(path) {
+			return true
+		}
+	}
+	return false
+}
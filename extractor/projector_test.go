@@ -0,0 +1,205 @@
+package extractor
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bluesky0724/jsonstream/encoder"
+)
+
+func TestJSONProjector(t *testing.T) {
+	input := `{
+		"@type": "dcat:Catalog",
+		"dataset": [
+			{"identifier": "a", "title": "First", "publisher": {"name": "GSA", "@type": "org"}, "keyword": ["x", "y"]},
+			{"identifier": "b", "description": "no title", "distribution": [{"downloadURL": "u1", "mediaType": "csv"}, {"mediaType": "zip"}]},
+			{"title": "Th\"ird", "publisher": {"subOrganizationOf": {"name": "US"}}}
+		]
+	}`
+
+	tests := []struct {
+		name     string
+		base     string
+		fields   []string
+		opts     ProjectOptions
+		expected string
+	}{
+		{
+			name:     "nested layout",
+			base:     ".dataset",
+			fields:   []string{"identifier", "title", "publisher.name"},
+			expected: `{"dataset":[{"identifier":"a","title":"First","publisher":{"name":"GSA"}},{"identifier":"b"},{"title":"Th\"ird"}]}`,
+		},
+		{
+			name:     "nested layout with arrays",
+			base:     ".dataset",
+			fields:   []string{"keyword", "distribution.downloadURL"},
+			expected: `{"dataset":[{"keyword":["x","y"]},{"distribution":[{"downloadURL":"u1"}]},{}]}`,
+		},
+		{
+			name:     "nested layout with object field",
+			base:     ".dataset",
+			fields:   []string{"publisher"},
+			expected: `{"dataset":[{"publisher":{"name":"GSA","@type":"org"}},{},{"publisher":{"subOrganizationOf":{"name":"US"}}}]}`,
+		},
+		{
+			name:     "flat layout",
+			base:     ".dataset",
+			fields:   []string{"identifier", "publisher.name", "keyword", "distribution.mediaType"},
+			opts:     ProjectOptions{Layout: ProjectFlat},
+			expected: `[{"identifier":"a","publisher.name":"GSA","keyword":["x","y"]},{"identifier":"b","distribution.mediaType":["csv","zip"]},{}]`,
+		},
+		{
+			name:     "flat layout with object field",
+			base:     ".dataset",
+			fields:   []string{"publisher"},
+			opts:     ProjectOptions{Layout: ProjectFlat},
+			expected: `[{"publisher":{"name":"GSA","@type":"org"}},{},{"publisher":{"subOrganizationOf":{"name":"US"}}}]`,
+		},
+		{
+			name:     "JSON pointers",
+			base:     "/dataset",
+			fields:   []string{"/identifier", "/publisher/name"},
+			opts:     ProjectOptions{Layout: ProjectFlat},
+			expected: `[{"/identifier":"a","/publisher/name":"GSA"},{"/identifier":"b"},{}]`,
+		},
+		{
+			name:     "indented output",
+			base:     ".dataset",
+			fields:   []string{"identifier"},
+			opts:     ProjectOptions{Layout: ProjectFlat, Encoder: encoder.Options{Indent: " "}},
+			expected: "[\n {\n  \"identifier\": \"a\"\n },\n {\n  \"identifier\": \"b\"\n },\n {}\n]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			projector, err := NewJSONProjector(strings.NewReader(input), &output, tt.base, tt.fields, tt.opts)
+			if err != nil {
+				t.Fatalf("NewJSONProjector() error = %v", err)
+			}
+			if err := projector.Project(); err != nil {
+				t.Fatalf("Project() error = %v", err)
+			}
+			if output.String() != tt.expected {
+				t.Errorf("Project() = %s\n, want %s", output.String(), tt.expected)
+			}
+			if !json.Valid(output.Bytes()) {
+				t.Errorf("Project() output is not valid JSON")
+			}
+		})
+	}
+}
+
+func TestJSONProjectorPointers(t *testing.T) {
+	input := `{"data":[{"a.b":1,"":2,"keyword":["x","y"],"c":{"d":3}},{"a.b":4,"keyword":["z"]}]}`
+
+	tests := []struct {
+		name     string
+		base     string
+		fields   []string
+		opts     ProjectOptions
+		expected string
+	}{
+		{
+			name:     "nested layout",
+			base:     "/data",
+			fields:   []string{"/a.b", "/", "/keyword/0"},
+			expected: `{"data":[{"a.b":1,"":2,"keyword":["x"]},{"a.b":4,"keyword":["z"]}]}`,
+		},
+		{
+			name:     "flat layout",
+			base:     "/data",
+			fields:   []string{"/a.b", "/", "/keyword/0"},
+			opts:     ProjectOptions{Layout: ProjectFlat},
+			expected: `[{"/a.b":1,"/":2,"/keyword/0":"x"},{"/a.b":4,"/keyword/0":"z"}]`,
+		},
+		{
+			name:     "base position",
+			base:     "/data/1",
+			fields:   []string{"/keyword"},
+			expected: `{"data":[{"keyword":["z"]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			projector, err := NewJSONProjector(strings.NewReader(input), &output, tt.base, tt.fields, tt.opts)
+			if err != nil {
+				t.Fatalf("NewJSONProjector() error = %v", err)
+			}
+			if err := projector.Project(); err != nil {
+				t.Fatalf("Project() error = %v", err)
+			}
+			if output.String() != tt.expected {
+				t.Errorf("Project() = %s, want %s", output.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestJSONProjectorUnsupportedPaths(t *testing.T) {
+	tests := []struct {
+		base   string
+		fields []string
+	}{
+		{"/dataset", []string{"/a~"}},
+		{"/dataset/*", []string{"id"}},
+		{"$.dataset[*]", []string{"id"}},
+		{".dataset[*].distribution[*]", []string{"id"}},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewJSONProjector(strings.NewReader(`{}`), &bytes.Buffer{}, tt.base, tt.fields, ProjectOptions{}); err == nil {
+			t.Errorf("NewJSONProjector(%q, %q) expected an error", tt.base, tt.fields)
+		}
+	}
+}
+
+func TestJSONProjectorBaseShapes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		base     string
+		expected string
+	}{
+		{
+			name:     "deep base path",
+			input:    `{"meta":{"x":1},"catalog":{"items":[{"id":1,"y":2}],"z":3}}`,
+			base:     ".catalog.items",
+			expected: `{"catalog":{"items":[{"id":1}]}}`,
+		},
+		{
+			name:     "lone object base",
+			input:    `{"item":{"id":1,"y":2}}`,
+			base:     ".item",
+			expected: `{"item":{"id":1}}`,
+		},
+		{
+			name:     "missing base",
+			input:    `{"other":[{"id":1}]}`,
+			base:     ".dataset",
+			expected: `{}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			projector, err := NewJSONProjector(strings.NewReader(tt.input), &output, tt.base, []string{"id"}, ProjectOptions{})
+			if err != nil {
+				t.Fatalf("NewJSONProjector() error = %v", err)
+			}
+			if err := projector.Project(); err != nil {
+				t.Fatalf("Project() error = %v", err)
+			}
+			if output.String() != tt.expected {
+				t.Errorf("Project() = %s, want %s", output.String(), tt.expected)
+			}
+		})
+	}
+}
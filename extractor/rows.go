@@ -0,0 +1,322 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bluesky0724/jsonstream/jsonpath"
+	"github.com/bluesky0724/jsonstream/output"
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// ordinalitySuffix names the ordinality column of an exploded field, e.g. "keyword_ordinality"
+const ordinalitySuffix = "_ordinality"
+
+// RowOptions configures how the values of an element are combined into rows, see JSONExtractor.SetRows
+// They apply to the rows, not to an output.ElementWriter which gets the values of the element whole
+type RowOptions struct {
+	// Zip aligns the exploded fields in the same array of the element by their index in it,
+	// e.g. "distribution.mediaType" and "distribution.downloadURL" give one row per distribution
+	// instead of every media type with every URL; fields in different arrays are still combined
+	Zip bool
+	// Ordinality lists the exploded fields followed by the column field_ordinality, the position from 1
+	// of the row's value in its array, empty for a missing value
+	Ordinality []string
+	// MaxRows caps the rows written for one element, the following ones are dropped; unlimited when zero
+	MaxRows int
+	// Report, if set, is called with the number of elements whose rows were capped once the extraction is done
+	Report func(truncated int64)
+}
+
+// errRowLimit stops the combination of the values of an element once MaxRows rows are written
+var errRowLimit = errors.New("row limit of the element reached")
+
+// arrayPosition is where a collected value is in the arrays of its element: the first array on its path
+type arrayPosition struct {
+	ancestor string // the keys leading to the array, only set when zipping
+	index    int    // the index in the array, -1 when the value is not in an array
+	inArray  bool   // whether an array the field leaves out holds the value, see fieldDepths
+}
+
+// dimension is a group of output columns whose values vary together: the rows combine the choices of every dimension
+type dimension struct {
+	columns []int   // the positions of the columns in the row
+	choices [][]any // the values of the columns, one slice per choice
+}
+
+// SetRows sets how the values of an element are combined into rows, nil goes back to the cartesian product
+// of the exploded fields without limit
+// The ordinality columns are part of the header, so the dedup columns may name them
+// It must be called before Extract
+func (e *JSONExtractor) SetRows(opts *RowOptions) error {
+	if opts != nil && opts.MaxRows < 0 {
+		return fmt.Errorf("invalid row options: MaxRows %d is negative", opts.MaxRows)
+	}
+	if opts != nil {
+		for _, field := range opts.Ordinality {
+			if !slices.ContainsFunc(e.columns, func(c column) bool { return c.field == field && c.mode == explodeArray }) {
+				return fmt.Errorf("invalid ordinality field %q: it is not an exploded target field", field)
+			}
+		}
+	}
+	e.rows = opts
+	e.header = e.header[:0:0]
+	for i, column := range e.columns {
+		e.header = append(e.header, e.columnHeaders(i)...)
+		if e.numbered(column) {
+			e.header = append(e.header, column.header()+ordinalitySuffix)
+		}
+	}
+	return nil
+}
+
+// Truncated returns the number of elements whose rows were capped by RowOptions.MaxRows so far
+func (e *JSONExtractor) Truncated() int64 {
+	return e.truncated
+}
+
+// numbered tells whether the column is followed by its ordinality column
+func (e *JSONExtractor) numbered(c column) bool {
+	return e.rows != nil && e.writeElement == nil && c.mode == explodeArray && slices.Contains(e.rows.Ordinality, c.field)
+}
+
+// collect adds a value of a field, with its position in the element when the rows or the elements need it
+// relative is the path of the value inside the element
+func (e *JSONExtractor) collect(absolutePath string, value any, relative []parser.PathSegment) {
+	e.values[absolutePath] = append(e.values[absolutePath], value)
+	if !e.tracksPositions() {
+		return
+	}
+	position := arrayPosition{index: -1}
+	for i, segment := range relative {
+		if segment.Index < 0 {
+			continue
+		}
+		position.index = segment.Index
+		// The field has a segment for each of its tokens, the other ones are the arrays it leaves out
+		depth, ok := e.depths[absolutePath]
+		position.inArray = !ok || len(relative) > depth
+		if e.rows != nil && e.rows.Zip {
+			keys := make([]string, i)
+			for j := range keys {
+				keys[j] = relative[j].Key
+			}
+			position.ancestor = strings.Join(keys, "/")
+		}
+		break
+	}
+	e.positions[absolutePath] = append(e.positions[absolutePath], position)
+}
+
+// tracksPositions tells whether the positions of the values are collected: for the row options,
+// and for an output.ElementWriter whose documents keep the arrays of the input arrays
+func (e *JSONExtractor) tracksPositions() bool {
+	return e.rows != nil || e.writeElement != nil
+}
+
+// fieldDepths keeps the number of tokens of each field that is a path, by its absolute path,
+// the JSONPath and synthetic fields having none
+func (e *JSONExtractor) fieldDepths() {
+	e.depths = make(map[string]int, len(e.fields))
+	for _, field := range e.fields {
+		if jsonpath.IsPath(field) || isSynthetic(field) {
+			continue
+		}
+		if pointer, err := toPointer(strings.TrimLeft(field, ancestorPrefix)); err == nil {
+			e.depths[getAbsolutePath(e.base, field)] = len(pointer)
+		}
+	}
+}
+
+// inArray tells whether an array of the element holds a value of the field
+func (e *JSONExtractor) inArray(field string) bool {
+	return slices.ContainsFunc(e.positions[getAbsolutePath(e.base, field)], func(p arrayPosition) bool { return p.inArray })
+}
+
+// zipGroup is the exploded fields of the element in one array, aligned by their index when zipping
+type zipGroup struct {
+	dimension int // the position of the dimension of the group
+	columns   []int
+	fields    []zipField
+}
+
+// zipField is a field of a zipGroup
+type zipField struct {
+	values    []any
+	positions []arrayPosition
+	numbered  bool            // whether the field has an ordinality column
+	cells     func(any) []any // the values of the columns of the field for one value, see JSONExtractor.cells
+}
+
+// dimensions groups the values of the element by the output columns they vary together
+func (e *JSONExtractor) dimensions(values map[string][]any) []dimension {
+	rawStrings := !e.parser.Options().DecodeStrings
+	var dimensions []dimension
+	var groups []*zipGroup
+	zipped := make(map[string]*zipGroup) // the group of each array ancestor
+	offset := 0
+	for i, c := range e.columns {
+		absolutePath := getAbsolutePath(e.base, c.field)
+		fieldValues := e.columnFieldValues(values, c)
+		width := len(e.columnHeaders(i))
+		if c.mode != explodeArray {
+			var choice []any
+			if e.flattens(c) {
+				// The first or last value is flattened like an exploded one
+				if slot := c.appendSlots(nil, fieldValues, rawStrings)[0]; len(slot) > 0 {
+					choice = e.cells(i, slot[0])
+				} else {
+					choice = slices.Repeat([]any{output.Missing}, width)
+				}
+			} else {
+				for _, slot := range c.appendSlots(nil, columnValues(c, fieldValues), rawStrings) {
+					choice = append(choice, firstOrMissing(slot))
+				}
+			}
+			d := dimension{choices: [][]any{choice}}
+			for j := range choice {
+				d.columns = append(d.columns, offset+j)
+			}
+			dimensions = append(dimensions, d)
+			offset += len(choice)
+			continue
+		}
+
+		var columns []int
+		for j := range width {
+			columns = append(columns, offset+j)
+		}
+		numbered := e.numbered(c)
+		if numbered {
+			columns = append(columns, offset+width)
+		}
+		offset += len(columns)
+		var positions []arrayPosition
+		if e.rows != nil {
+			positions = e.positions[absolutePath]
+		}
+
+		if e.rows != nil && e.rows.Zip && len(positions) > 0 && positions[0].index >= 0 {
+			group, ok := zipped[positions[0].ancestor]
+			if !ok {
+				group = &zipGroup{dimension: len(dimensions)}
+				zipped[positions[0].ancestor] = group
+				groups = append(groups, group)
+				dimensions = append(dimensions, dimension{})
+			}
+			group.columns = append(group.columns, columns...)
+			cells := func(value any) []any { return e.cells(i, value) }
+			group.fields = append(group.fields, zipField{values: fieldValues, positions: positions, numbered: numbered, cells: cells})
+			continue
+		}
+
+		d := dimension{columns: columns}
+		for j, value := range fieldValues {
+			choice := e.cells(i, value)
+			if numbered {
+				choice = append(choice, ordinal(positions, j))
+			}
+			d.choices = append(d.choices, choice)
+		}
+		if len(d.choices) == 0 {
+			// if target field value is empty, we use output.Missing
+			d.choices = [][]any{slices.Repeat([]any{output.Missing}, len(columns))}
+		}
+		dimensions = append(dimensions, d)
+	}
+
+	for _, group := range groups {
+		dimensions[group.dimension] = group.zip()
+	}
+	return dimensions
+}
+
+// zip makes one choice per index of the array, the fields without a value there being empty
+// A field with several values at one index, e.g. from a nested array, multiplies the choices of that index
+func (g *zipGroup) zip() dimension {
+	var indexes []int
+	byIndex := make([]map[int][]any, len(g.fields)) // the values of each field at each index
+	for i, field := range g.fields {
+		byIndex[i] = make(map[int][]any)
+		for j, value := range field.values {
+			index := field.positions[j].index
+			byIndex[i][index] = append(byIndex[i][index], value)
+			indexes = append(indexes, index)
+		}
+	}
+	slices.Sort(indexes)
+	indexes = slices.Compact(indexes)
+
+	d := dimension{columns: g.columns}
+	for _, index := range indexes {
+		choices := [][]any{nil}
+		for i, field := range g.fields {
+			var options [][]any
+			for _, value := range byIndex[i][index] {
+				options = append(options, field.choice(value, int64(index+1)))
+			}
+			if options == nil {
+				options = [][]any{field.choice(output.Missing, output.Missing)}
+			}
+			var product [][]any
+			for _, choice := range choices {
+				for _, option := range options {
+					product = append(product, slices.Concat(choice, option))
+				}
+			}
+			choices = product
+		}
+		d.choices = append(d.choices, choices...)
+	}
+	return d
+}
+
+// choice returns the values of the columns of the field for one value
+func (f zipField) choice(value any, ordinality any) []any {
+	choice := f.cells(value)
+	if f.numbered {
+		choice = append(choice, ordinality)
+	}
+	return choice
+}
+
+// ordinal returns the ordinality of the i-th value of a field: its position in its array from 1,
+// or among the values of the field when it is not in an array
+func ordinal(positions []arrayPosition, i int) any {
+	if i < len(positions) && positions[i].index >= 0 {
+		return int64(positions[i].index + 1)
+	}
+	return int64(i + 1)
+}
+
+// firstOrMissing returns the single value of a slot, output.Missing for a missing value
+func firstOrMissing(slot []any) any {
+	if len(slot) == 0 {
+		return output.Missing
+	}
+	return slot[0]
+}
+
+// combine writes the rows of every combination of the choices of the dimensions
+func (e *JSONExtractor) combine(dimensions []dimension, index int, row []any, written *int) error {
+	if index == len(dimensions) {
+		if e.rows != nil && e.rows.MaxRows > 0 && *written >= e.rows.MaxRows {
+			return errRowLimit
+		}
+		*written++
+		return e.writeRecord(row)
+	}
+
+	d := dimensions[index]
+	for _, choice := range d.choices {
+		for i, column := range d.columns {
+			row[column] = choice[i]
+		}
+		if err := e.combine(dimensions, index+1, row, written); err != nil {
+			return err
+		}
+	}
+	return nil
+}
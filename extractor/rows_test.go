@@ -0,0 +1,132 @@
+package extractor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+)
+
+func TestJSONExtractorRows(t *testing.T) {
+	input := `{"data":[
+		{"id":1,"distribution":[{"mediaType":"csv","downloadURL":"a"},{"downloadURL":"b"},{"mediaType":"json","downloadURL":"c"}],"keyword":["k1","k2"]},
+		{"id":2,"keyword":["k3"]}
+	]}`
+
+	tests := []struct {
+		name     string
+		base     string
+		fields   []string
+		opts     *RowOptions
+		expected string
+	}{
+		{
+			name:   "zip",
+			base:   ".data",
+			fields: []string{"id", "distribution.mediaType", "distribution.downloadURL"},
+			opts:   &RowOptions{Zip: true},
+			expected: "id,distribution.mediaType,distribution.downloadURL\n" +
+				"1,csv,a\n1,,b\n1,json,c\n2,,\n",
+		},
+		{
+			name:   "zip with another array",
+			base:   "/data",
+			fields: []string{"/distribution/downloadURL", "/keyword", "/distribution/mediaType"},
+			opts:   &RowOptions{Zip: true},
+			expected: "/distribution/downloadURL,/keyword,/distribution/mediaType\n" +
+				"a,k1,csv\na,k2,csv\nb,k1,\nb,k2,\nc,k1,json\nc,k2,json\n,k3,\n",
+		},
+		{
+			name:   "ordinality",
+			base:   ".data",
+			fields: []string{"id", "keyword", "distribution.mediaType"},
+			opts:   &RowOptions{Ordinality: []string{"keyword", "distribution.mediaType"}},
+			expected: "id,keyword,keyword_ordinality,distribution.mediaType,distribution.mediaType_ordinality\n" +
+				"1,k1,1,csv,1\n1,k1,1,json,3\n1,k2,2,csv,1\n1,k2,2,json,3\n2,k3,1,,\n",
+		},
+		{
+			name:   "zip with ordinality",
+			base:   "$.data[*]",
+			fields: []string{"distribution.mediaType", "distribution.downloadURL|count", "$.distribution[*].downloadURL"},
+			opts:   &RowOptions{Zip: true, Ordinality: []string{"distribution.mediaType", "$.distribution[*].downloadURL"}},
+			expected: "distribution.mediaType,distribution.mediaType_ordinality,distribution.downloadURL,$.distribution[*].downloadURL,$.distribution[*].downloadURL_ordinality\n" +
+				"csv,1,3,a,1\n,,3,b,2\njson,3,3,c,3\n,,0,,\n",
+		},
+		{
+			name:   "row cap",
+			base:   ".data",
+			fields: []string{"id", "keyword", "distribution.downloadURL"},
+			opts:   &RowOptions{MaxRows: 4},
+			expected: "id,keyword,distribution.downloadURL\n" +
+				"1,k1,a\n1,k1,b\n1,k1,c\n1,k2,a\n2,k3,\n",
+		},
+		{
+			name:   "row cap of JSONPath elements",
+			base:   "$.data[?(@.id)]",
+			fields: []string{"id", "keyword"},
+			opts:   &RowOptions{MaxRows: 1},
+			expected: "id,keyword\n" +
+				"1,k1\n2,k3\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			writer := csv.NewWriter(&output)
+			extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), writer, tt.base, tt.fields)
+			if err != nil {
+				t.Fatalf("NewJSONExtractor() error = %v", err)
+			}
+			if err := extractor.SetRows(tt.opts); err != nil {
+				t.Fatalf("SetRows() error = %v", err)
+			}
+			if err := extractor.Extract(); err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			writer.Flush()
+			if output.String() != tt.expected {
+				t.Errorf("Extract() output = %q, want %q", output.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestJSONExtractorRowsReport(t *testing.T) {
+	input := `{"data":[{"id":1,"tags":["a","b","a"]},{"id":2,"tags":["c"]},{"id":3,"tags":["d","e"]}]}`
+
+	var output bytes.Buffer
+	writer := csv.NewWriter(&output)
+	extractor, err := NewJSONExtractor(bufio.NewReader(strings.NewReader(input)), writer, ".data", []string{"id", "tags"})
+	if err != nil {
+		t.Fatalf("NewJSONExtractor() error = %v", err)
+	}
+	var reported int64 = -1
+	if err := extractor.SetRows(&RowOptions{Ordinality: []string{"tags"}, MaxRows: 2, Report: func(truncated int64) { reported = truncated }}); err != nil {
+		t.Fatalf("SetRows() error = %v", err)
+	}
+	// The dedup columns may be ordinality columns
+	if err := extractor.SetDedup(&DedupOptions{Columns: []string{"tags_ordinality"}}); err != nil {
+		t.Fatalf("SetDedup() error = %v", err)
+	}
+	if err := extractor.Extract(); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	writer.Flush()
+
+	expected := "id,tags,tags_ordinality\n1,a,1\n1,b,2\n"
+	if output.String() != expected {
+		t.Errorf("Extract() output = %q, want %q", output.String(), expected)
+	}
+	if reported != 1 || extractor.Truncated() != 1 {
+		t.Errorf("truncated = %d and %d, want 1", reported, extractor.Truncated())
+	}
+
+	if err := extractor.SetRows(&RowOptions{MaxRows: -1}); err == nil {
+		t.Errorf("SetRows() expected an error for a negative MaxRows")
+	}
+	if err := extractor.SetRows(&RowOptions{Ordinality: []string{"name"}}); err == nil {
+		t.Errorf("SetRows() expected an error for an ordinality field that is not extracted")
+	}
+}
@@ -0,0 +1,152 @@
+package formatter
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bluesky0724/jsonstream/encoder"
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// Style defines the layout of the reformatted document
+type Style int
+
+const (
+	// Minified removes all the whitespace between the tokens
+	Minified Style = iota
+	// Indented writes every element of objects and arrays on its own indented line
+	Indented
+	// ElementPerLine writes each element of an array minified on its own line (NDJSON),
+	// leaving out everything around the array
+	ElementPerLine
+)
+
+// defaultSortBufferSize is the default memory bound of key sorting: 1mb
+const defaultSortBufferSize = 1 << 20
+
+// Options configures Format
+type Options struct {
+	Style Style
+	// Indent is the indentation of the Indented style, two spaces when empty
+	Indent string
+	// Base is the field of the array written one element per line by ElementPerLine,
+	// e.g. ".dataset" or the JSON Pointer "/dataset"; the root value is used when empty
+	Base string
+	// SortKeys writes the members of each object sorted by key
+	SortKeys bool
+	// SortBufferSize bounds the approximate bytes buffered to sort one object, 1mb when zero
+	// An object larger than the bound keeps its original order, the objects inside it are still sorted
+	SortBufferSize int
+	// Parser configures the parser, strings are always decoded and numbers kept as text
+	Parser parser.Options
+}
+
+// Format streams the JSON document from r to w in the given style
+// Without SortKeys the memory used does not depend on the size of the document
+func Format(r io.Reader, w io.Writer, opts Options) error {
+	encoderOptions := encoder.Options{}
+	switch opts.Style {
+	case Indented:
+		encoderOptions.Indent = opts.Indent
+		if encoderOptions.Indent == "" {
+			encoderOptions.Indent = "  "
+		}
+	case ElementPerLine:
+		encoderOptions.MultipleValues = true
+	}
+	e := encoder.NewJSONEncoder(w, encoderOptions)
+
+	p, err := parser.NewParser(r, encoder.PipeOptions(opts.Parser), nil)
+	if err != nil {
+		return err
+	}
+
+	// The events go through the key sorter, if any, before reaching the encoder
+	sink := e.WriteEvent
+	if opts.SortKeys {
+		size := opts.SortBufferSize
+		if size <= 0 {
+			size = defaultSortBufferSize
+		}
+		sink = newKeySorter(e, size).handle
+	}
+	if opts.Style == ElementPerLine {
+		filter, err := newElementFilter(p, opts.Base, sink)
+		if err != nil {
+			return err
+		}
+		sink = filter.handle
+	}
+	p.SetEventHandler(sink)
+
+	if err := p.Parse(); err != nil {
+		return fmt.Errorf("error parsing data: %w", err)
+	}
+	if opts.Style == ElementPerLine {
+		// No line at all is a valid output when the array is empty or missing
+		return e.Flush()
+	}
+	return e.Close()
+}
+
+// elementFilter passes on the events of the elements of the base array only
+type elementFilter struct {
+	parser    *parser.JSONParser
+	base      string
+	pointer   parser.Pointer // the parsed base when it is a JSON Pointer, nil otherwise
+	sink      func(parser.Event) error
+	depth     int  // the nesting level of the current event
+	baseDepth int  // the nesting level inside the base array, 0 outside of it
+	whole     bool // whether the whole document is a single element
+}
+
+func newElementFilter(p *parser.JSONParser, base string, sink func(parser.Event) error) (*elementFilter, error) {
+	filter := &elementFilter{parser: p, base: base, sink: sink}
+	if parser.IsPointer(base) {
+		pointer, err := parser.ParsePointer(base)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base: %w", err)
+		}
+		filter.pointer = pointer
+	}
+	return filter, nil
+}
+
+// atBase checks if the current value is at the base
+func (f *elementFilter) atBase() bool {
+	if f.pointer != nil {
+		return f.pointer.Match(f.parser.Path())
+	}
+	return f.parser.NowField == f.base
+}
+
+// handle forwards the events of the base elements
+// Without a base, a root document that is not an array is written whole as a single line
+func (f *elementFilter) handle(event parser.Event) error {
+	if f.depth == 0 && f.base == "" && event.Kind != parser.ArrayStart {
+		f.whole = true
+	}
+	if f.whole {
+		return f.sink(event)
+	}
+
+	switch event.Kind {
+	case parser.ObjectStart, parser.ArrayStart:
+		f.depth++
+		if f.baseDepth == 0 && event.Kind == parser.ArrayStart && f.atBase() {
+			f.baseDepth = f.depth
+			return nil
+		}
+	case parser.ObjectEnd, parser.ArrayEnd:
+		f.depth--
+		if f.baseDepth > 0 && f.depth < f.baseDepth {
+			f.baseDepth = 0
+			return nil
+		}
+	}
+
+	if f.baseDepth > 0 {
+		return f.sink(event)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package formatter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	input := `{ "b": [1, 2.50, {"y": null, "x": true}],
+	           "a": "text\u00e9", "c": {} }`
+
+	tests := []struct {
+		name     string
+		input    string
+		opts     Options
+		expected string
+	}{
+		{
+			name:     "minified",
+			input:    input,
+			expected: `{"b":[1,2.50,{"y":null,"x":true}],"a":"text` + "\u00e9" + `","c":{}}`,
+		},
+		{
+			name:     "indented",
+			input:    `{"a":[1,{"b":2}],"c":[]}`,
+			opts:     Options{Style: Indented},
+			expected: "{\n  \"a\": [\n    1,\n    {\n      \"b\": 2\n    }\n  ],\n  \"c\": []\n}",
+		},
+		{
+			name:     "custom indentation",
+			input:    `[1]`,
+			opts:     Options{Style: Indented, Indent: "\t"},
+			expected: "[\n\t1\n]",
+		},
+		{
+			name:     "sorted keys",
+			input:    input,
+			opts:     Options{SortKeys: true},
+			expected: `{"a":"text` + "\u00e9" + `","b":[1,2.50,{"x":true,"y":null}],"c":{}}`,
+		},
+		{
+			name:     "element per line at the root",
+			input:    `[{"id": 1}, [2, 3], "four"]`,
+			opts:     Options{Style: ElementPerLine},
+			expected: "{\"id\":1}\n[2,3]\n\"four\"\n",
+		},
+		{
+			name:     "element per line of a nested array",
+			input:    `{"meta": {"n": 2}, "dataset": [{"b": 1, "a": 2}, {"c": [3]}], "after": []}`,
+			opts:     Options{Style: ElementPerLine, Base: ".dataset", SortKeys: true},
+			expected: "{\"a\":2,\"b\":1}\n{\"c\":[3]}\n",
+		},
+		{
+			name:     "element per line of a JSON pointer base",
+			input:    `{"a.b": {"c/d": [1, {"e": 2}]}, "a": {"b": []}}`,
+			opts:     Options{Style: ElementPerLine, Base: "/a.b/c~1d"},
+			expected: "1\n{\"e\":2}\n",
+		},
+		{
+			name:     "element per line of a single object",
+			input:    `{"a": [1, 2]}`,
+			opts:     Options{Style: ElementPerLine},
+			expected: "{\"a\":[1,2]}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			if err := Format(strings.NewReader(tt.input), &output, tt.opts); err != nil {
+				t.Fatalf("Format() error = %v", err)
+			}
+			if output.String() != tt.expected {
+				t.Errorf("Format() = %q, want %q", output.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatSortBufferSize(t *testing.T) {
+	// The outer object is larger than the bound and keeps its order, its small objects are still sorted
+	input := `{"z": {"b": 1, "a": 2}, "long": "` + strings.Repeat("x", 500) + `", "y": {"d": 3, "c": 4}}`
+	expected := `{"z":{"a":2,"b":1},"long":"` + strings.Repeat("x", 500) + `","y":{"c":4,"d":3}}`
+
+	var output bytes.Buffer
+	if err := Format(strings.NewReader(input), &output, Options{SortKeys: true, SortBufferSize: 450}); err != nil {
+		t.Fatalf("Format() error = %v", err)
+	}
+	if output.String() != expected {
+		t.Errorf("Format() = %q, want %q", output.String(), expected)
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	inputs := []string{`{"a": }`, `[1, 2`, ``}
+	for _, input := range inputs {
+		if err := Format(strings.NewReader(input), &bytes.Buffer{}, Options{}); err == nil {
+			t.Errorf("Format(%q) expected an error", input)
+		}
+	}
+}
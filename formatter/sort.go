@@ -0,0 +1,161 @@
+package formatter
+
+import (
+	"sort"
+
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// eventOverhead is the approximate memory taken by a buffered event besides its strings
+const eventOverhead = 48
+
+// keySorter buffers the events of each object until its end to write its members sorted by key
+// Objects inside a buffered object are buffered with it and sorted when it is written
+// When the buffer grows over the limit, the buffered events are written in their original order
+// and the following objects are buffered again from their own start
+type keySorter struct {
+	encoder eventWriter
+	limit   int
+	buffer  []parser.Event
+	size    int // approximate bytes held by buffer
+	depth   int // nesting level inside the buffered object, 0 when nothing is buffered
+}
+
+// eventWriter is the part of encoder.JSONEncoder the sorter writes to
+type eventWriter interface {
+	WriteEvent(parser.Event) error
+	Key(string) error
+}
+
+func newKeySorter(encoder eventWriter, limit int) *keySorter {
+	return &keySorter{encoder: encoder, limit: limit}
+}
+
+// handle buffers or forwards an event
+func (s *keySorter) handle(event parser.Event) error {
+	if s.depth == 0 && event.Kind != parser.ObjectStart {
+		return s.encoder.WriteEvent(event)
+	}
+
+	s.buffer = append(s.buffer, event)
+	s.size += eventSize(event)
+	switch event.Kind {
+	case parser.ObjectStart, parser.ArrayStart:
+		s.depth++
+	case parser.ObjectEnd, parser.ArrayEnd:
+		s.depth--
+	}
+
+	if s.depth == 0 {
+		// The buffered object is complete
+		return s.flush()
+	}
+	if s.size > s.limit {
+		// Too large to sort: write what is buffered, the rest of the object streams through
+		s.depth = 0
+		return s.flush()
+	}
+	return nil
+}
+
+// flush writes the buffered events, sorting the complete objects among them
+func (s *keySorter) flush() error {
+	events := s.buffer
+	s.buffer, s.size = s.buffer[:0], 0
+	return s.write(events)
+}
+
+// write writes a sequence of events, sorting the members of the complete objects among them
+// The start of an object cut by the end of the sequence is written as it is
+func (s *keySorter) write(events []parser.Event) error {
+	i := 0
+	for i < len(events) {
+		event := events[i]
+		switch event.Kind {
+		case parser.ObjectStart:
+			end := matchingEnd(events, i)
+			if end < 0 {
+				// The object is cut by the end of the buffer: keep its order
+				if err := s.encoder.WriteEvent(event); err != nil {
+					return err
+				}
+				i++
+				continue
+			}
+			if err := s.writeSorted(events[i : end+1]); err != nil {
+				return err
+			}
+			i = end + 1
+		default:
+			if err := s.encoder.WriteEvent(event); err != nil {
+				return err
+			}
+			i++
+		}
+	}
+	return nil
+}
+
+// member is a key of an object with the events of its value
+type member struct {
+	key    string
+	events []parser.Event
+}
+
+// writeSorted writes a complete object, from its start to its end event, with sorted members
+func (s *keySorter) writeSorted(events []parser.Event) error {
+	var members []member
+	inner := events[1 : len(events)-1]
+	for i := 0; i < len(inner); {
+		// inner[i] is a Key event followed by the events of one value
+		end := i + 1
+		if kind := inner[end].Kind; kind == parser.ObjectStart || kind == parser.ArrayStart {
+			end = matchingEnd(inner, end)
+		}
+		members = append(members, member{key: inner[i].Key, events: inner[i+1 : end+1]})
+		i = end + 1
+	}
+	sort.SliceStable(members, func(a, b int) bool { return members[a].key < members[b].key })
+
+	if err := s.encoder.WriteEvent(events[0]); err != nil {
+		return err
+	}
+	for _, m := range members {
+		if err := s.encoder.Key(m.key); err != nil {
+			return err
+		}
+		if err := s.write(m.events); err != nil {
+			return err
+		}
+	}
+	return s.encoder.WriteEvent(events[len(events)-1])
+}
+
+// matchingEnd returns the index of the end event of the container starting at start, -1 if it is not in events
+func matchingEnd(events []parser.Event, start int) int {
+	depth := 0
+	for i := start; i < len(events); i++ {
+		switch events[i].Kind {
+		case parser.ObjectStart, parser.ArrayStart:
+			depth++
+		case parser.ObjectEnd, parser.ArrayEnd:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// eventSize approximates the memory taken by a buffered event
+func eventSize(event parser.Event) int {
+	size := eventOverhead + len(event.Key)
+	switch value := event.Value.(type) {
+	case string:
+		size += len(value)
+	case interface{ String() string }:
+		size += len(value.String())
+	}
+	return size
+}
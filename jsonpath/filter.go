@@ -0,0 +1,300 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// filter is a compiled filter expression
+// The grammar is:
+//
+//	expression := and ('||' and)*
+//	and        := unary ('&&' unary)*
+//	unary      := '!' unary | '(' expression ')' | operand [('==' | '!=' | '<' | '<=' | '>' | '>=') operand]
+//	operand    := '@' followed by child names and indexes | 'string' | "string" | number | true | false | null
+//
+// An operand alone tests that the member exists, e.g. [?(@.downloadURL)]
+type filter struct {
+	root     expression
+	operands [][]parser.PathSegment // the relative paths of the '@' operands, recorded by the Matcher
+}
+
+// expression is a node of a filter expression
+type expression interface {
+	eval(values []any, found []bool) bool
+}
+
+// containerValue stands for an object or array operand, which only supports existence tests
+type containerValue struct{}
+
+type orExpression struct{ left, right expression }
+type andExpression struct{ left, right expression }
+type notExpression struct{ operand expression }
+type existsExpression struct{ operand operand }
+type compareExpression struct {
+	op          string
+	left, right operand
+}
+
+// operand is a literal or an '@' path, identified by its index in filter.operands
+type operand struct {
+	isPath  bool
+	id      int
+	literal any
+}
+
+// value returns the value of the operand and whether it exists
+func (o operand) value(values []any, found []bool) (any, bool) {
+	if o.isPath {
+		return values[o.id], found[o.id]
+	}
+	return o.literal, true
+}
+
+func (e orExpression) eval(values []any, found []bool) bool {
+	return e.left.eval(values, found) || e.right.eval(values, found)
+}
+
+func (e andExpression) eval(values []any, found []bool) bool {
+	return e.left.eval(values, found) && e.right.eval(values, found)
+}
+
+func (e notExpression) eval(values []any, found []bool) bool {
+	return !e.operand.eval(values, found)
+}
+
+func (e existsExpression) eval(values []any, found []bool) bool {
+	_, exists := e.operand.value(values, found)
+	return exists
+}
+
+func (e compareExpression) eval(values []any, found []bool) bool {
+	left, leftExists := e.left.value(values, found)
+	right, rightExists := e.right.value(values, found)
+	if !leftExists || !rightExists {
+		// A missing member only equals another missing member
+		equal := leftExists == rightExists
+		switch e.op {
+		case "==", "<=", ">=":
+			return equal
+		case "!=":
+			return !equal
+		}
+		return false
+	}
+
+	order, comparable := compareValues(left, right)
+	switch e.op {
+	case "==":
+		return comparable && order == 0
+	case "!=":
+		return !comparable || order != 0
+	case "<":
+		return comparable && order < 0
+	case "<=":
+		return comparable && order <= 0
+	case ">":
+		return comparable && order > 0
+	case ">=":
+		return comparable && order >= 0
+	}
+	return false
+}
+
+// compareValues orders two values of the same type, numbers of any NumberMode compare numerically
+// Booleans and nulls are only comparable when equal, objects and arrays never are
+func compareValues(left any, right any) (int, bool) {
+	if leftNumber, ok := toFloat(left); ok {
+		rightNumber, ok := toFloat(right)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case leftNumber < rightNumber:
+			return -1, true
+		case leftNumber > rightNumber:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	switch leftValue := left.(type) {
+	case string:
+		if rightValue, ok := right.(string); ok {
+			return strings.Compare(leftValue, rightValue), true
+		}
+	case bool:
+		if rightValue, ok := right.(bool); ok && leftValue == rightValue {
+			return 0, true
+		}
+	case nil:
+		if right == nil {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// toFloat converts the numbers of the parser to float64
+func toFloat(value any) (float64, bool) {
+	switch number := value.(type) {
+	case float64:
+		return number, true
+	case int64:
+		return float64(number), true
+	case json.Number:
+		f, err := number.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// compileFilter parses the filter expression following '?'
+func (c *compiler) compileFilter() (*filter, error) {
+	f := &filter{}
+	root, err := c.compileOr(f)
+	if err != nil {
+		return nil, err
+	}
+	f.root = root
+	return f, nil
+}
+
+func (c *compiler) compileOr(f *filter) (expression, error) {
+	left, err := c.compileAnd(f)
+	if err != nil {
+		return nil, err
+	}
+	for c.skipSpaces(); c.consume("||"); c.skipSpaces() {
+		right, err := c.compileAnd(f)
+		if err != nil {
+			return nil, err
+		}
+		left = orExpression{left, right}
+	}
+	return left, nil
+}
+
+func (c *compiler) compileAnd(f *filter) (expression, error) {
+	left, err := c.compileUnary(f)
+	if err != nil {
+		return nil, err
+	}
+	for c.skipSpaces(); c.consume("&&"); c.skipSpaces() {
+		right, err := c.compileUnary(f)
+		if err != nil {
+			return nil, err
+		}
+		left = andExpression{left, right}
+	}
+	return left, nil
+}
+
+func (c *compiler) compileUnary(f *filter) (expression, error) {
+	c.skipSpaces()
+	if c.peek() == '!' && !strings.HasPrefix(c.input[c.pos:], "!=") {
+		c.pos++
+		operand, err := c.compileUnary(f)
+		if err != nil {
+			return nil, err
+		}
+		return notExpression{operand}, nil
+	}
+	if c.consume("(") {
+		inner, err := c.compileOr(f)
+		if err != nil {
+			return nil, err
+		}
+		c.skipSpaces()
+		if !c.consume(")") {
+			return nil, fmt.Errorf("expected ')' at position %d", c.pos)
+		}
+		return inner, nil
+	}
+
+	left, err := c.compileOperand(f)
+	if err != nil {
+		return nil, err
+	}
+	c.skipSpaces()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if c.consume(op) {
+			c.skipSpaces()
+			right, err := c.compileOperand(f)
+			if err != nil {
+				return nil, err
+			}
+			return compareExpression{op: op, left: left, right: right}, nil
+		}
+	}
+	if !left.isPath {
+		return nil, fmt.Errorf("expected a comparison after the literal at position %d", c.pos)
+	}
+	return existsExpression{left}, nil
+}
+
+// compileOperand parses an '@' path or a literal
+func (c *compiler) compileOperand(f *filter) (operand, error) {
+	switch char := c.peek(); {
+	case char == '@':
+		c.pos++
+		steps, err := c.compileSegments()
+		if err != nil {
+			return operand{}, err
+		}
+		path, err := operandPath(steps)
+		if err != nil {
+			return operand{}, err
+		}
+		// The same path used twice is recorded once
+		for id, existing := range f.operands {
+			if equalPath(existing, path) {
+				return operand{isPath: true, id: id}, nil
+			}
+		}
+		f.operands = append(f.operands, path)
+		return operand{isPath: true, id: len(f.operands) - 1}, nil
+	case char == '\'' || char == '"':
+		text, err := c.compileQuoted()
+		return operand{literal: text}, err
+	case c.consume("true"):
+		return operand{literal: true}, nil
+	case c.consume("false"):
+		return operand{literal: false}, nil
+	case c.consume("null"):
+		return operand{literal: nil}, nil
+	}
+
+	start := c.pos
+	for c.pos < len(c.input) && strings.ContainsRune("+-.0123456789eE", rune(c.input[c.pos])) {
+		c.pos++
+	}
+	number, err := strconv.ParseFloat(c.input[start:c.pos], 64)
+	if err != nil {
+		return operand{}, fmt.Errorf("expected an operand at position %d", start)
+	}
+	return operand{literal: number}, nil
+}
+
+// operandPath converts the steps of an '@' operand, which may only hold child names and indexes
+func operandPath(steps []step) ([]parser.PathSegment, error) {
+	path := make([]parser.PathSegment, len(steps))
+	for i, s := range steps {
+		if s.descendant || len(s.selectors) != 1 {
+			return nil, fmt.Errorf("filter operands only support child names and indexes")
+		}
+		switch selector := s.selectors[0]; selector.kind {
+		case nameSelector:
+			path[i] = parser.PathSegment{Key: selector.name, Index: -1}
+		case indexSelector:
+			path[i] = parser.PathSegment{Index: selector.index}
+		default:
+			return nil, fmt.Errorf("filter operands only support child names and indexes")
+		}
+	}
+	return path, nil
+}
@@ -0,0 +1,52 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestFilterEval(t *testing.T) {
+	// The operands are @.a and @.b, in this order, for every expression
+	tests := []struct {
+		expression string
+		a, b       any
+		foundA     bool
+		foundB     bool
+		expected   bool
+	}{
+		{"@.a == 'x'", "x", nil, true, false, true},
+		{"@.a == 'x'", "y", nil, true, false, false},
+		{"@.a == 'x'", nil, nil, false, false, false},
+		{"@.a != 'x'", nil, nil, false, false, true},
+		{"@.a > 10", 11.0, nil, true, false, true},
+		{"@.a > 10", json.Number("9.5"), nil, true, false, false},
+		{"@.a <= 10", int64(10), nil, true, false, true},
+		{"@.a < 'b'", "a", nil, true, false, true},
+		{"@.a < 1", "a", nil, true, false, false},
+		{"@.a == true", true, nil, true, false, true},
+		{"@.a == null", nil, nil, true, false, true},
+		{"@.a == @.b", nil, nil, false, false, true},
+		{"@.a", nil, nil, true, false, true},
+		{"!@.a", nil, nil, false, false, true},
+		{"@.a == 1 && @.b == 2", 1.0, 2.0, true, true, true},
+		{"@.a == 1 && @.b == 2", 1.0, 3.0, true, true, false},
+		{"@.a == 1 || @.b == 2", 0.0, 2.0, true, true, true},
+		{"(@.a == 1 || @.a == 2) && !(@.b)", 2.0, nil, true, false, true},
+		{"@.a == 'x'", containerValue{}, nil, true, false, false},
+		{"@.a != 'x'", containerValue{}, nil, true, false, true},
+	}
+
+	for _, tt := range tests {
+		c := &compiler{input: tt.expression}
+		f, err := c.compileFilter()
+		if err != nil {
+			t.Errorf("compileFilter(%q) error = %v", tt.expression, err)
+			continue
+		}
+		values := []any{tt.a, tt.b}
+		found := []bool{tt.foundA, tt.foundB}
+		if result := f.root.eval(values[:len(f.operands)], found[:len(f.operands)]); result != tt.expected {
+			t.Errorf("%q with a=%v b=%v = %v, want %v", tt.expression, tt.a, tt.b, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package jsonpath
+
+import "github.com/bluesky0724/jsonstream/parser"
+
+// Matcher evaluates a Path over the calls of a parser's parse handler
+// It only relies on what the parse handler sees, the path from JSONParser.Path, the kind of call
+// from JSONParser.Ending and the value, so it can run next to any other use of the parser
+// Matches are decided at the end of the matched value; when a filter on the way to the value
+// is not decided yet, e.g. [?(@.accessLevel=='public')] on an element whose accessLevel comes
+// after the matched field, the match is held until the filtered value ends
+type Matcher struct {
+	path   *Path
+	emit   func(payload any) error
+	frames []matchFrame // frames[i] is the node at the first i segments of the current path
+	guards []*guard     // the filters not decided yet, in the order they were opened
+
+	// ExpandArrays also matches the elements of matched arrays, recursively,
+	// like the dotted fields of JSONExtractor which see through arrays
+	ExpandArrays bool
+
+	// capture builds the matched values for Stream
+	capture bool
+}
+
+// matchFrame is a node on the current path
+type matchFrame struct {
+	segment parser.PathSegment // the segment leading to the node, unused for the root
+	states  []matchState       // the positions reached in the path by the node
+	guards  []*guard           // the filters applied to the node
+	builder *valueBuilder      // the value of the node when it may match, in capture mode
+}
+
+// matchState is a position in the path reached by a node: the number of matched steps,
+// and the innermost filter the route to the node went through, nil when there is none
+type matchState struct {
+	step  int
+	guard *guard
+}
+
+// guardResult is the decision of a filter for one node
+type guardResult int
+
+const (
+	guardPending guardResult = iota
+	guardPassed
+	guardFailed
+)
+
+// guard is a filter applied to one node, decided when the node ends
+type guard struct {
+	filter *filter
+	depth  int    // the length of the path of the node
+	parent *guard // the filter the route went through before this one
+	values []any  // the values of the filter operands
+	found  []bool // whether the filter operands were found
+	result guardResult
+	queue  []*pendingMatch // the matches waiting for the decision
+}
+
+// pendingMatch is a match waiting for filters, shared by its routes so it is emitted once
+type pendingMatch struct {
+	payload any
+	done    bool
+}
+
+// NewMatcher creates a Matcher calling emit with the payload of each match once it is decided
+func NewMatcher(path *Path, emit func(payload any) error) *Matcher {
+	return &Matcher{path: path, emit: emit}
+}
+
+// Reset forgets the current position and the pending matches, e.g. to match the next base element
+func (m *Matcher) Reset() {
+	m.frames = m.frames[:0]
+	m.guards = m.guards[:0]
+}
+
+// Enter moves the matcher to the value at path, without handling the value itself
+// It returns the length of the path of the outermost node of path that may match, or -1 if there is none
+func (m *Matcher) Enter(path []parser.PathSegment) int {
+	if len(m.frames) == 0 {
+		m.frames = append(m.frames, m.newFrame(parser.PathSegment{}, []matchState{{}}))
+	}
+
+	// Keep the frames shared with the previous path, then open the new ones
+	shared := 1
+	for shared < len(m.frames) && shared <= len(path) && m.frames[shared].segment == path[shared-1] {
+		shared++
+	}
+	m.frames = m.frames[:shared]
+	for depth := shared; depth <= len(path); depth++ {
+		m.frames = append(m.frames, m.childFrame(&m.frames[depth-1], path[depth-1], depth))
+	}
+
+	for depth := range m.frames {
+		if m.isCandidate(&m.frames[depth]) {
+			return depth
+		}
+	}
+	return -1
+}
+
+// Handle processes one call of the parse handler: a value, or the end of an object or array, at path
+// When it matches, payload builds what is emitted, it is called at once even if the match is held
+func (m *Matcher) Handle(path []parser.PathSegment, ending parser.EventKind, value any, payload func() any) error {
+	m.Enter(path)
+	depth := len(path)
+	node := &m.frames[depth]
+
+	// Give the filters on the way the values of their operands
+	for _, g := range m.guards {
+		g.record(path[g.depth:], ending, value)
+	}
+	if m.capture {
+		for i := range m.frames {
+			if builder := m.frames[i].builder; builder != nil {
+				builder.add(path[i:], ending, value)
+			}
+		}
+	}
+
+	// The node ends here: decide its filters, then whether it matches
+	for _, g := range node.guards {
+		if err := m.resolve(g); err != nil {
+			return err
+		}
+	}
+	err := m.match(node, path, payload)
+	m.frames = m.frames[:depth]
+	return err
+}
+
+// match emits or holds the node if one of its routes reached the end of the path
+func (m *Matcher) match(node *matchFrame, path []parser.PathSegment, payload func() any) error {
+	var pending []*guard
+	for _, state := range node.states {
+		if state.step != len(m.path.steps) {
+			continue
+		}
+		g, alive := effectiveGuard(state.guard)
+		if !alive {
+			continue
+		}
+		if g == nil {
+			// One route needs no more decision
+			return m.emit(m.payload(node, path, payload))
+		}
+		pending = appendUnique(pending, g)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	held := &pendingMatch{payload: m.payload(node, path, payload)}
+	for _, g := range pending {
+		g.queue = append(g.queue, held)
+	}
+	return nil
+}
+
+// payload builds what is emitted for a match
+func (m *Matcher) payload(node *matchFrame, path []parser.PathSegment, payload func() any) any {
+	if m.capture {
+		return Match{Pointer: pointerOf(path), Value: node.builder.value}
+	}
+	if payload == nil {
+		return nil
+	}
+	return payload()
+}
+
+// resolve decides a filter at the end of its node and passes on the matches it held
+func (m *Matcher) resolve(g *guard) error {
+	for i, active := range m.guards {
+		if active == g {
+			m.guards = append(m.guards[:i], m.guards[i+1:]...)
+			break
+		}
+	}
+
+	if !g.filter.root.eval(g.values, g.found) {
+		g.result, g.queue = guardFailed, nil
+		return nil
+	}
+	g.result = guardPassed
+	queue := g.queue
+	g.queue = nil
+
+	parent, alive := effectiveGuard(g.parent)
+	for _, held := range queue {
+		if held.done || !alive {
+			continue
+		}
+		if parent != nil {
+			parent.queue = append(parent.queue, held)
+			continue
+		}
+		held.done = true
+		if err := m.emit(held.payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// effectiveGuard returns the innermost undecided filter of a route, and whether no filter of it failed
+func effectiveGuard(g *guard) (*guard, bool) {
+	for g != nil {
+		switch g.result {
+		case guardFailed:
+			return nil, false
+		case guardPending:
+			return g, true
+		}
+		g = g.parent
+	}
+	return nil, true
+}
+
+// childFrame computes the states of a child node from the states of its parent
+func (m *Matcher) childFrame(parent *matchFrame, segment parser.PathSegment, depth int) matchFrame {
+	steps := m.path.steps
+	var states []matchState
+	var guards []*guard
+	for _, state := range parent.states {
+		if state.step == len(steps) {
+			if m.ExpandArrays && segment.Index >= 0 {
+				states = appendState(states, state)
+			}
+			continue
+		}
+		s := &steps[state.step]
+		if s.descendant {
+			states = appendState(states, state) // keep looking deeper
+		}
+		for i := range s.selectors {
+			selector := &s.selectors[i]
+			if !selector.matches(segment) {
+				continue
+			}
+			next := matchState{step: state.step + 1, guard: state.guard}
+			if selector.kind == filterSelector {
+				next.guard = m.newGuard(selector.filter, depth, state.guard)
+				guards = append(guards, next.guard)
+			}
+			states = appendState(states, next)
+		}
+	}
+
+	frame := m.newFrame(segment, states)
+	frame.guards = guards
+	return frame
+}
+
+// newFrame creates a frame, with a value builder when the node may match in capture mode
+func (m *Matcher) newFrame(segment parser.PathSegment, states []matchState) matchFrame {
+	frame := matchFrame{segment: segment, states: states}
+	if m.capture && m.isCandidate(&frame) {
+		frame.builder = &valueBuilder{}
+	}
+	return frame
+}
+
+// isCandidate checks if a node reached the end of the path by a route that did not fail yet
+func (m *Matcher) isCandidate(frame *matchFrame) bool {
+	for _, state := range frame.states {
+		if state.step != len(m.path.steps) {
+			continue
+		}
+		if _, alive := effectiveGuard(state.guard); alive {
+			return true
+		}
+	}
+	return false
+}
+
+// newGuard opens a filter on the node at the given depth
+func (m *Matcher) newGuard(f *filter, depth int, parent *guard) *guard {
+	g := &guard{
+		filter: f,
+		depth:  depth,
+		parent: parent,
+		values: make([]any, len(f.operands)),
+		found:  make([]bool, len(f.operands)),
+	}
+	m.guards = append(m.guards, g)
+	return g
+}
+
+// record keeps the first value found at the path of each operand, relative to the filtered node
+func (g *guard) record(relative []parser.PathSegment, ending parser.EventKind, value any) {
+	for id, operandPath := range g.filter.operands {
+		if g.found[id] || !equalPath(operandPath, relative) {
+			continue
+		}
+		g.found[id] = true
+		if ending == parser.Value {
+			g.values[id] = value
+		} else {
+			g.values[id] = containerValue{}
+		}
+	}
+}
+
+// equalPath compares two paths segment by segment
+func equalPath(a []parser.PathSegment, b []parser.PathSegment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendState adds a state unless it is already there
+func appendState(states []matchState, state matchState) []matchState {
+	for _, existing := range states {
+		if existing == state {
+			return states
+		}
+	}
+	return append(states, state)
+}
+
+// appendUnique adds a guard unless it is already there
+func appendUnique(guards []*guard, g *guard) []*guard {
+	for _, existing := range guards {
+		if existing == g {
+			return guards
+		}
+	}
+	return append(guards, g)
+}
@@ -0,0 +1,93 @@
+package jsonpath
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// matchPointers runs a Matcher over the input and returns the pointers of the matches
+func matchPointers(t *testing.T, input string, expression string, expand bool) []string {
+	t.Helper()
+	var results []string
+	matcher := NewMatcher(MustCompile(expression), func(payload any) error {
+		results = append(results, payload.(string))
+		return nil
+	})
+	matcher.ExpandArrays = expand
+
+	var p *parser.JSONParser
+	p, err := parser.NewParser(strings.NewReader(input), parser.Options{}, func(value any) error {
+		pointer := p.Pointer()
+		return matcher.Handle(p.Path(), p.Ending(), value, func() any { return pointer })
+	})
+	if err != nil {
+		t.Fatalf("NewParser() error = %v", err)
+	}
+	if err := p.Parse(); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return results
+}
+
+func TestMatcher(t *testing.T) {
+	input := `{"a": {"b": [1, [2, 3]], "c": {"b": 4}}, "b": 5}`
+
+	tests := []struct {
+		expression string
+		expand     bool
+		expected   []string
+	}{
+		{"$.a.b", false, []string{"/a/b"}},
+		{"$.a.b", true, []string{"/a/b/0", "/a/b/1/0", "/a/b/1/1", "/a/b/1", "/a/b"}},
+		{"$..b", false, []string{"/a/b", "/a/c/b", "/b"}},
+		{"$.a.*", false, []string{"/a/b", "/a/c"}},
+		{"$.a[?(@.b == 4)]", false, []string{"/a/c"}},
+		{"$[?(@.c)].b", false, []string{"/a/b"}},
+	}
+
+	for _, tt := range tests {
+		results := matchPointers(t, input, tt.expression, tt.expand)
+		if !reflect.DeepEqual(results, tt.expected) {
+			t.Errorf("%s (expand %v) = %q, want %q", tt.expression, tt.expand, results, tt.expected)
+		}
+	}
+}
+
+func TestMatcherNestedFilters(t *testing.T) {
+	// The value is held by the inner filter, then by the outer one, and emitted when both passed
+	input := `{"x": [
+		{"items": [{"v": 1, "ok": true}, {"v": 2, "ok": false}], "keep": true},
+		{"items": [{"v": 3, "ok": true}], "keep": false}
+	]}`
+
+	results := matchPointers(t, input, "$.x[?(@.keep == true)].items[?(@.ok)].v", false)
+	expected := []string{"/x/0/items/0/v", "/x/0/items/1/v"}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("nested filters = %q, want %q", results, expected)
+	}
+
+	results = matchPointers(t, input, "$.x[?(@.keep == true)].items[?(@.ok == true)].v", false)
+	expected = []string{"/x/0/items/0/v"}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("nested filters = %q, want %q", results, expected)
+	}
+}
+
+func TestMatcherEnter(t *testing.T) {
+	matcher := NewMatcher(MustCompile("$.a[*]"), func(any) error { return nil })
+	key := func(k string) parser.PathSegment { return parser.PathSegment{Key: k, Index: -1} }
+
+	if depth := matcher.Enter([]parser.PathSegment{key("a")}); depth != -1 {
+		t.Errorf("Enter(/a) = %d, want -1", depth)
+	}
+	if depth := matcher.Enter([]parser.PathSegment{key("a"), {Index: 0}, key("b")}); depth != 2 {
+		t.Errorf("Enter(/a/0/b) = %d, want 2", depth)
+	}
+	matcher.Reset()
+	if depth := matcher.Enter([]parser.PathSegment{key("b"), {Index: 0}}); depth != -1 {
+		t.Errorf("Enter(/b/0) = %d, want -1", depth)
+	}
+}
@@ -0,0 +1,336 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// Path is a compiled JSONPath expression
+// The supported subset is the one that can be evaluated while streaming:
+//   - the root "$", or "@" for paths relative to a base element
+//   - child names: .name, ['name'], ["name"] and unions like ['a','b']
+//   - wildcards: .* and [*]
+//   - recursive descent: ..name, ..* and ..[...]
+//   - indexes and slices: [0], [0,2], [0:100], [10:], [::2], without negative values
+//   - filters: [?(@.accessLevel=='public')] or [?@.size > 10], see the filter grammar in filter.go
+type Path struct {
+	expression string
+	steps      []step
+}
+
+// step is one segment of the path: the selectors applied to the children of the current nodes
+type step struct {
+	descendant bool       // whether the selectors apply to all the descendants, for ".."
+	selectors  []selector // the union of the selectors, a child matches if one of them does
+}
+
+// selectorKind defines what a selector matches
+type selectorKind int
+
+const (
+	nameSelector     selectorKind = iota // an object member by key
+	indexSelector                        // an array element by position
+	sliceSelector                        // array elements in a range of positions
+	wildcardSelector                     // every member or element
+	filterSelector                       // every member or element for which the filter holds
+)
+
+// selector matches the children of a node
+type selector struct {
+	kind   selectorKind
+	name   string
+	index  int // the position of indexSelector, the start of sliceSelector
+	end    int // the end of sliceSelector, excluded, -1 for no end
+	stride int // the step of sliceSelector
+	filter *filter
+}
+
+// IsPath reports whether a base or field is written as a JSONPath expression, e.g. "$.dataset[*]" or "@.title"
+// Keys starting with '$' or '@' like "@type" stay dotted fields
+func IsPath(expression string) bool {
+	if expression == "$" || expression == "@" {
+		return true
+	}
+	if len(expression) < 2 || (expression[0] != '$' && expression[0] != '@') {
+		return false
+	}
+	return expression[1] == '.' || expression[1] == '['
+}
+
+// Compile parses a JSONPath expression
+func Compile(expression string) (*Path, error) {
+	c := &compiler{input: expression}
+	steps, err := c.compilePath()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath %q: %w", expression, err)
+	}
+	return &Path{expression: expression, steps: steps}, nil
+}
+
+// MustCompile is like Compile but panics when the expression is invalid
+func MustCompile(expression string) *Path {
+	path, err := Compile(expression)
+	if err != nil {
+		panic(err)
+	}
+	return path
+}
+
+// String returns the expression the path was compiled from
+func (p *Path) String() string {
+	return p.expression
+}
+
+// compiler reads an expression from left to right
+type compiler struct {
+	input string
+	pos   int
+}
+
+// compilePath parses the whole expression
+func (c *compiler) compilePath() ([]step, error) {
+	if !c.consume("$") && !c.consume("@") {
+		return nil, fmt.Errorf("must start with '$' or '@'")
+	}
+	steps, err := c.compileSegments()
+	if err != nil {
+		return nil, err
+	}
+	if c.pos < len(c.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d", c.input[c.pos], c.pos)
+	}
+	return steps, nil
+}
+
+// compileSegments parses the segments following a root
+func (c *compiler) compileSegments() ([]step, error) {
+	var steps []step
+	for c.pos < len(c.input) {
+		var s step
+		switch {
+		case c.consume(".."):
+			s.descendant = true
+			if c.peek() == '[' {
+				selectors, err := c.compileBracket()
+				if err != nil {
+					return nil, err
+				}
+				s.selectors = selectors
+			} else {
+				dotted, err := c.compileDotted()
+				if err != nil {
+					return nil, err
+				}
+				s.selectors = []selector{dotted}
+			}
+		case c.consume("."):
+			dotted, err := c.compileDotted()
+			if err != nil {
+				return nil, err
+			}
+			s.selectors = []selector{dotted}
+		case c.peek() == '[':
+			selectors, err := c.compileBracket()
+			if err != nil {
+				return nil, err
+			}
+			s.selectors = selectors
+		default:
+			// The end of a relative path inside a filter
+			return steps, nil
+		}
+		steps = append(steps, s)
+	}
+	return steps, nil
+}
+
+// compileDotted parses the name or '*' following a '.'
+func (c *compiler) compileDotted() (selector, error) {
+	if c.consume("*") {
+		return selector{kind: wildcardSelector}, nil
+	}
+	start := c.pos
+	for c.pos < len(c.input) && !strings.ContainsRune(".[]()=!<>&|,' \t\"", rune(c.input[c.pos])) {
+		c.pos++
+	}
+	if c.pos == start {
+		return selector{}, fmt.Errorf("expected a name at position %d", start)
+	}
+	return selector{kind: nameSelector, name: c.input[start:c.pos]}, nil
+}
+
+// compileBracket parses a bracketed selector list, e.g. ['a','b'], [0:10], [*] or [?(...)]
+func (c *compiler) compileBracket() ([]selector, error) {
+	c.consume("[")
+	c.skipSpaces()
+
+	var selectors []selector
+	switch {
+	case c.consume("*"):
+		selectors = []selector{{kind: wildcardSelector}}
+	case c.consume("?"):
+		filter, err := c.compileFilter()
+		if err != nil {
+			return nil, err
+		}
+		selectors = []selector{{kind: filterSelector, filter: filter}}
+	default:
+		for {
+			c.skipSpaces()
+			item, err := c.compileUnionItem()
+			if err != nil {
+				return nil, err
+			}
+			selectors = append(selectors, item)
+			c.skipSpaces()
+			if !c.consume(",") {
+				break
+			}
+		}
+	}
+
+	c.skipSpaces()
+	if !c.consume("]") {
+		return nil, fmt.Errorf("expected ']' at position %d", c.pos)
+	}
+	return selectors, nil
+}
+
+// compileUnionItem parses a quoted name, an index or a slice
+func (c *compiler) compileUnionItem() (selector, error) {
+	if c.peek() == '\'' || c.peek() == '"' {
+		name, err := c.compileQuoted()
+		if err != nil {
+			return selector{}, err
+		}
+		return selector{kind: nameSelector, name: name}, nil
+	}
+
+	// An index, or a slice [start:end:step] where each part is optional
+	var parts [3]int
+	var present [3]bool
+	colons := 0
+	for {
+		c.skipSpaces()
+		if number, ok, err := c.compileInteger(); err != nil {
+			return selector{}, err
+		} else if ok {
+			parts[colons], present[colons] = number, true
+		}
+		c.skipSpaces()
+		if colons == 2 || !c.consume(":") {
+			break
+		}
+		colons++
+	}
+
+	if colons == 0 {
+		if !present[0] {
+			return selector{}, fmt.Errorf("expected a name, an index or a slice at position %d", c.pos)
+		}
+		return selector{kind: indexSelector, index: parts[0]}, nil
+	}
+	s := selector{kind: sliceSelector, index: parts[0], end: -1, stride: 1}
+	if present[1] {
+		s.end = parts[1]
+	}
+	if present[2] {
+		if parts[2] == 0 {
+			return selector{}, fmt.Errorf("slice step must not be 0")
+		}
+		s.stride = parts[2]
+	}
+	return s, nil
+}
+
+// compileInteger parses an array position, negative ones need the array length and can not be streamed
+func (c *compiler) compileInteger() (int, bool, error) {
+	start := c.pos
+	if c.peek() == '-' {
+		return 0, false, fmt.Errorf("negative indexes and steps are not supported while streaming, at position %d", start)
+	}
+	for c.pos < len(c.input) && c.input[c.pos] >= '0' && c.input[c.pos] <= '9' {
+		c.pos++
+	}
+	if c.pos == start {
+		return 0, false, nil
+	}
+	number, err := strconv.Atoi(c.input[start:c.pos])
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid index %q: %w", c.input[start:c.pos], err)
+	}
+	return number, true, nil
+}
+
+// compileQuoted parses a string in single or double quotes, with backslash escapes
+func (c *compiler) compileQuoted() (string, error) {
+	quote := c.input[c.pos]
+	c.pos++
+	var builder strings.Builder
+	for c.pos < len(c.input) {
+		char := c.input[c.pos]
+		switch {
+		case char == quote:
+			c.pos++
+			return builder.String(), nil
+		case char == '\\' && c.pos+1 < len(c.input):
+			c.pos++
+			switch escaped := c.input[c.pos]; escaped {
+			case 'n':
+				builder.WriteByte('\n')
+			case 't':
+				builder.WriteByte('\t')
+			default:
+				builder.WriteByte(escaped)
+			}
+		default:
+			builder.WriteByte(char)
+		}
+		c.pos++
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// consume moves past the token if the input continues with it
+func (c *compiler) consume(token string) bool {
+	if strings.HasPrefix(c.input[c.pos:], token) {
+		c.pos += len(token)
+		return true
+	}
+	return false
+}
+
+// peek returns the next character, 0 at the end of the input
+func (c *compiler) peek() byte {
+	if c.pos < len(c.input) {
+		return c.input[c.pos]
+	}
+	return 0
+}
+
+// skipSpaces moves past spaces and tabs
+func (c *compiler) skipSpaces() {
+	for c.pos < len(c.input) && (c.input[c.pos] == ' ' || c.input[c.pos] == '\t') {
+		c.pos++
+	}
+}
+
+// matches checks if a selector matches the child at the segment
+// Filters match every child, the filter itself is decided by the Matcher once the child is read
+func (s *selector) matches(segment parser.PathSegment) bool {
+	switch s.kind {
+	case nameSelector:
+		return segment.Index < 0 && segment.Key == s.name
+	case indexSelector:
+		return segment.Index == s.index
+	case sliceSelector:
+		if segment.Index < s.index || (s.end >= 0 && segment.Index >= s.end) {
+			return false
+		}
+		return s.stride > 0 && (segment.Index-s.index)%s.stride == 0
+	}
+	return true
+}
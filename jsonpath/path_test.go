@@ -0,0 +1,107 @@
+package jsonpath
+
+import (
+	"testing"
+
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+func TestIsPath(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{"$", true},
+		{"$.dataset[*]", true},
+		{"$['dataset']", true},
+		{"@.title", true},
+		{"@", true},
+		{"@type", false},
+		{"$ref", false},
+		{".dataset", false},
+		{"/dataset", false},
+	}
+
+	for _, tt := range tests {
+		if result := IsPath(tt.expression); result != tt.expected {
+			t.Errorf("IsPath(%q) = %v, want %v", tt.expression, result, tt.expected)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	valid := []string{
+		"$",
+		"$.dataset[*]",
+		"$..distribution[*].downloadURL",
+		"$.dataset[?(@.accessLevel=='public')]",
+		"$.dataset[?@.size >= 10 && !(@.draft)]",
+		"$.dataset[0:100]",
+		"$.dataset[::2]",
+		"$['a.b', \"c\"][0, 2]",
+		"$..*",
+		"$..['@type']",
+		"@.publisher.name",
+	}
+	for _, expression := range valid {
+		path, err := Compile(expression)
+		if err != nil {
+			t.Errorf("Compile(%q) error = %v", expression, err)
+			continue
+		}
+		if path.String() != expression {
+			t.Errorf("Compile(%q).String() = %q", expression, path.String())
+		}
+	}
+
+	invalid := []string{
+		"",
+		"dataset",
+		"$.",
+		"$[",
+		"$[-1]",
+		"$[0:-1]",
+		"$[::0]",
+		"$['unterminated]",
+		"$[?(@.a == )]",
+		"$[?(@..a)]",
+		"$[?('a')]",
+		"$.a b",
+	}
+	for _, expression := range invalid {
+		if _, err := Compile(expression); err == nil {
+			t.Errorf("Compile(%q) expected an error", expression)
+		}
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	key := parser.PathSegment{Key: "a", Index: -1}
+	index := func(i int) parser.PathSegment { return parser.PathSegment{Index: i} }
+
+	tests := []struct {
+		expression string
+		segment    parser.PathSegment
+		expected   bool
+	}{
+		{"$.a", key, true},
+		{"$.b", key, false},
+		{"$.*", key, true},
+		{"$[*]", index(3), true},
+		{"$[3]", index(3), true},
+		{"$[3]", index(4), false},
+		{"$[0:4]", index(3), true},
+		{"$[0:4]", index(4), false},
+		{"$[1::2]", index(3), true},
+		{"$[1::2]", index(4), false},
+		{"$[0]", key, false},
+	}
+
+	for _, tt := range tests {
+		path := MustCompile(tt.expression)
+		selector := path.steps[0].selectors[0]
+		if result := selector.matches(tt.segment); result != tt.expected {
+			t.Errorf("%s matches %v = %v, want %v", tt.expression, tt.segment, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package jsonpath
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// Match is a value selected by a path
+type Match struct {
+	Pointer string // the location of the value as a JSON Pointer, e.g. "/dataset/3/title"
+	Value   any    // the value, objects as map[string]any and arrays as []any
+}
+
+// Stream parses the JSON document from reader and calls handler with every match of the expression
+// Only the matched values are built in memory, one at a time unless they are nested in each other
+// or held by a filter
+func Stream(reader io.Reader, expression string, opts parser.Options, handler func(Match) error) error {
+	path, err := Compile(expression)
+	if err != nil {
+		return err
+	}
+
+	jsonParser, err := parser.NewParser(reader, opts, nil)
+	if err != nil {
+		return err
+	}
+	matcher := NewMatcher(path, func(payload any) error {
+		return handler(payload.(Match))
+	})
+	matcher.capture = true
+	jsonParser.SetParseHandler(func(value any) error {
+		return matcher.Handle(jsonParser.Path(), jsonParser.Ending(), value, nil)
+	})
+
+	if err := jsonParser.Parse(); err != nil {
+		return fmt.Errorf("error parsing data: %w", err)
+	}
+	return nil
+}
+
+// valueBuilder rebuilds a value from the calls of the parse handler inside it
+type valueBuilder struct {
+	value any
+}
+
+// add inserts a value, or an empty object or array at its end, at the path relative to the built value
+func (b *valueBuilder) add(relative []parser.PathSegment, ending parser.EventKind, value any) {
+	b.value = insertValue(b.value, relative, ending, value)
+}
+
+// insertValue inserts a value into a container, creating the containers on the way
+func insertValue(container any, relative []parser.PathSegment, ending parser.EventKind, value any) any {
+	if len(relative) == 0 {
+		switch ending {
+		case parser.Value:
+			return value
+		case parser.ObjectEnd:
+			if container == nil {
+				return map[string]any{}
+			}
+		case parser.ArrayEnd:
+			if container == nil {
+				return []any{}
+			}
+		}
+		return container
+	}
+
+	segment := relative[0]
+	if segment.Index < 0 {
+		object, _ := container.(map[string]any)
+		if object == nil {
+			object = make(map[string]any)
+		}
+		object[segment.Key] = insertValue(object[segment.Key], relative[1:], ending, value)
+		return object
+	}
+	array, _ := container.([]any)
+	for len(array) <= segment.Index {
+		array = append(array, nil)
+	}
+	array[segment.Index] = insertValue(array[segment.Index], relative[1:], ending, value)
+	return array
+}
+
+// pointerOf formats a path as a JSON Pointer
+func pointerOf(path []parser.PathSegment) string {
+	tokens := make(parser.Pointer, len(path))
+	for i, segment := range path {
+		if segment.Index < 0 {
+			tokens[i] = segment.Key
+		} else {
+			tokens[i] = strconv.Itoa(segment.Index)
+		}
+	}
+	return tokens.String()
+}
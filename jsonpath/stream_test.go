@@ -0,0 +1,94 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+const catalog = `{
+	"dataset": [
+		{"id": 1, "accessLevel": "public", "distribution": [{"downloadURL": "a"}, {"downloadURL": "b"}]},
+		{"id": 2, "accessLevel": "private", "distribution": [{"downloadURL": "c"}]},
+		{"distribution": [], "accessLevel": "public", "id": 3}
+	]
+}`
+
+func TestStream(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   []string // pointer=value in JSON
+	}{
+		{"$.dataset[*].id", []string{"/dataset/0/id=1", "/dataset/1/id=2", "/dataset/2/id=3"}},
+		{"$..downloadURL", []string{
+			`/dataset/0/distribution/0/downloadURL="a"`,
+			`/dataset/0/distribution/1/downloadURL="b"`,
+			`/dataset/1/distribution/0/downloadURL="c"`,
+		}},
+		{"$..distribution[*].downloadURL", []string{
+			`/dataset/0/distribution/0/downloadURL="a"`,
+			`/dataset/0/distribution/1/downloadURL="b"`,
+			`/dataset/1/distribution/0/downloadURL="c"`,
+		}},
+		// The third id is held until accessLevel, which comes after it, is read
+		{"$.dataset[?(@.accessLevel=='public')].id", []string{"/dataset/0/id=1", "/dataset/2/id=3"}},
+		{"$.dataset[0:2].id", []string{"/dataset/0/id=1", "/dataset/1/id=2"}},
+		{"$.dataset[0,2]['id']", []string{"/dataset/0/id=1", "/dataset/2/id=3"}},
+		{"$.dataset[1].distribution", []string{`/dataset/1/distribution=[{"downloadURL":"c"}]`}},
+		{"$.dataset[?(@.id > 1 && @.accessLevel != 'private')].distribution", []string{"/dataset/2/distribution=[]"}},
+		{"$.dataset[*].distribution[?(@.downloadURL == 'b')]", []string{`/dataset/0/distribution/1={"downloadURL":"b"}`}},
+		{"$.missing", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			var results []string
+			err := Stream(strings.NewReader(catalog), tt.expression, parser.Options{}, func(match Match) error {
+				value, err := json.Marshal(match.Value)
+				if err != nil {
+					return err
+				}
+				results = append(results, match.Pointer+"="+string(value))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Stream() error = %v", err)
+			}
+			if !reflect.DeepEqual(results, tt.expected) {
+				t.Errorf("Stream() = %q, want %q", results, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStreamWholeDocument(t *testing.T) {
+	var result any
+	err := Stream(strings.NewReader(catalog), "$", parser.Options{}, func(match Match) error {
+		result = match.Value
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+
+	var expected any
+	if err := json.Unmarshal([]byte(catalog), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Stream() = %v, want %v", result, expected)
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	handler := func(Match) error { return nil }
+	if err := Stream(strings.NewReader(catalog), "$[-1]", parser.Options{}, handler); err == nil {
+		t.Errorf("Stream() expected an error for an invalid expression")
+	}
+	if err := Stream(strings.NewReader(`{"a": [1,`), "$.a[*]", parser.Options{}, handler); err == nil {
+		t.Errorf("Stream() expected an error for invalid JSON")
+	}
+}
@@ -1,76 +1,417 @@
 package jsonstream
 
 import (
-	"bufio"
-	"encoding/csv"
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 
+	"github.com/bluesky0724/jsonstream/diff"
 	"github.com/bluesky0724/jsonstream/extractor"
+	"github.com/bluesky0724/jsonstream/formatter"
+	"github.com/bluesky0724/jsonstream/output"
+	"github.com/bluesky0724/jsonstream/parser"
+	"github.com/bluesky0724/jsonstream/schema"
 )
 
+// Options holds the optional settings of JSON2CSVWithOptions
+type Options struct {
+	// Pipelined runs reading, parsing and writing on separate goroutines
+	// connected by bounded queues, see extractor.ExtractPipelined
+	Pipelined bool
+	// Pipeline tunes the queues of the pipelined mode
+	Pipeline extractor.PipelineOptions
+	// MemoryMap maps "file" inputs into memory and parses them in place instead of reading them in chunks
+	// It takes precedence over Pipelined, since there is no reading left to overlap with parsing
+	MemoryMap bool
+	// Filter keeps only the base elements matching the condition, e.g. `accessLevel == "public" AND keyword contains "health"`
+	// The fields it reads do not need to be extracted, see extractor.RowFilter for the syntax
+	Filter string
+	// Schema keeps only the base elements satisfying it, e.g. the dataset definition of a catalog schema
+	// loaded with schema.LoadSchema and selected with Schema.Ref; OnViolation, if set, gets each violation
+	Schema      *schema.Schema
+	OnViolation func(schema.Violation) error
+	// Dedup drops the rows already written, in full or by some columns, see extractor.DedupOptions
+	Dedup *extractor.DedupOptions
+	// Rows zips and numbers the exploded fields and caps the rows of an element, see extractor.RowOptions
+	Rows *extractor.RowOptions
+	// Containers writes the targets whose values are objects as JSON or sub-columns, see extractor.ContainerOptions
+	Containers *extractor.ContainerOptions
+	// Output selects the format of the output file, by default the one of its extension, see output.FormatOf
+	Output output.Options
+}
+
 // JSON2CSV converts JSON data from a file or URL to CSV format
 // Parameters:
 //
 //	fileType: "file" for local files or "url" for online data
 //	input: file path or URL of the JSON data, e.g: "https://open.gsa.gov/data.json"
-//	output: destination CSV filename, e.g: "output.csv"
+//	output: destination filename, e.g: "output.csv", its extension selects the format: ".tsv", ".ndjson", ".json", ".txt" or CSV
 //	base: base field path where target data is stored: we assume this field is an array, e.g: ".dataset"
 //	fields: array of field names to extract relative to base path, e.g: "modified" means the absolute path of target field is ".dataset.modified"
+//
+// The base and fields may also be JSON Pointers (RFC 6901) relative to the document and to the base element,
+// e.g: "/dataset" and "/publisher/name", to select keys containing '.' or '/', empty keys and array positions ("/keyword/0")
+// or JSONPath expressions, the base then selecting the elements, e.g: "$.dataset[?(@.accessLevel=='public')]" and "$..downloadURL"
 func JSON2CSV(fileType string, input string, output string, base string, fields []string) error {
-	var reader *bufio.Reader
+	return JSON2CSVWithOptions(context.Background(), fileType, input, output, base, fields, Options{})
+}
 
-	// Handle local file input
-	if fileType == "file" {
-		file, err := os.Open(input)
-		if err != nil {
+// JSON2CSVWithOptions converts JSON data to CSV format like JSON2CSV with the given options
+// Cancelling ctx aborts the download of URL inputs and, in pipelined mode, the whole conversion
+func JSON2CSVWithOptions(ctx context.Context, fileType string, input string, output string, base string, fields []string, opts Options) error {
+	filter, err := parseFilter(opts.Filter)
+	if err != nil {
+		return err
+	}
 
-			return fmt.Errorf("error opening file: %w", err)
-		}
-		defer file.Close()
+	source, err := openJSON(ctx, fileType, input, opts.MemoryMap)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
 
-		reader = bufio.NewReader(file)
-	} else if fileType == "url" {
-		// Handle URL input
-		jsonURL := input
+	// Create the output file and the writer of its format
+	rows, err := createOutput(output, opts.Output)
+	if err != nil {
+		return err
+	}
+	defer rows.release()
 
-		resp, err := http.Get(jsonURL)
-		if err != nil {
+	if err := extract(ctx, source, rows.writer, rows.format.DecodesStrings(), base, fields, filter, opts); err != nil {
+		return err
+	}
+	return rows.close()
+}
 
-			return fmt.Errorf("error fetching URL: %w", err)
+// JSON2DB extracts the fields of the JSON data from a file or URL like JSON2CSVWithOptions, but inserts the rows
+// into an existing table of db in transactions of dbOpts.BatchSize rows, see output.DBWriter
+// When the extraction fails, the rows of the current transaction are rolled back and the committed ones stay
+func JSON2DB(ctx context.Context, fileType string, input string, db *sql.DB, base string, fields []string, opts Options, dbOpts output.DBOptions) error {
+	filter, err := parseFilter(opts.Filter)
+	if err != nil {
+		return err
+	}
+
+	source, err := openJSON(ctx, fileType, input, opts.MemoryMap)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	writer := output.NewDBWriter(ctx, db, dbOpts)
+	if err := extract(ctx, source, writer, true, base, fields, filter, opts); err != nil {
+		return errors.Join(err, writer.Rollback())
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+	return nil
+}
+
+// jsonSource is the input of an extraction: a reader, or a memory-mapped file
+type jsonSource struct {
+	reader io.ReadCloser
+	mapped *parser.MappedFile
+}
+
+// openJSON opens the input of an extraction, mapping "file" inputs into memory when asked to
+func openJSON(ctx context.Context, fileType string, input string, memoryMap bool) (*jsonSource, error) {
+	if memoryMap && fileType == "file" {
+		file, err := parser.MapFile(input)
+		if err != nil {
+			return nil, err
 		}
-		defer resp.Body.Close()
+		return &jsonSource{mapped: file}, nil
+	}
 
-		if resp.StatusCode != http.StatusOK {
+	reader, err := openInput(ctx, fileType, input)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonSource{reader: reader}, nil
+}
 
-			return fmt.Errorf("failed to fetch data: HTTP status %d", resp.StatusCode)
+// Close closes the reader or unmaps the file
+func (s *jsonSource) Close() error {
+	if s.mapped != nil {
+		return s.mapped.Close()
+	}
+	return s.reader.Close()
+}
+
+// parseFilter parses the filter of the options, nil when there is none
+func parseFilter(filter string) (*extractor.RowFilter, error) {
+	if filter == "" {
+		return nil, nil
+	}
+	return extractor.ParseRowFilter(filter)
+}
+
+// extract runs the extractor over the source with the options and writes the rows to writer,
+// the strings decoded when decodeStrings is set; the writer is left open
+func extract(ctx context.Context, source *jsonSource, writer output.RowWriter, decodeStrings bool, base string, fields []string, filter *extractor.RowFilter, opts Options) error {
+	if opts.Pipelined && source.mapped == nil {
+		// The writer stage flushes the writer itself
+		pipelineOptions := opts.Pipeline
+		pipelineOptions.Parser.DecodeStrings = decodeStrings
+		pipelineOptions.Filter = filter
+		pipelineOptions.Schema = opts.Schema
+		pipelineOptions.OnViolation = opts.OnViolation
+		pipelineOptions.Dedup = opts.Dedup
+		pipelineOptions.Rows = opts.Rows
+		pipelineOptions.Containers = opts.Containers
+		if err := extractor.ExtractPipelinedTo(ctx, source.reader, writer, base, fields, pipelineOptions); err != nil {
+			return fmt.Errorf("error extracting JSON: %w", err)
 		}
+		return nil
+	}
 
-		reader = bufio.NewReader(resp.Body)
+	var jsonParser *parser.JSONParser
+	var err error
+	if source.mapped != nil {
+		// The extractor only keeps values until their row is written, well before the file is unmapped
+		parserOptions := parser.Options{ZeroCopy: true, DecodeStrings: decodeStrings}
+		jsonParser, err = parser.NewBytesParser(source.mapped.Bytes(), parserOptions, nil)
 	} else {
-		return fmt.Errorf("invalid fileType: must be 'file' or 'url'")
+		jsonParser, err = parser.NewParser(source.reader, parser.Options{DecodeStrings: decodeStrings}, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("error creating parser: %w", err)
+	}
+
+	// Create and run the JSON extractor
+	extractor, err := extractor.NewJSONExtractorWithWriter(jsonParser, writer, base, fields)
+	if err != nil {
+		return fmt.Errorf("error creating extractor: %w", err)
+	}
+	if err := extractor.SetFilter(filter); err != nil {
+		return fmt.Errorf("error creating extractor: %w", err)
+	}
+	if err := extractor.SetRows(opts.Rows); err != nil {
+		return fmt.Errorf("error creating extractor: %w", err)
+	}
+	if err := extractor.SetContainers(opts.Containers); err != nil {
+		return fmt.Errorf("error creating extractor: %w", err)
+	}
+	if err := extractor.SetDedup(opts.Dedup); err != nil {
+		return fmt.Errorf("error creating extractor: %w", err)
+	}
+	if opts.Schema != nil {
+		if err := extractor.SetSchema(opts.Schema, opts.OnViolation); err != nil {
+			return fmt.Errorf("error creating extractor: %w", err)
+		}
+	}
+
+	if err := extractor.Extract(); err != nil {
+		return fmt.Errorf("error extracting JSON: %w", err)
+	}
+	return nil
+}
+
+// rowOutput is an output file of JSON2CSV with the writer of its format
+type rowOutput struct {
+	file   *os.File // nil when the writer creates its own files
+	writer output.RowWriter
+	format output.Format
+	closed bool // whether the writer was closed
+}
+
+// createOutput creates the output file and its writer, in the format of opts or else of the file extension
+func createOutput(name string, opts output.Options) (*rowOutput, error) {
+	if opts.Format == output.Auto {
+		opts.Format = output.FormatOf(name)
+	}
+	if opts.Format == output.Bulk && opts.Bulk.MaxBytes > 0 {
+		// The parts are files named after the output, e.g. bulk-1.ndjson, created by the writer
+		writer, err := output.NewSplitBulkWriter(output.FileParts(name), opts.Bulk)
+		if err != nil {
+			return nil, fmt.Errorf("error creating writer: %w", err)
+		}
+		return &rowOutput{writer: writer, format: opts.Format}, nil
+	}
+
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file: %w", err)
+	}
+	writer, err := output.NewWriter(file, opts)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error creating writer: %w", err)
+	}
+	return &rowOutput{file: file, writer: writer, format: opts.Format}, nil
+}
+
+// close completes the output, the file itself is closed by release
+func (o *rowOutput) close() error {
+	o.closed = true
+	if err := o.writer.Close(); err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+	return nil
+}
+
+// release closes the file, and first the writer of an incomplete output so that it frees its resources,
+// e.g. the temporary file of SQL
+func (o *rowOutput) release() {
+	if !o.closed {
+		o.writer.Close()
 	}
+	if o.file != nil {
+		o.file.Close()
+	}
+}
 
-	// Create output CSV file
-	csvFilename := output
+// JSON2JSON writes a slimmed-down JSON document keeping only the given fields of each base element
+// The parameters are the same as JSON2CSV, opts selects the layout (original nesting or flat objects)
+// and the formatting of the output
+func JSON2JSON(fileType string, input string, output string, base string, fields []string, opts extractor.ProjectOptions) error {
+	source, err := openInput(context.Background(), fileType, input)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
 
-	csvFile, err := os.Create(csvFilename)
+	jsonFile, err := os.Create(output)
 	if err != nil {
 		return fmt.Errorf("error creating file: %w", err)
 	}
-	defer csvFile.Close()
+	defer jsonFile.Close()
+
+	projector, err := extractor.NewJSONProjector(source, jsonFile, base, fields, opts)
+	if err != nil {
+		return fmt.Errorf("error creating projector: %w", err)
+	}
 
-	// Initialize CSV writer
-	writer := csv.NewWriter(csvFile)
-	defer writer.Flush()
+	if err := projector.Project(); err != nil {
+		return fmt.Errorf("error projecting JSON: %w", err)
+	}
 
-	// Create and run the JSON extractor
-	extractor, err := extractor.NewJSONExtractor(reader, writer, base, fields)
+	return nil
+}
 
-	if err := extractor.Extract(); err != nil {
-		return fmt.Errorf("error extracting JSON: %w", err)
+// FormatJSON streams the JSON document from a file or URL to the output file minified, indented
+// or one element per line, see formatter.Options
+func FormatJSON(fileType string, input string, output string, opts formatter.Options) error {
+	source, err := openInput(context.Background(), fileType, input)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	jsonFile, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	defer jsonFile.Close()
+
+	if err := formatter.Format(source, jsonFile, opts); err != nil {
+		return fmt.Errorf("error formatting JSON: %w", err)
 	}
 
 	return nil
 }
+
+// DiscoverJSON scans the JSON document from a file or URL once and reports every distinct path
+// with the types, presence and samples found there, see schema.Report for the text and JSON Schema outputs
+func DiscoverJSON(fileType string, input string, opts schema.DiscoverOptions) (*schema.Report, error) {
+	source, err := openInput(context.Background(), fileType, input)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	report, err := schema.Discover(source, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error discovering paths: %w", err)
+	}
+
+	return report, nil
+}
+
+// ValidateJSON checks the JSON document from a file or URL against a JSON Schema while streaming it
+// and returns every violation with its JSON Pointer and byte offset, see schema.Validate
+func ValidateJSON(fileType string, input string, s *schema.Schema) ([]schema.Violation, error) {
+	source, err := openInput(context.Background(), fileType, input)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	var violations []schema.Violation
+	err = schema.Validate(source, s, schema.ValidateOptions{}, func(violation schema.Violation) error {
+		violations = append(violations, violation)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error validating JSON: %w", err)
+	}
+
+	return violations, nil
+}
+
+// DiffJSON compares two versions of a JSON document from files or URLs, matching the elements of opts.Base
+// by opts.Key, and writes the changes to the output file as a CSV report or a JSON Patch, see diff.Write
+func DiffJSON(fileType string, oldInput string, newInput string, output string, opts diff.Options) error {
+	oldSource, err := openInput(context.Background(), fileType, oldInput)
+	if err != nil {
+		return err
+	}
+	defer oldSource.Close()
+
+	newSource, err := openInput(context.Background(), fileType, newInput)
+	if err != nil {
+		return err
+	}
+	defer newSource.Close()
+
+	outputFile, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	defer outputFile.Close()
+
+	if err := diff.Write(oldSource, newSource, outputFile, opts); err != nil {
+		return fmt.Errorf("error comparing JSON: %w", err)
+	}
+
+	return nil
+}
+
+// openInput opens the local file or fetches the URL given as input
+func openInput(ctx context.Context, fileType string, input string) (io.ReadCloser, error) {
+	// Handle local file input
+	if fileType == "file" {
+		file, err := os.Open(input)
+		if err != nil {
+			return nil, fmt.Errorf("error opening file: %w", err)
+		}
+		return file, nil
+	}
+
+	if fileType == "url" {
+		// Handle URL input
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, input, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching URL: %w", err)
+		}
+
+		resp, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching URL: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to fetch data: HTTP status %d", resp.StatusCode)
+		}
+
+		return resp.Body, nil
+	}
+
+	return nil, fmt.Errorf("invalid fileType: must be 'file' or 'url'")
+}
@@ -0,0 +1,92 @@
+package jsonstream
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/bluesky0724/jsonstream/output"
+)
+
+// fakeDB is a database behind a fake database/sql driver that only counts the inserted, committed and rolled back rows
+type fakeDB struct {
+	mu        sync.Mutex
+	pending   int
+	committed int
+	commits   int
+	rollbacks int
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+// fakeConn is a connection to a fakeDB
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return &fakeStmt{c.db}, nil }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return &fakeTx{c.db}, nil }
+
+// fakeTx is a transaction of a fakeDB
+type fakeTx struct{ db *fakeDB }
+
+func (t *fakeTx) Commit() error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	t.db.committed += t.db.pending
+	t.db.pending = 0
+	t.db.commits++
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	t.db.pending = 0
+	t.db.rollbacks++
+	return nil
+}
+
+// fakeStmt is a prepared statement of a fakeDB, any statement inserts one row
+type fakeStmt struct{ db *fakeDB }
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.pending++
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestJSON2DBPipelinedFailure(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "data.json")
+	if err := os.WriteFile(input, []byte(`{"data":[{"id":1},{"id":2},{"id":3} oops`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The writer stage may see the end of the rows before the cancellation, so run it many times
+	for range 200 {
+		fake := &fakeDB{}
+		db := sql.OpenDB(fake)
+		db.SetMaxOpenConns(1)
+		err := JSON2DB(context.Background(), "file", input, db, ".data", []string{"id"}, Options{Pipelined: true}, output.DBOptions{})
+		db.Close()
+		if err == nil {
+			t.Fatal("JSON2DB() expected a parse error")
+		}
+		if fake.committed != 0 || fake.commits != 0 {
+			t.Fatalf("%d rows committed in %d commits after the failure, want none", fake.committed, fake.commits)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bluesky0724/jsonstream/encoder"
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// BulkOptions configures a BulkWriter
+type BulkOptions struct {
+	// Index is the _index of the actions, left out when empty, e.g. when the index is in the URL of the requests
+	Index string
+	// Action is the bulk action of the documents, "index" by default or "create"
+	Action string
+	// IDField is the column whose value is the _id of the documents, generated by the search engine when empty
+	// or when the element has no value there
+	IDField string
+	// MaxBytes splits the output into parts of at most that many bytes, see NewSplitBulkWriter,
+	// a document larger than that being alone in its part; a single output when zero
+	MaxBytes int
+	// Encoder configures the JSON of the documents, the indentation is ignored
+	Encoder encoder.Options
+}
+
+// BulkWriter writes the elements as the body of requests of the Elasticsearch and OpenSearch _bulk API:
+// for each element, an action line and the document built from the columns
+// The dotted names of the columns nest the document, e.g. "publisher.name" gives {"publisher": {"name": ...}},
+// and so do the JSON Pointers; an Array is written as an array, a missing column is left out
+// and so is an object without any value
+// It is an ElementWriter, the rows given to WriteRow are documents with one value per column
+type BulkWriter struct {
+	opts    BulkOptions
+	create  func(part int) (io.WriteCloser, error) // nil for a single output
+	writer  io.Writer                              // the output or the current part, nil before the first part
+	part    int                                    // the number of parts created
+	size    int                                    // the number of bytes written to the current part
+	root    *documentNode
+	id      int // the position of IDField in the columns, -1 when there is none
+	buffer  bytes.Buffer
+	encoder *encoder.JSONEncoder
+}
+
+// documentNode is a member of the documents, either a column or an object of members
+type documentNode struct {
+	key     string
+	column  int // the position of the column, -1 for an object
+	members []*documentNode
+}
+
+// NewBulkWriter creates a writer of bulk requests to w
+// The output can not be split, opts.MaxBytes must be zero
+func NewBulkWriter(w io.Writer, opts BulkOptions) (*BulkWriter, error) {
+	if opts.MaxBytes > 0 {
+		return nil, errors.New("bulk requests split by size need NewSplitBulkWriter")
+	}
+	return newBulkWriter(w, nil, opts)
+}
+
+// NewSplitBulkWriter creates a writer of bulk requests to the parts returned by create, numbered from 1,
+// a new one as soon as the next element does not fit in opts.MaxBytes; the parts are closed by the writer
+func NewSplitBulkWriter(create func(part int) (io.WriteCloser, error), opts BulkOptions) (*BulkWriter, error) {
+	return newBulkWriter(nil, create, opts)
+}
+
+// newBulkWriter checks the options and creates the writer
+func newBulkWriter(w io.Writer, create func(part int) (io.WriteCloser, error), opts BulkOptions) (*BulkWriter, error) {
+	switch opts.Action {
+	case "":
+		opts.Action = "index"
+	case "index", "create":
+	default:
+		return nil, fmt.Errorf("invalid bulk action %q: must be 'index' or 'create'", opts.Action)
+	}
+	opts.Encoder.Indent, opts.Encoder.Prefix, opts.Encoder.MultipleValues = "", "", true
+	b := &BulkWriter{opts: opts, create: create, writer: w, id: -1}
+	b.encoder = encoder.NewJSONEncoder(&b.buffer, opts.Encoder)
+	return b, nil
+}
+
+// FileParts returns a create function of NewSplitBulkWriter making files named after name with the number
+// of the part, e.g. "bulk-1.ndjson" and "bulk-2.ndjson" for "bulk.ndjson"
+func FileParts(name string) func(part int) (io.WriteCloser, error) {
+	extension := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, extension)
+	return func(part int) (io.WriteCloser, error) {
+		file, err := os.Create(stem + "-" + strconv.Itoa(part) + extension)
+		if err != nil {
+			return nil, fmt.Errorf("error creating file: %w", err)
+		}
+		return file, nil
+	}
+}
+
+// WriteHeader builds the structure of the documents from the column names
+func (b *BulkWriter) WriteHeader(columns []string) error {
+	b.root = &documentNode{column: -1}
+	for i, column := range columns {
+		if column == b.opts.IDField {
+			b.id = i
+		}
+		if err := b.root.add(documentKeys(column), i); err != nil {
+			return fmt.Errorf("invalid column %q: %w", column, err)
+		}
+	}
+	if b.opts.IDField != "" && b.id < 0 {
+		return fmt.Errorf("invalid id field %q: it is not a column", b.opts.IDField)
+	}
+	return nil
+}
+
+// WriteRow writes a document with one value per column, the missing ones being left out
+func (b *BulkWriter) WriteRow(values []any) error {
+	columns := make([][]any, len(values))
+	for i := range values {
+		if values[i] != Missing {
+			columns[i] = values[i : i+1]
+		}
+	}
+	return b.WriteElement(columns)
+}
+
+// WriteElement writes the action and the document of one element
+func (b *BulkWriter) WriteElement(columns [][]any) error {
+	if b.root == nil {
+		return errors.New("no header written")
+	}
+	e := b.encoder
+	e.BeginObject()
+	e.Key(b.opts.Action)
+	e.BeginObject()
+	if b.opts.Index != "" {
+		e.Key("_index")
+		e.String(b.opts.Index)
+	}
+	if b.id >= 0 && len(columns[b.id]) > 0 && columns[b.id][0] != nil {
+		e.Key("_id")
+		e.String(Text(columns[b.id][0]))
+	}
+	e.EndObject()
+	e.EndObject()
+	b.writeDocument(b.root, columns)
+	if err := e.Flush(); err != nil {
+		return err
+	}
+
+	err := b.writeEntry(b.buffer.Bytes())
+	b.buffer.Reset()
+	return err
+}
+
+// writeDocument writes an object of the document
+func (b *BulkWriter) writeDocument(node *documentNode, columns [][]any) {
+	e := b.encoder
+	e.BeginObject()
+	for _, member := range node.members {
+		if member.column < 0 {
+			if member.empty(columns) {
+				continue // an object without any value is left out too
+			}
+			e.Key(member.key)
+			b.writeDocument(member, columns)
+			continue
+		}
+		values := columns[member.column]
+		switch len(values) {
+		case 0:
+			continue // a missing field is left out
+		case 1:
+			e.Key(member.key)
+			b.writeValue(values[0])
+		default:
+			e.Key(member.key)
+			e.BeginArray()
+			for _, value := range values {
+				b.writeValue(value)
+			}
+			e.EndArray()
+		}
+	}
+	e.EndObject()
+}
+
+// writeValue writes a value of the document, a JSONValue as its JSON text and an Array as an array
+func (b *BulkWriter) writeValue(value any) {
+	switch v := value.(type) {
+	case JSONValue:
+		b.encoder.RawJSON(v.JSONText())
+	case Array:
+		b.encoder.BeginArray()
+		for _, item := range v {
+			b.writeValue(item)
+		}
+		b.encoder.EndArray()
+	default:
+		b.encoder.Value(value)
+	}
+}
+
+// writeEntry writes the lines of one element, in a new part when it does not fit in the current one
+func (b *BulkWriter) writeEntry(entry []byte) error {
+	if b.create != nil && (b.writer == nil || (b.opts.MaxBytes > 0 && b.size > 0 && b.size+len(entry) > b.opts.MaxBytes)) {
+		if err := b.nextPart(); err != nil {
+			return err
+		}
+	}
+	if _, err := b.writer.Write(entry); err != nil {
+		return fmt.Errorf("error writing bulk requests: %w", err)
+	}
+	b.size += len(entry)
+	return nil
+}
+
+// nextPart closes the current part and creates the next one
+func (b *BulkWriter) nextPart() error {
+	if err := b.closePart(); err != nil {
+		return err
+	}
+	b.part++
+	writer, err := b.create(b.part)
+	if err != nil {
+		return err
+	}
+	b.writer, b.size = writer, 0
+	return nil
+}
+
+// closePart closes the current part, if any
+func (b *BulkWriter) closePart() error {
+	closer, ok := b.writer.(io.Closer)
+	if b.create == nil || !ok {
+		return nil
+	}
+	b.writer = nil
+	if err := closer.Close(); err != nil {
+		return fmt.Errorf("error writing bulk requests: %w", err)
+	}
+	return nil
+}
+
+// Flush does nothing, every element is written to the output when it is complete
+func (b *BulkWriter) Flush() error {
+	return nil
+}
+
+// Close closes the last part of a split output, the single output is not closed
+func (b *BulkWriter) Close() error {
+	return b.closePart()
+}
+
+// Parts returns the number of parts created so far
+func (b *BulkWriter) Parts() int {
+	return b.part
+}
+
+// add inserts the column at the path of keys, the objects on the way are created as needed
+func (n *documentNode) add(keys []string, column int) error {
+	key := keys[0]
+	index := slices.IndexFunc(n.members, func(member *documentNode) bool { return member.key == key })
+	if len(keys) == 1 {
+		if index >= 0 {
+			return fmt.Errorf("%q is already in the document", key)
+		}
+		n.members = append(n.members, &documentNode{key: key, column: column})
+		return nil
+	}
+
+	if index < 0 {
+		n.members = append(n.members, &documentNode{key: key, column: -1})
+		index = len(n.members) - 1
+	}
+	member := n.members[index]
+	if member.column >= 0 {
+		return fmt.Errorf("%q is a value and an object", key)
+	}
+	return member.add(keys[1:], column)
+}
+
+// empty tells whether none of the columns under the node has a value
+func (n *documentNode) empty(columns [][]any) bool {
+	if n.column >= 0 {
+		return len(columns[n.column]) == 0
+	}
+	for _, member := range n.members {
+		if !member.empty(columns) {
+			return false
+		}
+	}
+	return true
+}
+
+// documentKeys splits a column name into the keys of its place in the document:
+// the tokens of a JSON Pointer, the parts of a dotted path, the name itself for a JSONPath expression
+func documentKeys(column string) []string {
+	if strings.HasPrefix(column, "$") || strings.HasPrefix(column, "@") {
+		return []string{column}
+	}
+	if parser.IsPointer(column) {
+		if tokens, err := parser.ParsePointer(column); err == nil && len(tokens) > 0 {
+			return tokens
+		}
+	}
+	return strings.Split(strings.TrimPrefix(column, "."), ".")
+}
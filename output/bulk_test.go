@@ -0,0 +1,181 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBulkWriter(t *testing.T) {
+	columns := []string{"id", "publisher.name", "publisher.country", "/tags", "$..url"}
+	elements := [][][]any{
+		{{json.Number("1")}, {"ACME \"Inc\""}, {"FR"}, {"a", "b"}, {"u1"}},
+		{{"x/2"}, {}, {nil}, {}, {}},
+		{{}, {"Solo"}, {}, {"c"}, {"u2", "u3"}},
+	}
+
+	tests := []struct {
+		name     string
+		opts     BulkOptions
+		expected string
+	}{
+		{
+			name: "index with ids",
+			opts: BulkOptions{Index: "books", IDField: "id"},
+			expected: `{"index":{"_index":"books","_id":"1"}}` + "\n" +
+				`{"id":1,"publisher":{"name":"ACME \"Inc\"","country":"FR"},"tags":["a","b"],"$..url":"u1"}` + "\n" +
+				`{"index":{"_index":"books","_id":"x/2"}}` + "\n" +
+				`{"id":"x/2","publisher":{"country":null}}` + "\n" +
+				`{"index":{"_index":"books"}}` + "\n" +
+				`{"publisher":{"name":"Solo"},"tags":"c","$..url":["u2","u3"]}` + "\n",
+		},
+		{
+			name: "create without index",
+			opts: BulkOptions{Action: "create"},
+			expected: `{"create":{}}` + "\n" +
+				`{"id":1,"publisher":{"name":"ACME \"Inc\"","country":"FR"},"tags":["a","b"],"$..url":"u1"}` + "\n" +
+				`{"create":{}}` + "\n" +
+				`{"id":"x/2","publisher":{"country":null}}` + "\n" +
+				`{"create":{}}` + "\n" +
+				`{"publisher":{"name":"Solo"},"tags":"c","$..url":["u2","u3"]}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			writer, err := NewBulkWriter(&buffer, tt.opts)
+			if err != nil {
+				t.Fatalf("NewBulkWriter() error = %v", err)
+			}
+			if err := writer.WriteHeader(columns); err != nil {
+				t.Fatalf("WriteHeader() error = %v", err)
+			}
+			for _, element := range elements {
+				if err := writer.WriteElement(element); err != nil {
+					t.Fatalf("WriteElement() error = %v", err)
+				}
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if buffer.String() != tt.expected {
+				t.Errorf("output = %q, want %q", buffer.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestBulkWriterRows(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := NewBulkWriter(&buffer, BulkOptions{IDField: "id"})
+	if err != nil {
+		t.Fatalf("NewBulkWriter() error = %v", err)
+	}
+	if err := writer.WriteHeader([]string{"id", "a.b", "c", "d"}); err != nil {
+		t.Fatalf("WriteHeader() error = %v", err)
+	}
+	if err := writer.WriteRow([]any{"1", "x", true, nil}); err != nil {
+		t.Fatalf("WriteRow() error = %v", err)
+	}
+	// A missing id lets the search engine generate one, and the missing fields are left out
+	if err := writer.WriteRow([]any{Missing, Missing, false, Missing}); err != nil {
+		t.Fatalf("WriteRow() error = %v", err)
+	}
+	expected := `{"index":{"_id":"1"}}` + "\n" + `{"id":"1","a":{"b":"x"},"c":true,"d":null}` + "\n" +
+		`{"index":{}}` + "\n" + `{"c":false}` + "\n"
+	if buffer.String() != expected {
+		t.Errorf("output = %q, want %q", buffer.String(), expected)
+	}
+}
+
+func TestBulkWriterErrors(t *testing.T) {
+	if _, err := NewBulkWriter(io.Discard, BulkOptions{Action: "delete"}); err == nil {
+		t.Errorf("NewBulkWriter() expected an error for an unsupported action")
+	}
+	if _, err := NewBulkWriter(io.Discard, BulkOptions{MaxBytes: 100}); err == nil {
+		t.Errorf("NewBulkWriter() expected an error for a split output")
+	}
+
+	headers := []struct {
+		name    string
+		columns []string
+		opts    BulkOptions
+	}{
+		{"value and object", []string{"a", "a.b"}, BulkOptions{}},
+		{"object and value", []string{"/a/b", "a"}, BulkOptions{}},
+		{"duplicate", []string{"a.b", "/a/b"}, BulkOptions{}},
+		{"unknown id field", []string{"a"}, BulkOptions{IDField: "id"}},
+	}
+	for _, tt := range headers {
+		writer, err := NewBulkWriter(io.Discard, tt.opts)
+		if err != nil {
+			t.Fatalf("NewBulkWriter() error = %v", err)
+		}
+		if err := writer.WriteHeader(tt.columns); err == nil {
+			t.Errorf("WriteHeader(%q) expected an error for %s", tt.columns, tt.name)
+		}
+	}
+}
+
+func TestSplitBulkWriter(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "bulk.ndjson")
+	// Each element takes 25 bytes, two fit in a part
+	writer, err := NewSplitBulkWriter(FileParts(name), BulkOptions{MaxBytes: 50})
+	if err != nil {
+		t.Fatalf("NewSplitBulkWriter() error = %v", err)
+	}
+	if err := writer.WriteHeader([]string{"id"}); err != nil {
+		t.Fatalf("WriteHeader() error = %v", err)
+	}
+	for i := range 5 {
+		if err := writer.WriteRow([]any{fmt.Sprintf("e%d", i)}); err != nil {
+			t.Fatalf("WriteRow() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if writer.Parts() != 3 {
+		t.Errorf("Parts() = %d, want 3", writer.Parts())
+	}
+
+	expected := []string{
+		`{"index":{}}` + "\n" + `{"id":"e0"}` + "\n" + `{"index":{}}` + "\n" + `{"id":"e1"}` + "\n",
+		`{"index":{}}` + "\n" + `{"id":"e2"}` + "\n" + `{"index":{}}` + "\n" + `{"id":"e3"}` + "\n",
+		`{"index":{}}` + "\n" + `{"id":"e4"}` + "\n",
+	}
+	for i, want := range expected {
+		content, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("bulk-%d.ndjson", i+1)))
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		if string(content) != want {
+			t.Errorf("part %d = %q, want %q", i+1, content, want)
+		}
+	}
+}
+
+func TestBulkWriterArrays(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := NewBulkWriter(&buffer, BulkOptions{})
+	if err != nil {
+		t.Fatalf("NewBulkWriter() error = %v", err)
+	}
+	if err := writer.WriteHeader([]string{"tags", "name", "empty"}); err != nil {
+		t.Fatalf("WriteHeader() error = %v", err)
+	}
+	// A one-element array stays an array, a single value stays a scalar
+	if err := writer.WriteElement([][]any{{Array{"c"}}, {"n"}, {Array{}}}); err != nil {
+		t.Fatalf("WriteElement() error = %v", err)
+	}
+	expected := `{"index":{}}` + "\n" + `{"tags":["c"],"name":"n","empty":[]}` + "\n"
+	if buffer.String() != expected {
+		t.Errorf("output = %q, want %q", buffer.String(), expected)
+	}
+}
@@ -0,0 +1,61 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// CSVWriter writes the rows as CSV records, the values formatted with Text
+type CSVWriter struct {
+	writer *csv.Writer
+	nulls  Nulls
+	record []string // reused for every row
+}
+
+// NewCSVWriter creates a writer of rows to an existing CSV writer, e.g. one with its own Comma or UseCRLF
+func NewCSVWriter(writer *csv.Writer) *CSVWriter {
+	return &CSVWriter{writer: writer}
+}
+
+// NewDelimitedWriter creates a writer of rows to w with the given field delimiter, e.g. '\t' for TSV
+func NewDelimitedWriter(w io.Writer, delimiter rune) (*CSVWriter, error) {
+	// encoding/csv only checks the delimiter on the first write, it is better refused right away
+	if delimiter == 0 || delimiter == '"' || delimiter == '\r' || delimiter == '\n' || !utf8.ValidRune(delimiter) || delimiter == utf8.RuneError {
+		return nil, fmt.Errorf("invalid delimiter %q", delimiter)
+	}
+	writer := csv.NewWriter(w)
+	writer.Comma = delimiter
+	return NewCSVWriter(writer), nil
+}
+
+// SetNulls sets the text of the missing, null and empty values
+func (c *CSVWriter) SetNulls(nulls Nulls) {
+	c.nulls = nulls
+}
+
+// WriteHeader writes the column names as the first record
+func (c *CSVWriter) WriteHeader(columns []string) error {
+	return c.writer.Write(columns)
+}
+
+// WriteRow writes one record
+func (c *CSVWriter) WriteRow(values []any) error {
+	c.record = c.record[:0]
+	for _, value := range values {
+		c.record = append(c.record, c.nulls.Text(value))
+	}
+	return c.writer.Write(c.record)
+}
+
+// Flush writes the buffered records to the underlying writer
+func (c *CSVWriter) Flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// Close flushes the records, there is nothing to complete in CSV
+func (c *CSVWriter) Close() error {
+	return c.Flush()
+}
@@ -0,0 +1,53 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"testing"
+)
+
+func TestCSVWriter(t *testing.T) {
+	var output bytes.Buffer
+	writer := NewCSVWriter(csv.NewWriter(&output))
+
+	rows := [][]any{
+		{"a,b", 1.5, true},
+		{"", nil, int64(-2)},
+		{"line\nbreak", "\"quoted\"", false},
+	}
+	if err := writer.WriteHeader([]string{"text", "value", "flag"}); err != nil {
+		t.Fatalf("WriteHeader() error = %v", err)
+	}
+	for _, row := range rows {
+		if err := writer.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	expected := "text,value,flag\n\"a,b\",1.5,true\n,,-2\n\"line\nbreak\",\"\"\"quoted\"\"\",false\n"
+	if output.String() != expected {
+		t.Errorf("output = %q, want %q", output.String(), expected)
+	}
+}
+
+// failingWriter fails every write
+type failingWriter struct{ err error }
+
+func (w failingWriter) Write(p []byte) (int, error) { return 0, w.err }
+
+func TestCSVWriterError(t *testing.T) {
+	writeErr := errors.New("disk full")
+	writer, err := NewDelimitedWriter(failingWriter{err: writeErr}, '|')
+	if err != nil {
+		t.Fatalf("NewDelimitedWriter() error = %v", err)
+	}
+	writer.WriteHeader([]string{"id"})
+	writer.WriteRow([]any{1.0})
+	if err := writer.Flush(); !errors.Is(err, writeErr) {
+		t.Errorf("Flush() error = %v, want %v", err, writeErr)
+	}
+}
@@ -0,0 +1,216 @@
+package output
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DBOptions configures a DBWriter
+type DBOptions struct {
+	// Dialect selects the placeholders, $1 for PostgreSQL and ? otherwise, the quoting of names and the upsert syntax
+	Dialect SQLDialect
+	// Table is the name of the existing table receiving the rows, "data" when empty
+	Table string
+	// BatchSize is the number of rows inserted in each transaction, 500 when zero
+	BatchSize int
+	// CommitInterval, if set, also commits a transaction once it is open for that long
+	CommitInterval time.Duration
+	// Key, if set, is the column of a unique constraint: the rows with a key already in the table
+	// update the other columns of that row instead of failing
+	Key string
+}
+
+// DBWriter inserts the rows into a table of a database/sql connection with a prepared INSERT statement,
+// committing a transaction every BatchSize rows or CommitInterval
+// When an insert fails, the transaction of the current batch is rolled back and the error is returned:
+// the batches committed before stay in the table, see Committed
+// Numbers are passed as int64 or float64, null and missing values as NULL, the empty strings as they are
+type DBWriter struct {
+	ctx       context.Context
+	db        *sql.DB
+	opts      DBOptions
+	query     string
+	tx        *sql.Tx
+	stmt      *sql.Stmt
+	started   time.Time // when the transaction began
+	rows      int       // the number of rows of the current transaction
+	committed int64
+	args      []any // reused for every row
+}
+
+// NewDBWriter creates a writer of rows to db, the statements run with ctx
+func NewDBWriter(ctx context.Context, db *sql.DB, opts DBOptions) *DBWriter {
+	if opts.Table == "" {
+		opts.Table = "data"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	return &DBWriter{ctx: ctx, db: db, opts: opts}
+}
+
+// WriteHeader builds the INSERT statement of the columns
+func (d *DBWriter) WriteHeader(columns []string) error {
+	if d.opts.Key != "" && !slices.Contains(columns, d.opts.Key) {
+		return fmt.Errorf("invalid key column %q: it is not a column", d.opts.Key)
+	}
+	d.query = insertQuery(d.opts, columns)
+	return nil
+}
+
+// WriteRow inserts one row, beginning a transaction when there is none and committing it when the batch is done
+func (d *DBWriter) WriteRow(values []any) error {
+	if d.query == "" {
+		return errors.New("no header written")
+	}
+	if d.tx == nil {
+		if err := d.begin(); err != nil {
+			return err
+		}
+	}
+
+	d.args = d.args[:0]
+	for _, value := range values {
+		d.args = append(d.args, driverValue(value))
+	}
+	if _, err := d.stmt.ExecContext(d.ctx, d.args...); err != nil {
+		return d.fail(fmt.Errorf("error inserting row: %w", err))
+	}
+	d.rows++
+
+	if d.rows >= d.opts.BatchSize || (d.opts.CommitInterval > 0 && time.Since(d.started) >= d.opts.CommitInterval) {
+		return d.commit()
+	}
+	return nil
+}
+
+// Flush does nothing: the batches are only committed by their size, their interval and Close,
+// so that a flush never ends the transaction a failure has to roll back
+func (d *DBWriter) Flush() error {
+	return nil
+}
+
+// Close commits the last batch
+// It does not close the database
+func (d *DBWriter) Close() error {
+	if d.tx == nil {
+		return nil
+	}
+	return d.commit()
+}
+
+// Rollback discards the rows of the current batch, e.g. when the extraction failed
+func (d *DBWriter) Rollback() error {
+	if d.tx == nil {
+		return nil
+	}
+	return d.fail(nil)
+}
+
+// Committed returns the number of rows committed so far
+func (d *DBWriter) Committed() int64 {
+	return d.committed
+}
+
+// begin starts a transaction with the prepared statement
+func (d *DBWriter) begin() error {
+	tx, err := d.db.BeginTx(d.ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	stmt, err := tx.PrepareContext(d.ctx, d.query)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("error preparing statement: %w", err)
+	}
+	d.tx, d.stmt, d.started, d.rows = tx, stmt, time.Now(), 0
+	return nil
+}
+
+// commit commits the current transaction
+func (d *DBWriter) commit() error {
+	d.stmt.Close()
+	err := d.tx.Commit()
+	d.tx, d.stmt = nil, nil
+	if err != nil {
+		return fmt.Errorf("error committing rows: %w", err)
+	}
+	d.committed += int64(d.rows)
+	return nil
+}
+
+// fail rolls back the current transaction and returns err, with the error of the rollback if any
+func (d *DBWriter) fail(err error) error {
+	d.stmt.Close()
+	if rollbackErr := d.tx.Rollback(); rollbackErr != nil {
+		err = errors.Join(err, fmt.Errorf("error rolling back rows: %w", rollbackErr))
+	}
+	d.tx, d.stmt = nil, nil
+	return err
+}
+
+// insertQuery returns the INSERT statement of one row, with the upsert clause of the dialect when there is a key
+func insertQuery(opts DBOptions, columns []string) string {
+	names := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = quoteIdentifier(opts.Dialect, column)
+		placeholders[i] = "?"
+		if opts.Dialect == PostgreSQL {
+			placeholders[i] = "$" + strconv.Itoa(i+1)
+		}
+	}
+	query := "INSERT INTO " + quoteIdentifier(opts.Dialect, opts.Table) +
+		" (" + strings.Join(names, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+	if opts.Key == "" {
+		return query
+	}
+
+	var updates []string
+	for i, column := range columns {
+		if column == opts.Key {
+			continue
+		}
+		if opts.Dialect == MySQL {
+			updates = append(updates, names[i]+" = VALUES("+names[i]+")")
+		} else {
+			updates = append(updates, names[i]+" = excluded."+names[i])
+		}
+	}
+	key := quoteIdentifier(opts.Dialect, opts.Key)
+	switch {
+	case opts.Dialect == MySQL && len(updates) == 0:
+		return query + " ON DUPLICATE KEY UPDATE " + key + " = " + key
+	case opts.Dialect == MySQL:
+		return query + " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	case len(updates) == 0:
+		return query + " ON CONFLICT (" + key + ") DO NOTHING"
+	}
+	return query + " ON CONFLICT (" + key + ") DO UPDATE SET " + strings.Join(updates, ", ")
+}
+
+// driverValue converts a value of a row to a value of database/sql
+func driverValue(value any) any {
+	switch v := value.(type) {
+	case missingValue:
+		return nil
+	case JSONValue:
+		return v.String()
+	case json.Number:
+		if integer, err := v.Int64(); err == nil {
+			return integer
+		}
+		if number, err := v.Float64(); err == nil {
+			return number
+		}
+		return string(v)
+	}
+	return value
+}
@@ -0,0 +1,209 @@
+package output
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeDB is an in-memory database behind a fake database/sql driver: it records the prepared
+// queries and keeps the inserted rows until their transaction is committed or rolled back
+type fakeDB struct {
+	queries   []string
+	pending   [][]driver.Value
+	committed [][]driver.Value
+	commits   int
+	rollbacks int
+	failOn    driver.Value // an insert with this value fails
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+// fakeConn is a connection to a fakeDB
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	c.db.queries = append(c.db.queries, query)
+	return &fakeStmt{c.db}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return &fakeTx{c.db}, nil }
+
+// fakeTx is a transaction of a fakeDB
+type fakeTx struct{ db *fakeDB }
+
+func (t *fakeTx) Commit() error {
+	t.db.committed = append(t.db.committed, t.db.pending...)
+	t.db.pending = nil
+	t.db.commits++
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.db.pending = nil
+	t.db.rollbacks++
+	return nil
+}
+
+// fakeStmt is a prepared statement of a fakeDB, any statement inserts its arguments
+type fakeStmt struct{ db *fakeDB }
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	for _, arg := range args {
+		if s.db.failOn != nil && arg == s.db.failOn {
+			return nil, errors.New("constraint violated")
+		}
+	}
+	s.db.pending = append(s.db.pending, append([]driver.Value(nil), args...))
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+// writeDB writes the rows to a DBWriter over a new fakeDB
+func writeDB(t *testing.T, fake *fakeDB, opts DBOptions, rows [][]any) (*DBWriter, error) {
+	t.Helper()
+	db := sql.OpenDB(fake)
+	t.Cleanup(func() { db.Close() })
+	// A single connection keeps the statements of a transaction on the connection of the transaction
+	db.SetMaxOpenConns(1)
+
+	writer := NewDBWriter(context.Background(), db, opts)
+	if err := writer.WriteHeader([]string{"id", "name"}); err != nil {
+		t.Fatalf("WriteHeader() error = %v", err)
+	}
+	for _, row := range rows {
+		if err := writer.WriteRow(row); err != nil {
+			return writer, err
+		}
+	}
+	return writer, writer.Close()
+}
+
+func TestDBWriter(t *testing.T) {
+	rows := [][]any{
+		{json.Number("1"), "a"},
+		{json.Number("2.5"), nil},
+		{int64(3), ""},
+		{4.0, "d"},
+		{json.Number("5"), true},
+		{int64(6), rawJSON(`{"a":1}`)},
+	}
+	fake := &fakeDB{}
+	writer, err := writeDB(t, fake, DBOptions{Table: "datasets", BatchSize: 2}, rows)
+	if err != nil {
+		t.Fatalf("WriteRow() error = %v", err)
+	}
+
+	expected := [][]driver.Value{{int64(1), "a"}, {2.5, nil}, {int64(3), ""}, {4.0, "d"}, {int64(5), true}, {int64(6), `{"a":1}`}}
+	if !reflect.DeepEqual(fake.committed, expected) {
+		t.Errorf("committed rows = %v, want %v", fake.committed, expected)
+	}
+	if fake.commits != 3 || writer.Committed() != 6 {
+		t.Errorf("%d commits of %d rows, want 3 commits of 6 rows", fake.commits, writer.Committed())
+	}
+	if query := `INSERT INTO "datasets" ("id", "name") VALUES ($1, $2)`; fake.queries[0] != query {
+		t.Errorf("query = %q, want %q", fake.queries[0], query)
+	}
+}
+
+func TestDBWriterFailure(t *testing.T) {
+	fake := &fakeDB{failOn: "bad"}
+	rows := [][]any{{1.0, "a"}, {2.0, "b"}, {3.0, "c"}, {4.0, "d"}, {5.0, "bad"}, {6.0, "f"}}
+	writer, err := writeDB(t, fake, DBOptions{BatchSize: 3}, rows)
+	if err == nil {
+		t.Fatal("WriteRow() error = nil")
+	}
+
+	// The first batch stays, the row of the second one inserted before the failure is rolled back
+	if len(fake.committed) != 3 || writer.Committed() != 3 || fake.rollbacks != 1 {
+		t.Errorf("%d rows committed (%d reported) with %d rollbacks, want 3 with 1 rollback", len(fake.committed), writer.Committed(), fake.rollbacks)
+	}
+	if err := writer.Close(); err != nil || fake.commits != 1 {
+		t.Errorf("Close() after the failure error = %v with %d commits, want nil with 1 commit", err, fake.commits)
+	}
+}
+
+func TestDBWriterRollback(t *testing.T) {
+	fake := &fakeDB{}
+	db := sql.OpenDB(fake)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	writer := NewDBWriter(context.Background(), db, DBOptions{})
+	if err := writer.WriteRow([]any{1.0}); err == nil {
+		t.Error("WriteRow() before WriteHeader() error = nil")
+	}
+	writer.WriteHeader([]string{"id"})
+	writer.WriteRow([]any{1.0})
+	writer.WriteRow([]any{2.0})
+	if err := writer.Flush(); err != nil || fake.commits != 0 {
+		t.Errorf("Flush() error = %v with %d commits, want nil with no commit", err, fake.commits)
+	}
+	if err := writer.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if len(fake.committed) != 0 || fake.rollbacks != 1 {
+		t.Errorf("%d rows committed with %d rollbacks, want none with 1 rollback", len(fake.committed), fake.rollbacks)
+	}
+}
+
+func TestDBWriterCommitInterval(t *testing.T) {
+	fake := &fakeDB{}
+	rows := [][]any{{1.0, "a"}, {2.0, "b"}, {3.0, "c"}}
+	if _, err := writeDB(t, fake, DBOptions{BatchSize: 100, CommitInterval: time.Nanosecond}, rows); err != nil {
+		t.Fatalf("WriteRow() error = %v", err)
+	}
+	if fake.commits != 3 {
+		t.Errorf("%d commits, want one per row", fake.commits)
+	}
+}
+
+func TestDBWriterKey(t *testing.T) {
+	writer := NewDBWriter(context.Background(), nil, DBOptions{Key: "identifier"})
+	if err := writer.WriteHeader([]string{"id"}); err == nil {
+		t.Error("WriteHeader() with a key that is not a column error = nil")
+	}
+}
+
+func TestInsertQuery(t *testing.T) {
+	columns := []string{"id", "title"}
+	tests := []struct {
+		name     string
+		opts     DBOptions
+		columns  []string
+		expected string
+	}{
+		{"SQLite", DBOptions{Dialect: SQLite, Table: "t"}, columns, `INSERT INTO "t" ("id", "title") VALUES (?, ?)`},
+		{"PostgreSQL upsert", DBOptions{Table: "t", Key: "id"}, columns,
+			`INSERT INTO "t" ("id", "title") VALUES ($1, $2) ON CONFLICT ("id") DO UPDATE SET "title" = excluded."title"`},
+		{"SQLite upsert of the key only", DBOptions{Dialect: SQLite, Table: "t", Key: "id"}, []string{"id"},
+			`INSERT INTO "t" ("id") VALUES (?) ON CONFLICT ("id") DO NOTHING`},
+		{"MySQL upsert", DBOptions{Dialect: MySQL, Table: "t", Key: "id"}, columns,
+			"INSERT INTO `t` (`id`, `title`) VALUES (?, ?) ON DUPLICATE KEY UPDATE `title` = VALUES(`title`)"},
+		{"MySQL upsert of the key only", DBOptions{Dialect: MySQL, Table: "t", Key: "id"}, []string{"id"},
+			"INSERT INTO `t` (`id`) VALUES (?) ON DUPLICATE KEY UPDATE `id` = `id`"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := insertQuery(tt.opts, tt.columns); got != tt.expected {
+				t.Errorf("insertQuery() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
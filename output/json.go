@@ -0,0 +1,89 @@
+package output
+
+import (
+	"errors"
+	"io"
+
+	"github.com/bluesky0724/jsonstream/encoder"
+)
+
+// JSONWriter writes every row as a JSON object with the column names as keys,
+// either one object per line (NDJSON) or all of them in a JSON array
+// The values keep their JSON types: numbers, booleans and null are not quoted, a JSONValue is nested as it is,
+// and the missing fields are left out
+type JSONWriter struct {
+	encoder *encoder.JSONEncoder
+	lines   bool     // NDJSON instead of an array
+	columns []string // the keys of the objects
+	started bool     // whether the array is open
+}
+
+// NewNDJSONWriter creates a writer of one object per line to w, opts.Indent is ignored
+func NewNDJSONWriter(w io.Writer, opts encoder.Options) *JSONWriter {
+	opts.Indent, opts.Prefix, opts.MultipleValues = "", "", true
+	return &JSONWriter{encoder: encoder.NewJSONEncoder(w, opts), lines: true}
+}
+
+// NewJSONWriter creates a writer of a JSON array of objects to w
+func NewJSONWriter(w io.Writer, opts encoder.Options) *JSONWriter {
+	return &JSONWriter{encoder: encoder.NewJSONEncoder(w, opts)}
+}
+
+// WriteHeader keeps the column names as the keys of the objects
+func (j *JSONWriter) WriteHeader(columns []string) error {
+	j.columns = append([]string(nil), columns...)
+	return j.begin()
+}
+
+// WriteRow writes one object
+func (j *JSONWriter) WriteRow(values []any) error {
+	if len(values) != len(j.columns) {
+		return errors.New("the row does not match the columns")
+	}
+	if err := j.begin(); err != nil {
+		return err
+	}
+	e := j.encoder
+	e.BeginObject()
+	for i, value := range values {
+		if value == Missing {
+			continue
+		}
+		e.Key(j.columns[i])
+		if object, ok := value.(JSONValue); ok {
+			e.RawJSON(object.JSONText())
+		} else {
+			e.Value(value)
+		}
+	}
+	// The encoder keeps the first error
+	return e.EndObject()
+}
+
+// Flush writes the buffered objects to the underlying writer
+func (j *JSONWriter) Flush() error {
+	return j.encoder.Flush()
+}
+
+// Close ends the array, an empty one when there is no row, and flushes the output
+func (j *JSONWriter) Close() error {
+	if j.lines {
+		return j.encoder.Flush()
+	}
+	if err := j.begin(); err != nil {
+		return err
+	}
+	if err := j.encoder.EndArray(); err != nil {
+		return err
+	}
+	return j.encoder.Close()
+}
+
+// begin opens the array before the first object
+func (j *JSONWriter) begin() error {
+	if j.lines || j.started {
+		return nil
+	}
+	j.started = true
+	return j.encoder.BeginArray()
+}
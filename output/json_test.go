@@ -0,0 +1,122 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+
+	"github.com/bluesky0724/jsonstream/encoder"
+)
+
+func TestJSONWriter(t *testing.T) {
+	columns := []string{"id", "publisher.name", "flag"}
+	rows := [][]any{
+		{json.Number("1"), "GSA <gov>", true},
+		{2.5, nil, ""},
+	}
+
+	tests := []struct {
+		name     string
+		create   func(*bytes.Buffer) *JSONWriter
+		rows     [][]any
+		expected string
+	}{
+		{
+			name:     "NDJSON",
+			create:   func(b *bytes.Buffer) *JSONWriter { return NewNDJSONWriter(b, encoder.Options{Indent: "  "}) },
+			rows:     rows,
+			expected: "{\"id\":1,\"publisher.name\":\"GSA <gov>\",\"flag\":true}\n{\"id\":2.5,\"publisher.name\":null,\"flag\":\"\"}\n",
+		},
+		{
+			name:     "NDJSON without rows",
+			create:   func(b *bytes.Buffer) *JSONWriter { return NewNDJSONWriter(b, encoder.Options{}) },
+			expected: "",
+		},
+		{
+			name:     "array",
+			create:   func(b *bytes.Buffer) *JSONWriter { return NewJSONWriter(b, encoder.Options{}) },
+			rows:     rows,
+			expected: `[{"id":1,"publisher.name":"GSA <gov>","flag":true},{"id":2.5,"publisher.name":null,"flag":""}]`,
+		},
+		{
+			name:     "indented array",
+			create:   func(b *bytes.Buffer) *JSONWriter { return NewJSONWriter(b, encoder.Options{Indent: " "}) },
+			rows:     rows[:1],
+			expected: "[\n {\n  \"id\": 1,\n  \"publisher.name\": \"GSA <gov>\",\n  \"flag\": true\n }\n]",
+		},
+		{
+			name:     "array without rows",
+			create:   func(b *bytes.Buffer) *JSONWriter { return NewJSONWriter(b, encoder.Options{}) },
+			expected: "[]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			writer := tt.create(&output)
+			if err := writer.WriteHeader(columns); err != nil {
+				t.Fatalf("WriteHeader() error = %v", err)
+			}
+			for _, row := range tt.rows {
+				if err := writer.WriteRow(row); err != nil {
+					t.Fatalf("WriteRow() error = %v", err)
+				}
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if output.String() != tt.expected {
+				t.Errorf("output = %q, want %q", output.String(), tt.expected)
+			}
+		})
+	}
+}
+
+// rawJSON is a JSONValue, like the objects of the extractor
+type rawJSON string
+
+func (r rawJSON) String() string   { return string(r) }
+func (r rawJSON) JSONText() string { return string(r) }
+
+func TestJSONWriterJSONValues(t *testing.T) {
+	row := []any{json.Number("1"), rawJSON(`{"name":"GSA","tags":["a"]}`)}
+
+	var lines bytes.Buffer
+	writer := NewNDJSONWriter(&lines, encoder.Options{})
+	writer.WriteHeader([]string{"id", "publisher"})
+	if err := writer.WriteRow(row); err != nil {
+		t.Fatalf("WriteRow() error = %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	// The object is nested, like in the bulk documents, instead of quoted
+	if expected := `{"id":1,"publisher":{"name":"GSA","tags":["a"]}}` + "\n"; lines.String() != expected {
+		t.Errorf("output = %q, want %q", lines.String(), expected)
+	}
+
+	// The other writers write its text
+	var records bytes.Buffer
+	csvWriter := NewCSVWriter(csv.NewWriter(&records))
+	csvWriter.WriteHeader([]string{"id", "publisher"})
+	if err := csvWriter.WriteRow(row); err != nil {
+		t.Fatalf("WriteRow() error = %v", err)
+	}
+	csvWriter.Close()
+	if expected := "id,publisher\n1,\"{\"\"name\"\":\"\"GSA\"\",\"\"tags\"\":[\"\"a\"\"]}\"\n"; records.String() != expected {
+		t.Errorf("CSV output = %q, want %q", records.String(), expected)
+	}
+}
+
+func TestJSONWriterErrors(t *testing.T) {
+	writer := NewJSONWriter(&bytes.Buffer{}, encoder.Options{})
+	writer.WriteHeader([]string{"id"})
+	if err := writer.WriteRow([]any{1.0, 2.0}); err == nil {
+		t.Error("WriteRow() with too many values error = nil")
+	}
+	if err := writer.WriteRow([]any{struct{}{}}); err == nil {
+		t.Error("WriteRow() with an unsupported value error = nil")
+	}
+}
@@ -0,0 +1,214 @@
+// Package output writes the rows extracted from JSON documents in several formats
+package output
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/bluesky0724/jsonstream/encoder"
+)
+
+// RowWriter receives the rows of an extraction: the column names once, then the rows
+// The values of a row are in the order of the columns and of the types passed by the parser:
+// string, float64, int64, json.Number, bool or nil, a JSONValue for an object, or Missing for a field missing in the element
+type RowWriter interface {
+	// WriteHeader writes the column names, it is called once before the first row
+	WriteHeader(columns []string) error
+	// WriteRow writes one row, the writer must not keep the slice after the call
+	WriteRow(values []any) error
+	// Flush writes the buffered rows to the underlying writer
+	Flush() error
+	// Close completes the output, e.g. closes a JSON array, and flushes it
+	// It does not close the underlying writer
+	Close() error
+}
+
+// ElementWriter is a RowWriter that takes each base element whole instead of its rows: the extractor calls
+// WriteElement with the values of every column, none for a missing field and an Array for a field in an array,
+// instead of WriteRow with their combinations
+type ElementWriter interface {
+	RowWriter
+	// WriteElement writes one element, the writer must not keep the slices after the call
+	WriteElement(columns [][]any) error
+}
+
+// Array is the values of a field that an array of the element holds, given to an ElementWriter as the single value
+// of its column, so that the documents keep it an array whatever the number of its values
+type Array []any
+
+// JSONValue is a value that is its own JSON text, e.g. an object of the input: the writers of JSON documents
+// may embed the text as it is, the others write it as a string, the text being its String
+type JSONValue interface {
+	fmt.Stringer
+	JSONText() string
+}
+
+// Format selects the RowWriter created by NewWriter
+type Format int
+
+const (
+	Auto   Format = iota // the format matching the extension of the output file, see FormatOf
+	CSV                  // comma-separated values (RFC 4180), see CSVWriter
+	TSV                  // tab-separated values, a CSVWriter with '\t' as delimiter
+	NDJSON               // one JSON object per line, see JSONWriter
+	JSON                 // a JSON array of objects, see JSONWriter
+	Table                // a text table with fixed-width columns, see TableWriter
+	XLSX                 // an Excel workbook, see XLSXWriter
+	SQL                  // a SQL script creating and filling a table, see SQLWriter
+	Bulk                 // the requests of the Elasticsearch and OpenSearch bulk API, see BulkWriter
+)
+
+// String returns the name of the format
+func (f Format) String() string {
+	switch f {
+	case Auto:
+		return "auto"
+	case CSV:
+		return "csv"
+	case TSV:
+		return "tsv"
+	case NDJSON:
+		return "ndjson"
+	case JSON:
+		return "json"
+	case Table:
+		return "table"
+	case XLSX:
+		return "xlsx"
+	case SQL:
+		return "sql"
+	case Bulk:
+		return "bulk"
+	}
+	return "unknown"
+}
+
+// DecodesStrings tells whether the format writes the strings decoded, so that the parser must decode
+// their escape sequences instead of passing them as they are written in the document
+func (f Format) DecodesStrings() bool {
+	return f == NDJSON || f == JSON || f == XLSX || f == SQL || f == Bulk
+}
+
+// FormatOf returns the format matching the extension of a file name, CSV for the unknown extensions:
+// ".tsv" and ".tab" for TSV, ".ndjson" and ".jsonl" for NDJSON, ".json" for JSON, ".txt" for Table,
+// ".xlsx" for XLSX and ".sql" for SQL
+func FormatOf(name string) Format {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".tsv", ".tab":
+		return TSV
+	case ".ndjson", ".jsonl":
+		return NDJSON
+	case ".json":
+		return JSON
+	case ".txt":
+		return Table
+	case ".xlsx":
+		return XLSX
+	case ".sql":
+		return SQL
+	}
+	return CSV
+}
+
+// Options configures the writer created by NewWriter
+type Options struct {
+	// Format selects the writer, CSV when Auto
+	Format Format
+	// Delimiter separates the fields of CSV, ',' by default, and of TSV, '\t' by default
+	Delimiter rune
+	// Encoder configures the JSON of NDJSON and JSON, e.g. the indentation of the array
+	Encoder encoder.Options
+	// Table configures the columns of Table
+	Table TableOptions
+	// XLSX configures the worksheets of XLSX
+	XLSX XLSXOptions
+	// SQL configures the dialect, the table and the statements of SQL
+	SQL SQLOptions
+	// Bulk configures the actions of Bulk
+	Bulk BulkOptions
+	// Nulls sets the text of the missing, null and empty values in CSV, TSV, Table and XLSX
+	Nulls Nulls
+}
+
+// NewWriter creates the RowWriter of opts.Format writing to w
+func NewWriter(w io.Writer, opts Options) (RowWriter, error) {
+	switch opts.Format {
+	case Auto, CSV, TSV:
+		delimiter := opts.Delimiter
+		if delimiter == 0 {
+			delimiter = ','
+			if opts.Format == TSV {
+				delimiter = '\t'
+			}
+		}
+		writer, err := NewDelimitedWriter(w, delimiter)
+		if err != nil {
+			return nil, err
+		}
+		writer.SetNulls(opts.Nulls)
+		return writer, nil
+	case NDJSON:
+		return NewNDJSONWriter(w, opts.Encoder), nil
+	case JSON:
+		return NewJSONWriter(w, opts.Encoder), nil
+	case Table:
+		writer := NewTableWriter(w, opts.Table)
+		writer.SetNulls(opts.Nulls)
+		return writer, nil
+	case XLSX:
+		writer := NewXLSXWriter(w, opts.XLSX)
+		writer.SetNulls(opts.Nulls)
+		return writer, nil
+	case SQL:
+		return NewSQLWriter(w, opts.SQL)
+	case Bulk:
+		return NewBulkWriter(w, opts.Bulk)
+	}
+	return nil, fmt.Errorf("unknown output format %d", opts.Format)
+}
+
+// Missing is the value of a field missing in the element, written like an empty string unless Nulls says otherwise
+// It is null in JSON, e.g. in the spill file of SQLWriter
+var Missing = missingValue{}
+
+// missingValue is the type of Missing
+type missingValue struct{}
+
+// String returns the text of Missing, which is empty
+func (missingValue) String() string { return "" }
+
+// MarshalJSON returns null
+func (missingValue) MarshalJSON() ([]byte, error) { return []byte("null"), nil }
+
+// Nulls sets the text of the cells without content in the formats writing text: CSV, TSV, Table and XLSX,
+// so that they can be told apart, e.g. `\N` for null; all are empty by default
+// The other formats keep their types: the JSON objects leave the missing fields out and write null,
+// SQL and the database rows write NULL for the missing and null values
+type Nulls struct {
+	Missing string // a field missing in the element
+	Null    string // a JSON null
+	Empty   string // an empty string
+}
+
+// Text formats a value as the text of a cell, the values without content as set
+func (n Nulls) Text(value any) string {
+	switch value {
+	case Missing:
+		return n.Missing
+	case nil:
+		return n.Null
+	case "":
+		return n.Empty
+	}
+	return Text(value)
+}
+
+// Text formats a value as the text of a CSV cell, empty for the null and missing values
+func Text(value any) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
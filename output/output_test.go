@@ -0,0 +1,122 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Format
+	}{
+		{"result.csv", CSV},
+		{"result", CSV},
+		{"result.TSV", TSV},
+		{"result.tab", TSV},
+		{"dir.v2/result.ndjson", NDJSON},
+		{"result.jsonl", NDJSON},
+		{"result.json", JSON},
+		{"result.txt", Table},
+		{"result.XLSX", XLSX},
+		{"load.sql", SQL},
+	}
+
+	for _, tt := range tests {
+		if got := FormatOf(tt.name); got != tt.expected {
+			t.Errorf("FormatOf(%q) = %v, want %v", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	columns := []string{"id", "name"}
+	row := []any{json.Number("1"), "a b"}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{"auto", Options{}, "id,name\n1,a b\n"},
+		{"CSV with delimiter", Options{Format: CSV, Delimiter: ';'}, "id;name\n1;a b\n"},
+		{"TSV", Options{Format: TSV}, "id\tname\n1\ta b\n"},
+		{"NDJSON", Options{Format: NDJSON}, "{\"id\":1,\"name\":\"a b\"}\n"},
+		{"JSON", Options{Format: JSON}, `[{"id":1,"name":"a b"}]`},
+		{"table", Options{Format: Table}, "id name\n-- ----\n1  a b\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			writer, err := NewWriter(&output, tt.opts)
+			if err != nil {
+				t.Fatalf("NewWriter() error = %v", err)
+			}
+			if err := writer.WriteHeader(columns); err != nil {
+				t.Fatalf("WriteHeader() error = %v", err)
+			}
+			if err := writer.WriteRow(row); err != nil {
+				t.Fatalf("WriteRow() error = %v", err)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if output.String() != tt.expected {
+				t.Errorf("output = %q, want %q", output.String(), tt.expected)
+			}
+		})
+	}
+
+	if _, err := NewWriter(&bytes.Buffer{}, Options{Format: Format(42)}); err == nil {
+		t.Error("NewWriter() with an unknown format error = nil")
+	}
+	if _, err := NewWriter(&bytes.Buffer{}, Options{Delimiter: '"'}); err == nil {
+		t.Error("NewWriter() with a quote as delimiter error = nil")
+	}
+}
+
+func TestNulls(t *testing.T) {
+	columns := []string{"missing", "null", "empty", "text"}
+	row := []any{Missing, nil, "", "a"}
+	nulls := Nulls{Missing: "?", Null: `\N`, Empty: `""`}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{"CSV", Options{}, "missing,null,empty,text\n,,,a\n"},
+		{"CSV with nulls", Options{Nulls: nulls}, "missing,null,empty,text\n?,\\N,\"\"\"\"\"\",a\n"},
+		{"TSV with nulls", Options{Format: TSV, Nulls: nulls}, "missing\tnull\tempty\ttext\n?\t\\N\t\"\"\"\"\"\"\ta\n"},
+		{"table with nulls", Options{Format: Table, Nulls: nulls}, "missing null empty text\n------- ---- ----- ----\n?       \\N   \"\"    a\n"},
+		{"NDJSON", Options{Format: NDJSON, Nulls: nulls}, "{\"null\":null,\"empty\":\"\",\"text\":\"a\"}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			writer, err := NewWriter(&output, tt.opts)
+			if err != nil {
+				t.Fatalf("NewWriter() error = %v", err)
+			}
+			if err := writer.WriteHeader(columns); err != nil {
+				t.Fatalf("WriteHeader() error = %v", err)
+			}
+			if err := writer.WriteRow(row); err != nil {
+				t.Fatalf("WriteRow() error = %v", err)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if output.String() != tt.expected {
+				t.Errorf("output = %q, want %q", output.String(), tt.expected)
+			}
+		})
+	}
+
+	if text := Text(nil); text != "" {
+		t.Errorf("Text(nil) = %q, want an empty string", text)
+	}
+}
@@ -0,0 +1,419 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultBatchSize is the default number of rows of an INSERT statement
+const defaultBatchSize = 500
+
+// SQLDialect selects the quoting and the types of the SQL statements
+type SQLDialect int
+
+const (
+	PostgreSQL SQLDialect = iota
+	SQLite
+	MySQL
+)
+
+// String returns the name of the dialect
+func (d SQLDialect) String() string {
+	switch d {
+	case PostgreSQL:
+		return "postgresql"
+	case SQLite:
+		return "sqlite"
+	case MySQL:
+		return "mysql"
+	}
+	return "unknown"
+}
+
+// SQLOptions configures a SQLWriter
+type SQLOptions struct {
+	// Dialect selects the quoting of identifiers and strings and the inferred types
+	Dialect SQLDialect
+	// Table is the name of the table, "data" when empty
+	Table string
+	// Types declares the SQL types of some columns by name, e.g. {"modified": "DATE"}
+	// The other types are inferred from the values of the whole output
+	Types map[string]string
+	// SkipCreate leaves out the CREATE TABLE statement, to load an existing table
+	SkipCreate bool
+	// BatchSize is the number of rows of each INSERT statement, 500 when zero
+	BatchSize int
+	// Copy writes a PostgreSQL COPY ... FROM STDIN statement with its data instead of INSERT statements
+	Copy bool
+	// TempDir is where the rows wait while the types are inferred, the default temporary directory when empty
+	TempDir string
+}
+
+// SQLWriter writes the rows as a SQL script: CREATE TABLE, then batched INSERT statements or a COPY
+// The types of the columns that are not declared are inferred from all the rows: BIGINT when all the
+// values are integers, DOUBLE PRECISION when they are numbers, BOOLEAN when they are booleans and TEXT
+// otherwise, with the names of the dialect. Since the table is created before the rows are inserted, the
+// rows are then spilled to a temporary file until Close; they are written as they come when every type is declared
+// Null and missing values are written as NULL, and so are empty strings outside of the text columns,
+// inferred or declared with a text type like TEXT or VARCHAR(n)
+type SQLWriter struct {
+	writer  *bufio.Writer
+	opts    SQLOptions
+	columns []string
+	types   []string     // the SQL types of the columns, empty while inferred
+	kinds   []valueKinds // the kinds of values seen in each column
+	text    []bool       // whether each column quotes every value, the text columns
+	rows    int          // the number of rows of the current INSERT statement
+	spill   *os.File     // the rows waiting for the inferred types, nil when every type is declared
+	spilled *bufio.Writer
+	encoder *json.Encoder
+}
+
+// valueKinds is a set of the kinds of JSON values
+type valueKinds int
+
+const (
+	integerKind valueKinds = 1 << iota
+	floatKind
+	boolKind
+	stringKind
+)
+
+// NewSQLWriter creates a writer of a SQL script to w
+func NewSQLWriter(w io.Writer, opts SQLOptions) (*SQLWriter, error) {
+	if opts.Copy && opts.Dialect != PostgreSQL {
+		return nil, fmt.Errorf("COPY is not supported by %s", opts.Dialect)
+	}
+	if opts.Table == "" {
+		opts.Table = "data"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	return &SQLWriter{writer: bufio.NewWriter(w), opts: opts}, nil
+}
+
+// WriteHeader keeps the column names, and writes the beginning of the script when every type is declared
+func (s *SQLWriter) WriteHeader(columns []string) error {
+	s.columns = append([]string(nil), columns...)
+	s.types = make([]string, len(columns))
+	s.kinds = make([]valueKinds, len(columns))
+	s.text = make([]bool, len(columns))
+	declared := true
+	for i, column := range columns {
+		s.types[i] = s.opts.Types[column]
+		s.text[i] = isTextType(s.types[i])
+		declared = declared && s.types[i] != ""
+	}
+	if declared {
+		return s.begin()
+	}
+
+	spill, err := os.CreateTemp(s.opts.TempDir, "jsonstream-sql-*.json")
+	if err != nil {
+		return fmt.Errorf("error creating spill file: %w", err)
+	}
+	s.spill, s.spilled = spill, bufio.NewWriter(spill)
+	s.encoder = json.NewEncoder(s.spilled)
+	s.encoder.SetEscapeHTML(false)
+	return nil
+}
+
+// WriteRow writes one row, or spills it while the types are inferred
+func (s *SQLWriter) WriteRow(values []any) error {
+	if len(values) != len(s.columns) {
+		return fmt.Errorf("the row has %d values for %d columns", len(values), len(s.columns))
+	}
+	if s.spill == nil {
+		return s.writeRow(values)
+	}
+	for i, value := range values {
+		s.kinds[i] |= kindOf(value)
+	}
+	if err := s.encoder.Encode(values); err != nil {
+		return fmt.Errorf("error writing spill file: %w", err)
+	}
+	return nil
+}
+
+// Flush writes the buffered statements to the underlying writer
+func (s *SQLWriter) Flush() error {
+	if s.spill != nil {
+		return s.spilled.Flush()
+	}
+	return s.writer.Flush()
+}
+
+// Close writes the spilled rows with the inferred types, ends the last statement and flushes the output
+func (s *SQLWriter) Close() error {
+	if s.spill != nil {
+		if err := s.replay(); err != nil {
+			return err
+		}
+	}
+	if s.types == nil {
+		return errors.New("no header written")
+	}
+	if s.opts.Copy {
+		s.writer.WriteString("\\.\n")
+	} else if s.rows > 0 {
+		s.writer.WriteString(";\n")
+	}
+	return s.writer.Flush()
+}
+
+// replay infers the types, writes the beginning of the script and then the spilled rows
+func (s *SQLWriter) replay() error {
+	defer func() {
+		s.spill.Close()
+		os.Remove(s.spill.Name())
+		s.spill = nil
+	}()
+	if err := s.spilled.Flush(); err != nil {
+		return fmt.Errorf("error writing spill file: %w", err)
+	}
+	for i := range s.types {
+		if s.types[i] == "" {
+			s.types[i], s.text[i] = s.inferType(s.kinds[i])
+		}
+	}
+	if err := s.begin(); err != nil {
+		return err
+	}
+
+	if _, err := s.spill.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error reading spill file: %w", err)
+	}
+	decoder := json.NewDecoder(bufio.NewReader(s.spill))
+	decoder.UseNumber()
+	for {
+		var values []any
+		if err := decoder.Decode(&values); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error reading spill file: %w", err)
+		}
+		if err := s.writeRow(values); err != nil {
+			return err
+		}
+	}
+}
+
+// begin writes the CREATE TABLE statement and, for COPY, the beginning of the data
+func (s *SQLWriter) begin() error {
+	if !s.opts.SkipCreate {
+		s.writer.WriteString("CREATE TABLE ")
+		s.writer.WriteString(s.identifier(s.opts.Table))
+		s.writer.WriteString(" (\n")
+		for i, column := range s.columns {
+			s.writer.WriteString("  ")
+			s.writer.WriteString(s.identifier(column))
+			s.writer.WriteByte(' ')
+			s.writer.WriteString(s.types[i])
+			if i < len(s.columns)-1 {
+				s.writer.WriteByte(',')
+			}
+			s.writer.WriteByte('\n')
+		}
+		s.writer.WriteString(");\n")
+	}
+	if s.opts.Copy {
+		s.writer.WriteString("COPY ")
+		s.writer.WriteString(s.identifier(s.opts.Table))
+		s.writer.WriteString(s.columnList())
+		s.writer.WriteString(" FROM STDIN;\n")
+	}
+	return nil
+}
+
+// writeRow writes one row of a COPY or of an INSERT statement, starting the next statement when the batch is full
+func (s *SQLWriter) writeRow(values []any) error {
+	if s.opts.Copy {
+		for i, value := range values {
+			if i > 0 {
+				s.writer.WriteByte('\t')
+			}
+			s.writer.WriteString(s.copyValue(i, value))
+		}
+		_, err := s.writer.WriteString("\n")
+		return err
+	}
+
+	if s.rows == s.opts.BatchSize {
+		s.writer.WriteString(";\n")
+		s.rows = 0
+	}
+	if s.rows == 0 {
+		s.writer.WriteString("INSERT INTO ")
+		s.writer.WriteString(s.identifier(s.opts.Table))
+		s.writer.WriteString(s.columnList())
+		s.writer.WriteString(" VALUES\n(")
+	} else {
+		s.writer.WriteString(",\n(")
+	}
+	s.rows++
+	for i, value := range values {
+		if i > 0 {
+			s.writer.WriteString(", ")
+		}
+		s.writer.WriteString(s.literal(i, value))
+	}
+	_, err := s.writer.WriteString(")")
+	return err
+}
+
+// columnList returns the quoted column names between parentheses, with a leading space
+func (s *SQLWriter) columnList() string {
+	names := make([]string, len(s.columns))
+	for i, column := range s.columns {
+		names[i] = s.identifier(column)
+	}
+	return " (" + strings.Join(names, ", ") + ")"
+}
+
+// inferType returns the type of the dialect for the kinds of values of a column, and whether it is text
+func (s *SQLWriter) inferType(kinds valueKinds) (string, bool) {
+	var names [4]string // integer, float, boolean and text
+	switch s.opts.Dialect {
+	case SQLite:
+		names = [4]string{"INTEGER", "REAL", "INTEGER", "TEXT"}
+	case MySQL:
+		names = [4]string{"BIGINT", "DOUBLE", "BOOLEAN", "LONGTEXT"}
+	default:
+		names = [4]string{"BIGINT", "DOUBLE PRECISION", "BOOLEAN", "TEXT"}
+	}
+	switch {
+	case kinds == 0:
+		return names[3], true
+	case kinds == integerKind:
+		return names[0], false
+	case kinds&^(integerKind|floatKind) == 0:
+		return names[1], false
+	case kinds == boolKind:
+		return names[2], false
+	}
+	return names[3], true
+}
+
+// isTextType tells whether a declared type holds text, e.g. TEXT, LONGTEXT, VARCHAR(255) or CLOB
+func isTextType(name string) bool {
+	name = strings.ToUpper(name)
+	return strings.Contains(name, "CHAR") || strings.Contains(name, "TEXT") || strings.Contains(name, "CLOB")
+}
+
+// literal returns a value as a SQL literal of the dialect
+func (s *SQLWriter) literal(column int, value any) string {
+	if value == nil || value == Missing || (value == "" && !s.text[column]) {
+		return "NULL"
+	}
+	if text, ok := value.(string); ok || s.text[column] {
+		if !ok {
+			text = Text(value)
+		}
+		return s.quote(text)
+	}
+	if flag, ok := value.(bool); ok {
+		switch {
+		case s.opts.Dialect == SQLite && flag:
+			return "1"
+		case s.opts.Dialect == SQLite:
+			return "0"
+		case flag:
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return numberText(value)
+}
+
+// copyValue returns a value as a field of the text format of COPY
+func (s *SQLWriter) copyValue(column int, value any) string {
+	if value == nil || value == Missing || (value == "" && !s.text[column]) {
+		return `\N`
+	}
+	if text, ok := value.(string); ok || s.text[column] {
+		if !ok {
+			text = Text(value)
+		}
+		return copyEscaper.Replace(text)
+	}
+	if _, ok := value.(bool); ok {
+		return Text(value)
+	}
+	return numberText(value)
+}
+
+// copyEscaper escapes the characters that are special in the text format of COPY
+var copyEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// mysqlEscaper escapes the characters that are special in the strings of MySQL
+var mysqlEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\x00", `\0`, "\n", `\n`, "\r", `\r`, "\x1a", `\Z`)
+
+// quote returns a string literal
+func (s *SQLWriter) quote(text string) string {
+	if s.opts.Dialect == MySQL {
+		return "'" + mysqlEscaper.Replace(text) + "'"
+	}
+	return "'" + strings.ReplaceAll(text, "'", "''") + "'"
+}
+
+// identifier returns a quoted table or column name
+func (s *SQLWriter) identifier(name string) string {
+	return quoteIdentifier(s.opts.Dialect, name)
+}
+
+// quoteIdentifier returns a table or column name quoted for the dialect
+func quoteIdentifier(dialect SQLDialect, name string) string {
+	if dialect == MySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// kindOf returns the kind of a value, none for null, a missing field and the empty string
+func kindOf(value any) valueKinds {
+	switch v := value.(type) {
+	case nil, missingValue:
+		return 0
+	case string:
+		if v == "" {
+			return 0
+		}
+		return stringKind
+	case bool:
+		return boolKind
+	case int64, int:
+		return integerKind
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<63 {
+			return integerKind
+		}
+		return floatKind
+	case json.Number:
+		if _, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return integerKind
+		}
+		if _, err := strconv.ParseFloat(string(v), 64); err == nil {
+			return floatKind
+		}
+	}
+	return stringKind
+}
+
+// numberText returns the text of a number
+func numberText(value any) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case json.Number:
+		return string(v)
+	}
+	return Text(value)
+}
@@ -0,0 +1,157 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+)
+
+func TestSQLWriter(t *testing.T) {
+	columns := []string{"id", "name", "score", "active", "tag"}
+	rows := [][]any{
+		{json.Number("1"), "O'Brien", 2.5, true, ""},
+		{2.0, Missing, json.Number("3"), false, json.Number("7")},
+		{int64(3), "back\\slash\nline", nil, nil, "x"},
+	}
+
+	tests := []struct {
+		name     string
+		opts     SQLOptions
+		expected string
+	}{
+		{
+			name: "PostgreSQL",
+			opts: SQLOptions{BatchSize: 2},
+			expected: "CREATE TABLE \"data\" (\n" +
+				"  \"id\" BIGINT,\n" +
+				"  \"name\" TEXT,\n" +
+				"  \"score\" DOUBLE PRECISION,\n" +
+				"  \"active\" BOOLEAN,\n" +
+				"  \"tag\" TEXT\n" +
+				");\n" +
+				"INSERT INTO \"data\" (\"id\", \"name\", \"score\", \"active\", \"tag\") VALUES\n" +
+				"(1, 'O''Brien', 2.5, TRUE, ''),\n" +
+				"(2, NULL, 3, FALSE, '7');\n" +
+				"INSERT INTO \"data\" (\"id\", \"name\", \"score\", \"active\", \"tag\") VALUES\n" +
+				"(3, 'back\\slash\nline', NULL, NULL, 'x');\n",
+		},
+		{
+			name: "SQLite with declared types",
+			opts: SQLOptions{Dialect: SQLite, Table: "my table", Types: map[string]string{"score": "NUMERIC", "tag": "VARCHAR(8)"}},
+			expected: "CREATE TABLE \"my table\" (\n" +
+				"  \"id\" INTEGER,\n" +
+				"  \"name\" TEXT,\n" +
+				"  \"score\" NUMERIC,\n" +
+				"  \"active\" INTEGER,\n" +
+				"  \"tag\" VARCHAR(8)\n" +
+				");\n" +
+				"INSERT INTO \"my table\" (\"id\", \"name\", \"score\", \"active\", \"tag\") VALUES\n" +
+				"(1, 'O''Brien', 2.5, 1, ''),\n" +
+				"(2, NULL, 3, 0, '7'),\n" +
+				"(3, 'back\\slash\nline', NULL, NULL, 'x');\n",
+		},
+		{
+			name: "MySQL without CREATE TABLE",
+			opts: SQLOptions{Dialect: MySQL, Table: "t`1", SkipCreate: true},
+			expected: "INSERT INTO `t``1` (`id`, `name`, `score`, `active`, `tag`) VALUES\n" +
+				"(1, 'O\\'Brien', 2.5, TRUE, ''),\n" +
+				"(2, NULL, 3, FALSE, '7'),\n" +
+				"(3, 'back\\\\slash\\nline', NULL, NULL, 'x');\n",
+		},
+		{
+			name: "PostgreSQL COPY",
+			opts: SQLOptions{Copy: true, SkipCreate: true},
+			expected: "COPY \"data\" (\"id\", \"name\", \"score\", \"active\", \"tag\") FROM STDIN;\n" +
+				"1\tO'Brien\t2.5\ttrue\t\n" +
+				"2\t\\N\t3\tfalse\t7\n" +
+				"3\tback\\\\slash\\nline\t\\N\t\\N\tx\n" +
+				"\\.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.TempDir = t.TempDir()
+			var buffer bytes.Buffer
+			writer, err := NewSQLWriter(&buffer, tt.opts)
+			if err != nil {
+				t.Fatalf("NewSQLWriter() error = %v", err)
+			}
+			if err := writer.WriteHeader(columns); err != nil {
+				t.Fatalf("WriteHeader() error = %v", err)
+			}
+			for _, row := range rows {
+				if err := writer.WriteRow(row); err != nil {
+					t.Fatalf("WriteRow() error = %v", err)
+				}
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if buffer.String() != tt.expected {
+				t.Errorf("output =\n%s\nwant\n%s", buffer.String(), tt.expected)
+			}
+			if files, _ := os.ReadDir(tt.opts.TempDir); len(files) > 0 {
+				t.Errorf("Close() left %d spill files", len(files))
+			}
+		})
+	}
+}
+
+func TestSQLWriterDeclaredTypes(t *testing.T) {
+	// With every type declared, the rows are written as they come
+	dir := t.TempDir()
+	var buffer bytes.Buffer
+	writer, err := NewSQLWriter(&buffer, SQLOptions{Types: map[string]string{"id": "INTEGER"}, TempDir: dir})
+	if err != nil {
+		t.Fatalf("NewSQLWriter() error = %v", err)
+	}
+	writer.WriteHeader([]string{"id"})
+	writer.WriteRow([]any{1.0})
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if expected := "CREATE TABLE \"data\" (\n  \"id\" INTEGER\n);\nINSERT INTO \"data\" (\"id\") VALUES\n(1)"; buffer.String() != expected {
+		t.Errorf("output before Close = %q, want %q", buffer.String(), expected)
+	}
+	if files, _ := os.ReadDir(dir); len(files) > 0 {
+		t.Errorf("WriteRow() spilled %d files", len(files))
+	}
+}
+
+func TestSQLWriterErrors(t *testing.T) {
+	if _, err := NewSQLWriter(&bytes.Buffer{}, SQLOptions{Dialect: MySQL, Copy: true}); err == nil {
+		t.Error("NewSQLWriter() with COPY for MySQL error = nil")
+	}
+
+	writer, err := NewSQLWriter(&bytes.Buffer{}, SQLOptions{TempDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewSQLWriter() error = %v", err)
+	}
+	writer.WriteHeader([]string{"id"})
+	if err := writer.WriteRow([]any{1.0, 2.0}); err == nil {
+		t.Error("WriteRow() with too many values error = nil")
+	}
+	writer.Close()
+
+	writer, _ = NewSQLWriter(&bytes.Buffer{}, SQLOptions{})
+	if err := writer.Close(); err == nil {
+		t.Error("Close() without header error = nil")
+	}
+}
+
+func TestSQLWriterWithoutRows(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := NewSQLWriter(&buffer, SQLOptions{Dialect: MySQL, TempDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewSQLWriter() error = %v", err)
+	}
+	writer.WriteHeader([]string{"id"})
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if expected := "CREATE TABLE `data` (\n  `id` LONGTEXT\n);\n"; buffer.String() != expected {
+		t.Errorf("output = %q, want %q", buffer.String(), expected)
+	}
+}
@@ -0,0 +1,168 @@
+package output
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultSampleRows is the default number of rows measured to size the columns of a table
+const defaultSampleRows = 100
+
+// TableOptions configures a TableWriter
+type TableOptions struct {
+	// Widths fixes the width in characters of the columns, in order
+	// The missing widths are measured on the header and the first SampleRows rows
+	Widths []int
+	// SampleRows is the number of rows held back to measure the columns, 100 when zero
+	SampleRows int
+	// MaxWidth caps the measured widths, unlimited when zero
+	MaxWidth int
+}
+
+// TableWriter writes the rows as a text table with fixed-width columns separated by a space,
+// the header underlined with dashes
+// Only the first rows are held in memory to size the columns: the later cells wider than their
+// column are cut so that every column starts at the same position on every line
+// Line breaks and tabs in the cells are written as spaces
+type TableWriter struct {
+	writer  *bufio.Writer
+	opts    TableOptions
+	nulls   Nulls
+	header  []string
+	sample  [][]string // the rows held back until the widths are known
+	widths  []int      // nil until the widths are known
+	line    strings.Builder
+	started bool // whether the header was written
+}
+
+// NewTableWriter creates a writer of a text table to w
+func NewTableWriter(w io.Writer, opts TableOptions) *TableWriter {
+	if opts.SampleRows <= 0 {
+		opts.SampleRows = defaultSampleRows
+	}
+	return &TableWriter{writer: bufio.NewWriter(w), opts: opts}
+}
+
+// SetNulls sets the text of the missing, null and empty values
+func (t *TableWriter) SetNulls(nulls Nulls) {
+	t.nulls = nulls
+}
+
+// WriteHeader keeps the column names until the widths are known
+func (t *TableWriter) WriteHeader(columns []string) error {
+	t.header = make([]string, len(columns))
+	for i, column := range columns {
+		t.header[i] = cleanCell(column)
+	}
+	return nil
+}
+
+// WriteRow holds back the row while the columns are measured, then writes it
+func (t *TableWriter) WriteRow(values []any) error {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		cells[i] = cleanCell(t.nulls.Text(value))
+	}
+	if t.widths != nil {
+		return t.writeLine(cells)
+	}
+	t.sample = append(t.sample, cells)
+	if len(t.sample) < t.opts.SampleRows {
+		return nil
+	}
+	return t.release()
+}
+
+// Flush writes the rows held back, which fixes the widths, and the buffered lines
+func (t *TableWriter) Flush() error {
+	if err := t.release(); err != nil {
+		return err
+	}
+	return t.writer.Flush()
+}
+
+// Close writes the rows left and flushes the output
+func (t *TableWriter) Close() error {
+	return t.Flush()
+}
+
+// release fixes the widths and writes the header and the rows held back
+func (t *TableWriter) release() error {
+	if t.widths == nil {
+		t.measure()
+	}
+	if !t.started {
+		t.started = true
+		if err := t.writeLine(t.header); err != nil {
+			return err
+		}
+		rule := make([]string, len(t.widths))
+		for i, width := range t.widths {
+			rule[i] = strings.Repeat("-", width)
+		}
+		if err := t.writeLine(rule); err != nil {
+			return err
+		}
+	}
+	for _, cells := range t.sample {
+		if err := t.writeLine(cells); err != nil {
+			return err
+		}
+	}
+	t.sample = nil
+	return nil
+}
+
+// measure sizes the columns on the header and the rows held back
+func (t *TableWriter) measure() {
+	t.widths = make([]int, len(t.header))
+	for i := range t.widths {
+		if i < len(t.opts.Widths) && t.opts.Widths[i] > 0 {
+			t.widths[i] = t.opts.Widths[i]
+			continue
+		}
+		width := utf8.RuneCountInString(t.header[i])
+		for _, cells := range t.sample {
+			if i < len(cells) {
+				width = max(width, utf8.RuneCountInString(cells[i]))
+			}
+		}
+		if t.opts.MaxWidth > 0 {
+			width = min(width, t.opts.MaxWidth)
+		}
+		t.widths[i] = width
+	}
+}
+
+// writeLine writes the cells padded or cut to the widths of their columns, without trailing spaces
+func (t *TableWriter) writeLine(cells []string) error {
+	t.line.Reset()
+	for i, width := range t.widths {
+		var cell string
+		if i < len(cells) {
+			cell = cells[i]
+		}
+		if i > 0 {
+			t.line.WriteByte(' ')
+		}
+		length := utf8.RuneCountInString(cell)
+		if length > width {
+			cell = string([]rune(cell)[:width])
+			length = width
+		}
+		t.line.WriteString(cell)
+		t.line.WriteString(strings.Repeat(" ", width-length))
+	}
+	_, err := t.writer.WriteString(strings.TrimRight(t.line.String(), " ") + "\n")
+	return err
+}
+
+// lineBreaks replaces the line breaks and tabs of the cells, which would break the lines of the table
+var lineBreaks = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ")
+
+// cleanCell makes a cell fit on one line
+func cleanCell(cell string) string {
+	return lineBreaks.Replace(cell)
+}
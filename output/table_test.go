@@ -0,0 +1,81 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTableWriter(t *testing.T) {
+	columns := []string{"id", "title", "flag"}
+	rows := [][]any{
+		{1.0, "Short", true},
+		{22.0, "A much longer title", nil},
+		{333.0, "Multi\nline", false},
+	}
+
+	tests := []struct {
+		name     string
+		opts     TableOptions
+		expected string
+	}{
+		{
+			name: "measured on all rows",
+			expected: "id  title               flag\n" +
+				"--- ------------------- -----\n" +
+				"1   Short               true\n" +
+				"22  A much longer title\n" +
+				"333 Multi line          false\n",
+		},
+		{
+			name: "measured on the first row",
+			opts: TableOptions{SampleRows: 1},
+			expected: "id title flag\n" +
+				"-- ----- ----\n" +
+				"1  Short true\n" +
+				"22 A muc\n" +
+				"33 Multi fals\n",
+		},
+		{
+			name: "fixed and capped widths",
+			opts: TableOptions{Widths: []int{5}, MaxWidth: 4},
+			expected: "id    titl flag\n" +
+				"----- ---- ----\n" +
+				"1     Shor true\n" +
+				"22    A mu\n" +
+				"333   Mult fals\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			writer := NewTableWriter(&output, tt.opts)
+			if err := writer.WriteHeader(columns); err != nil {
+				t.Fatalf("WriteHeader() error = %v", err)
+			}
+			for _, row := range rows {
+				if err := writer.WriteRow(row); err != nil {
+					t.Fatalf("WriteRow() error = %v", err)
+				}
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if output.String() != tt.expected {
+				t.Errorf("output =\n%s\nwant\n%s", output.String(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestTableWriterWithoutRows(t *testing.T) {
+	var output bytes.Buffer
+	writer := NewTableWriter(&output, TableOptions{})
+	writer.WriteHeader([]string{"identifier", "é"})
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if expected := "identifier é\n---------- -\n"; output.String() != expected {
+		t.Errorf("output = %q, want %q", output.String(), expected)
+	}
+}
@@ -0,0 +1,385 @@
+package output
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// xlsxMaxRows is the number of rows of an Excel worksheet, the header included
+	xlsxMaxRows = 1 << 20
+	// xlsxMaxColumns is the number of columns of an Excel worksheet
+	xlsxMaxColumns = 1 << 14
+	// xlsxMaxText is the number of characters a cell can hold, longer strings are cut
+	xlsxMaxText = 32767
+)
+
+// XLSXOptions configures an XLSXWriter
+type XLSXOptions struct {
+	// SheetName names the worksheets, "Sheet" by default: the first one is "Sheet1", then "Sheet2" and so on
+	SheetName string
+	// MaxRows is the number of rows of a worksheet, the header included, before the next one is started:
+	// Excel's limit of 1,048,576 rows when zero or larger
+	MaxRows int
+	// SharedStrings stores each distinct string once in the shared strings table instead of inline in
+	// the cells, which makes smaller files when values repeat but keeps the distinct strings in memory
+	SharedStrings bool
+}
+
+// XLSXWriter writes the rows as an Excel workbook (Office Open XML), streamed to the underlying writer
+// Numbers and booleans are written as typed cells and strings as text, so that Excel keeps leading zeros,
+// dates and line breaks as they are; null, missing and empty values are empty cells unless SetNulls gives them a text
+// The header is the first row of every worksheet, frozen, and a new worksheet is started when one is full
+type XLSXWriter struct {
+	archive *zip.Writer
+	sheet   *bufio.Writer // the worksheet being written, nil before the first one
+	opts    XLSXOptions
+	nulls   Nulls
+	sheets  int      // the number of worksheets started
+	row     int      // the number of rows of the current worksheet
+	header  []string // repeated at the top of every worksheet
+	columns []string // the letters of the columns, e.g. "A", "AB"
+	strings map[string]int
+	shared  []string // the shared strings in the order of their indexes
+}
+
+// NewXLSXWriter creates a writer of an Excel workbook to w
+func NewXLSXWriter(w io.Writer, opts XLSXOptions) *XLSXWriter {
+	if opts.SheetName == "" {
+		opts.SheetName = "Sheet"
+	}
+	if opts.MaxRows <= 0 || opts.MaxRows > xlsxMaxRows {
+		opts.MaxRows = xlsxMaxRows
+	}
+	// A worksheet holds at least the header and one row
+	opts.MaxRows = max(opts.MaxRows, 2)
+	return &XLSXWriter{archive: zip.NewWriter(w), opts: opts, strings: make(map[string]int)}
+}
+
+// SetNulls sets the text of the missing, null and empty values
+func (x *XLSXWriter) SetNulls(nulls Nulls) {
+	x.nulls = nulls
+}
+
+// WriteHeader keeps the column names, they start every worksheet
+func (x *XLSXWriter) WriteHeader(columns []string) error {
+	if len(columns) > xlsxMaxColumns {
+		return fmt.Errorf("too many columns for a worksheet: %d", len(columns))
+	}
+	x.header = append([]string(nil), columns...)
+	x.columns = make([]string, len(columns))
+	for i := range columns {
+		x.columns[i] = columnName(i)
+	}
+	return nil
+}
+
+// WriteRow writes one row, in a new worksheet when the current one is full
+func (x *XLSXWriter) WriteRow(values []any) error {
+	if len(values) > len(x.columns) {
+		return fmt.Errorf("the row has %d values for %d columns", len(values), len(x.columns))
+	}
+	if x.sheet == nil || x.row == x.opts.MaxRows {
+		if err := x.startSheet(); err != nil {
+			return err
+		}
+	}
+	return x.writeRow(values, 0)
+}
+
+// Flush writes the compressed rows to the underlying writer
+func (x *XLSXWriter) Flush() error {
+	if x.sheet != nil {
+		if err := x.sheet.Flush(); err != nil {
+			return err
+		}
+	}
+	return x.archive.Flush()
+}
+
+// Close ends the last worksheet, a sheet with only the header when there is no row, writes the parts
+// of the workbook and the end of the archive
+func (x *XLSXWriter) Close() error {
+	if x.sheet == nil {
+		if err := x.startSheet(); err != nil {
+			return err
+		}
+	}
+	if err := x.endSheet(); err != nil {
+		return err
+	}
+
+	type part struct{ name, body string }
+	parts := []part{
+		{"[Content_Types].xml", x.contentTypes()},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", x.workbook()},
+		{"xl/_rels/workbook.xml.rels", x.workbookRelationships()},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	if x.opts.SharedStrings {
+		parts = append(parts, part{"xl/sharedStrings.xml", x.sharedStrings()})
+	}
+	for _, part := range parts {
+		writer, err := x.archive.Create(part.name)
+		if err != nil {
+			return fmt.Errorf("error writing %s: %w", part.name, err)
+		}
+		if _, err := io.WriteString(writer, part.body); err != nil {
+			return fmt.Errorf("error writing %s: %w", part.name, err)
+		}
+	}
+	return x.archive.Close()
+}
+
+// startSheet ends the current worksheet and starts the next one with the frozen header
+func (x *XLSXWriter) startSheet() error {
+	if x.sheet != nil {
+		if err := x.endSheet(); err != nil {
+			return err
+		}
+	}
+	x.sheets++
+	writer, err := x.archive.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", x.sheets))
+	if err != nil {
+		return fmt.Errorf("error writing worksheet: %w", err)
+	}
+	x.sheet = bufio.NewWriter(writer)
+	x.row = 0
+
+	x.sheet.WriteString(xml.Header)
+	x.sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if len(x.header) > 0 {
+		x.sheet.WriteString(`<sheetViews><sheetView workbookViewId="0">` +
+			`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>` +
+			`</sheetView></sheetViews>`)
+	}
+	x.sheet.WriteString(`<sheetData>`)
+	if len(x.header) == 0 {
+		return nil
+	}
+	header := make([]any, len(x.header))
+	for i, column := range x.header {
+		header[i] = column
+	}
+	return x.writeRow(header, 1)
+}
+
+// endSheet closes the elements of the current worksheet
+func (x *XLSXWriter) endSheet() error {
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := x.sheet.Flush(); err != nil {
+		return fmt.Errorf("error writing worksheet: %w", err)
+	}
+	return nil
+}
+
+// writeRow writes the cells of one row with the given style, 1 being the bold header
+func (x *XLSXWriter) writeRow(values []any, style int) error {
+	x.row++
+	w := x.sheet
+	w.WriteString(`<row r="`)
+	w.WriteString(strconv.Itoa(x.row))
+	w.WriteString(`">`)
+	for i, value := range values {
+		if value == nil || value == "" || value == Missing {
+			if value = x.nulls.Text(value); value == "" {
+				continue // an empty cell is left out
+			}
+		}
+		w.WriteString(`<c r="`)
+		w.WriteString(x.columns[i])
+		w.WriteString(strconv.Itoa(x.row))
+		w.WriteByte('"')
+		if style > 0 {
+			w.WriteString(` s="`)
+			w.WriteString(strconv.Itoa(style))
+			w.WriteByte('"')
+		}
+		x.writeCell(value)
+		w.WriteString(`</c>`)
+	}
+	_, err := w.WriteString(`</row>`)
+	if err != nil {
+		return fmt.Errorf("error writing worksheet: %w", err)
+	}
+	return nil
+}
+
+// writeCell writes the type and the content of a cell, from after the reference to before </c>
+func (x *XLSXWriter) writeCell(value any) {
+	w := x.sheet
+	var number string
+	switch v := value.(type) {
+	case float64:
+		number = strconv.FormatFloat(v, 'g', -1, 64)
+	case int64:
+		number = strconv.FormatInt(v, 10)
+	case int:
+		number = strconv.Itoa(v)
+	case json.Number:
+		if _, err := strconv.ParseFloat(string(v), 64); err == nil {
+			number = string(v)
+		}
+	case bool:
+		w.WriteString(` t="b"><v>`)
+		if v {
+			w.WriteByte('1')
+		} else {
+			w.WriteByte('0')
+		}
+		w.WriteString(`</v>`)
+		return
+	}
+	if number != "" {
+		w.WriteString(`><v>`)
+		w.WriteString(number)
+		w.WriteString(`</v>`)
+		return
+	}
+
+	text := Text(value)
+	if utf8.RuneCountInString(text) > xlsxMaxText {
+		text = string([]rune(text)[:xlsxMaxText])
+	}
+	if x.opts.SharedStrings {
+		index, ok := x.strings[text]
+		if !ok {
+			index = len(x.shared)
+			x.strings[text] = index
+			x.shared = append(x.shared, text)
+		}
+		w.WriteString(` t="s"><v>`)
+		w.WriteString(strconv.Itoa(index))
+		w.WriteString(`</v>`)
+		return
+	}
+	w.WriteString(` t="inlineStr"><is>`)
+	writeText(w, text)
+	w.WriteString(`</is>`)
+}
+
+// writeText writes a <t> element, keeping the spaces around the text
+func writeText(w *bufio.Writer, text string) {
+	if strings.TrimSpace(text) != text {
+		w.WriteString(`<t xml:space="preserve">`)
+	} else {
+		w.WriteString(`<t>`)
+	}
+	// EscapeText only fails when w does, which the later writes report
+	_ = xml.EscapeText(w, []byte(text))
+	w.WriteString(`</t>`)
+}
+
+// contentTypes returns the part listing the content types of the package
+func (x *XLSXWriter) contentTypes() string {
+	var builder strings.Builder
+	builder.WriteString(xml.Header)
+	builder.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= x.sheets; i++ {
+		fmt.Fprintf(&builder, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	if x.opts.SharedStrings {
+		builder.WriteString(`<Override PartName="/xl/sharedStrings.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sharedStrings+xml"/>`)
+	}
+	builder.WriteString(`</Types>`)
+	return builder.String()
+}
+
+// workbook returns the part listing the worksheets
+func (x *XLSXWriter) workbook() string {
+	var builder strings.Builder
+	builder.WriteString(xml.Header)
+	builder.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i := 1; i <= x.sheets; i++ {
+		builder.WriteString(`<sheet name="`)
+		// Writing to a strings.Builder can not fail
+		_ = xml.EscapeText(&builder, []byte(x.sheetName(i)))
+		fmt.Fprintf(&builder, `" sheetId="%d" r:id="rId%d"/>`, i, i)
+	}
+	builder.WriteString(`</sheets></workbook>`)
+	return builder.String()
+}
+
+// workbookRelationships returns the part linking the workbook to the worksheets, the styles and the shared strings
+func (x *XLSXWriter) workbookRelationships() string {
+	const relationships = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/"
+	var builder strings.Builder
+	builder.WriteString(xml.Header)
+	builder.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= x.sheets; i++ {
+		fmt.Fprintf(&builder, `<Relationship Id="rId%d" Type="%sworksheet" Target="worksheets/sheet%d.xml"/>`, i, relationships, i)
+	}
+	fmt.Fprintf(&builder, `<Relationship Id="rId%d" Type="%sstyles" Target="styles.xml"/>`, x.sheets+1, relationships)
+	if x.opts.SharedStrings {
+		fmt.Fprintf(&builder, `<Relationship Id="rId%d" Type="%ssharedStrings" Target="sharedStrings.xml"/>`, x.sheets+2, relationships)
+	}
+	builder.WriteString(`</Relationships>`)
+	return builder.String()
+}
+
+// sharedStrings returns the part holding the shared strings
+func (x *XLSXWriter) sharedStrings() string {
+	var builder strings.Builder
+	writer := bufio.NewWriter(&builder)
+	writer.WriteString(xml.Header)
+	fmt.Fprintf(writer, `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="%d" uniqueCount="%d">`, len(x.shared), len(x.shared))
+	for _, text := range x.shared {
+		writer.WriteString(`<si>`)
+		writeText(writer, text)
+		writer.WriteString(`</si>`)
+	}
+	writer.WriteString(`</sst>`)
+	writer.Flush()
+	return builder.String()
+}
+
+// sheetName returns the name of the i-th worksheet, without the characters Excel does not allow
+// and cut to the 31 characters it allows
+func (x *XLSXWriter) sheetName(i int) string {
+	suffix := strconv.Itoa(i)
+	name := []rune(strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, x.opts.SheetName))
+	if len(name)+len(suffix) > 31 {
+		name = name[:31-len(suffix)]
+	}
+	return string(name) + suffix
+}
+
+// columnName returns the letters of a column from its position, e.g. "A" for 0 and "AA" for 26
+func columnName(position int) string {
+	var name []byte
+	for position++; position > 0; position = (position - 1) / 26 {
+		name = append([]byte{byte('A' + (position-1)%26)}, name...)
+	}
+	return string(name)
+}
+
+// xlsxStyles is the style sheet of the workbook: the default style and a bold one for the header
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
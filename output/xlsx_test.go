@@ -0,0 +1,219 @@
+package output
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// xlsxCell is a cell read back from a worksheet
+type xlsxCell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Style  string `xml:"s,attr"`
+	Value  string `xml:"v"`
+	Inline string `xml:"is>t"`
+}
+
+// xlsxSheet is a worksheet read back from a workbook
+type xlsxSheet struct {
+	Pane *struct {
+		State string `xml:"state,attr"`
+	} `xml:"sheetViews>sheetView>pane"`
+	Rows []struct {
+		Ref   string     `xml:"r,attr"`
+		Cells []xlsxCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX returns the parts of a workbook by name
+func readXLSX(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	parts := make(map[string][]byte)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("Open(%s) error = %v", file.Name, err)
+		}
+		body, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("ReadAll(%s) error = %v", file.Name, err)
+		}
+		parts[file.Name] = body
+	}
+	return parts
+}
+
+// readSheet decodes a worksheet and checks that it is well-formed
+func readSheet(t *testing.T, parts map[string][]byte, name string) xlsxSheet {
+	t.Helper()
+	body, ok := parts[name]
+	if !ok {
+		t.Fatalf("the workbook has no %s", name)
+	}
+	var sheet xlsxSheet
+	if err := xml.Unmarshal(body, &sheet); err != nil {
+		t.Fatalf("xml.Unmarshal(%s) error = %v", name, err)
+	}
+	return sheet
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := NewXLSXWriter(&buffer, XLSXOptions{SheetName: "Data/", MaxRows: 3})
+	if err := writer.WriteHeader([]string{"id", "code", "flag", "note"}); err != nil {
+		t.Fatalf("WriteHeader() error = %v", err)
+	}
+	rows := [][]any{
+		{json.Number("1"), "007", true, " <padded> "},
+		{2.5, "2024-01-02", false, nil},
+		{int64(3), "", nil, "a\nb"},
+	}
+	for _, row := range rows {
+		if err := writer.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	parts := readXLSX(t, buffer.Bytes())
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("the workbook has no %s", name)
+		}
+	}
+	if workbook := string(parts["xl/workbook.xml"]); !strings.Contains(workbook, `name="Data_1"`) || !strings.Contains(workbook, `name="Data_2"`) {
+		t.Errorf("workbook.xml = %s, want the sheets Data_1 and Data_2", workbook)
+	}
+
+	first := readSheet(t, parts, "xl/worksheets/sheet1.xml")
+	if first.Pane == nil || first.Pane.State != "frozen" {
+		t.Error("the header of sheet1 is not frozen")
+	}
+	expected := [][]xlsxCell{
+		{
+			{Ref: "A1", Type: "inlineStr", Style: "1", Inline: "id"},
+			{Ref: "B1", Type: "inlineStr", Style: "1", Inline: "code"},
+			{Ref: "C1", Type: "inlineStr", Style: "1", Inline: "flag"},
+			{Ref: "D1", Type: "inlineStr", Style: "1", Inline: "note"},
+		},
+		{
+			{Ref: "A2", Value: "1"},
+			{Ref: "B2", Type: "inlineStr", Inline: "007"},
+			{Ref: "C2", Type: "b", Value: "1"},
+			{Ref: "D2", Type: "inlineStr", Inline: " <padded> "},
+		},
+		{
+			{Ref: "A3", Value: "2.5"},
+			{Ref: "B3", Type: "inlineStr", Inline: "2024-01-02"},
+			{Ref: "C3", Type: "b", Value: "0"},
+		},
+	}
+	if len(first.Rows) != len(expected) {
+		t.Fatalf("sheet1 has %d rows, want %d", len(first.Rows), len(expected))
+	}
+	for i, row := range first.Rows {
+		if !reflect.DeepEqual(row.Cells, expected[i]) {
+			t.Errorf("sheet1 row %s = %+v, want %+v", row.Ref, row.Cells, expected[i])
+		}
+	}
+
+	// The third row is in a second worksheet, after the header again
+	second := readSheet(t, parts, "xl/worksheets/sheet2.xml")
+	if len(second.Rows) != 2 || second.Rows[0].Cells[0].Inline != "id" {
+		t.Fatalf("sheet2 rows = %+v, want the header and one row", second.Rows)
+	}
+	expectedRow := []xlsxCell{{Ref: "A2", Value: "3"}, {Ref: "D2", Type: "inlineStr", Inline: "a\nb"}}
+	if !reflect.DeepEqual(second.Rows[1].Cells, expectedRow) {
+		t.Errorf("sheet2 row 2 = %+v, want %+v", second.Rows[1].Cells, expectedRow)
+	}
+}
+
+func TestXLSXWriterNulls(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := NewXLSXWriter(&buffer, XLSXOptions{})
+	writer.SetNulls(Nulls{Null: "NULL"})
+	if err := writer.WriteHeader([]string{"missing", "null", "empty"}); err != nil {
+		t.Fatalf("WriteHeader() error = %v", err)
+	}
+	if err := writer.WriteRow([]any{Missing, nil, ""}); err != nil {
+		t.Fatalf("WriteRow() error = %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	sheet := readSheet(t, readXLSX(t, buffer.Bytes()), "xl/worksheets/sheet1.xml")
+	expected := []xlsxCell{{Ref: "B2", Type: "inlineStr", Inline: "NULL"}}
+	if len(sheet.Rows) != 2 || !reflect.DeepEqual(sheet.Rows[1].Cells, expected) {
+		t.Errorf("sheet1 rows = %+v, want the null as text only", sheet.Rows)
+	}
+}
+
+func TestXLSXWriterSharedStrings(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := NewXLSXWriter(&buffer, XLSXOptions{SharedStrings: true})
+	writer.WriteHeader([]string{"keyword"})
+	for _, keyword := range []string{"health", "data", "health"} {
+		if err := writer.WriteRow([]any{keyword}); err != nil {
+			t.Fatalf("WriteRow() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	parts := readXLSX(t, buffer.Bytes())
+	var table struct {
+		Items []string `xml:"si>t"`
+	}
+	if err := xml.Unmarshal(parts["xl/sharedStrings.xml"], &table); err != nil {
+		t.Fatalf("xml.Unmarshal(sharedStrings.xml) error = %v", err)
+	}
+	if expected := []string{"keyword", "health", "data"}; !reflect.DeepEqual(table.Items, expected) {
+		t.Errorf("shared strings = %q, want %q", table.Items, expected)
+	}
+
+	sheet := readSheet(t, parts, "xl/worksheets/sheet1.xml")
+	var indexes []string
+	for _, row := range sheet.Rows {
+		indexes = append(indexes, row.Cells[0].Value)
+	}
+	if expected := []string{"0", "1", "2", "1"}; !reflect.DeepEqual(indexes, expected) {
+		t.Errorf("string indexes = %q, want %q", indexes, expected)
+	}
+}
+
+func TestXLSXWriterWithoutRows(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := NewXLSXWriter(&buffer, XLSXOptions{})
+	writer.WriteHeader([]string{"id"})
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	sheet := readSheet(t, readXLSX(t, buffer.Bytes()), "xl/worksheets/sheet1.xml")
+	if len(sheet.Rows) != 1 {
+		t.Errorf("sheet1 has %d rows, want the header only", len(sheet.Rows))
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	tests := map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA", 16383: "XFD"}
+	for position, expected := range tests {
+		if got := columnName(position); got != expected {
+			t.Errorf("columnName(%d) = %q, want %q", position, got, expected)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"strings"
+	"unsafe"
+)
+
+// NewBytesParser creates a JSON parser over input that is already in memory
+// The parser reads the bytes in place instead of copying them chunk by chunk into its buffer,
+// so data must not be modified until parsing is finished
+// Strings passed to the parse handler are copied unless Options.ZeroCopy is set,
+// in which case they reference data directly and are only valid as long as data is
+func NewBytesParser(data []byte, opts Options, parseHandler func(any) error) (*JSONParser, error) {
+	parser := &JSONParser{
+		reader:       nil, // there is nothing more to stream
+		options:      opts,
+		buffer:       unsafe.String(unsafe.SliceData(data), len(data)),
+		aliased:      true,
+		pos:          0,
+		NowField:     "",
+		parseHandler: parseHandler,
+	}
+
+	if err := parser.consume(); err != nil {
+		return nil, err
+	}
+
+	return parser, nil
+}
+
+// ParseBytes parses a whole JSON document held in memory, calling parseHandler like Parse does
+func ParseBytes(data []byte, opts Options, parseHandler func(any) error) error {
+	parser, err := NewBytesParser(data, opts, parseHandler)
+	if err != nil {
+		return err
+	}
+	return parser.Parse()
+}
+
+// ParseFile memory-maps the file at path and parses it in place with ParseBytes
+// With Options.ZeroCopy the strings passed to parseHandler must not be used after ParseFile returns,
+// since the file is unmapped at that point
+func ParseFile(path string, opts Options, parseHandler func(any) error) error {
+	file, err := MapFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return ParseBytes(file.Bytes(), opts, parseHandler)
+}
+
+// detach copies a string taken from the buffer when the buffer is borrowed from the caller
+// and the caller did not opt in to zero-copy values
+func (p *JSONParser) detach(value string) string {
+	if p.aliased && !p.options.ZeroCopy {
+		return strings.Clone(value)
+	}
+	return value
+}
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestParseBytes(t *testing.T) {
+	inputs := []string{
+		`{"data":[{"id":1,"name":"John"},{"id":2,"name":"Jane"}]}`,
+		`  [1, "two", true, null, {"a": [false]}]  `,
+		`"simple string"`,
+		`42.5`,
+	}
+
+	for _, input := range inputs {
+		var streamed, inPlace []any
+		parser, _ := NewJSONParser(bufio.NewReader(strings.NewReader(input)), func(v any) error {
+			streamed = append(streamed, v)
+			return nil
+		})
+		if err := parser.Parse(); err != nil {
+			t.Fatalf("Parse(%q) error = %v", input, err)
+		}
+
+		err := ParseBytes([]byte(input), Options{}, func(v any) error {
+			inPlace = append(inPlace, v)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ParseBytes(%q) error = %v", input, err)
+		}
+		if !CompareArray(inPlace, streamed) {
+			t.Errorf("ParseBytes(%q) = %v, want %v", input, inPlace, streamed)
+		}
+	}
+}
+
+// references reports whether the string points into the memory of data
+func references(value string, data []byte) bool {
+	start := uintptr(unsafe.Pointer(unsafe.SliceData(data)))
+	pointer := uintptr(unsafe.Pointer(unsafe.StringData(value)))
+	return pointer >= start && pointer < start+uintptr(len(data))
+}
+
+func TestParseBytesZeroCopy(t *testing.T) {
+	data := []byte(`{"name":"John","tags":["a","b"]}`)
+
+	tests := []struct {
+		name     string
+		zeroCopy bool
+	}{
+		{name: "values are copied by default", zeroCopy: false},
+		{name: "values reference the input with ZeroCopy", zeroCopy: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ParseBytes(data, Options{ZeroCopy: tt.zeroCopy}, func(v any) error {
+				if s, ok := v.(string); ok && references(s, data) != tt.zeroCopy {
+					t.Errorf("value %q references the input = %v, want %v", s, !tt.zeroCopy, tt.zeroCopy)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("ParseBytes() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestParseBytesErrors(t *testing.T) {
+	inputs := []string{``, `{"a":`, `[1,2`, `{} x`}
+	for _, input := range inputs {
+		if err := ParseBytes([]byte(input), Options{Strict: true}, func(v any) error { return nil }); err == nil {
+			t.Errorf("ParseBytes(%q) expected an error", input)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package parser
+
+// EventKind defines the kind of a structural event
+type EventKind int
+
+const (
+	ObjectStart EventKind = iota // '{' was read
+	ObjectEnd                    // '}' was read
+	ArrayStart                   // '[' was read
+	ArrayEnd                     // ']' was read
+	Key                          // an object key and its ':' were read
+	Value                        // a string, number, boolean or null was read
+)
+
+// String returns the name of the event kind
+func (k EventKind) String() string {
+	switch k {
+	case ObjectStart:
+		return "ObjectStart"
+	case ObjectEnd:
+		return "ObjectEnd"
+	case ArrayStart:
+		return "ArrayStart"
+	case ArrayEnd:
+		return "ArrayEnd"
+	case Key:
+		return "Key"
+	case Value:
+		return "Value"
+	}
+	return "Unknown"
+}
+
+// Event is a token of the document reported to the event handler
+// Unlike the parse handler, which only sees the primitive values and a nil at the end of each container,
+// the event handler sees the whole structure, so the document can be rebuilt from the events
+// NowField holds the field of the value the event belongs to while the handler runs
+type Event struct {
+	Kind  EventKind
+	Key   string // the key of Key events
+	Value any    // the value of Value events, typed like the values of the parse handler
+}
+
+// SetEventHandler sets the function called with the structural events, next to the parse handler
+// Events are reported in document order; for each primitive value, the Value event comes
+// right before the parse handler is called
+func (p *JSONParser) SetEventHandler(eventHandler func(Event) error) {
+	p.eventHandler = eventHandler
+}
+
+// emit reports a structural event to the event handler, if any
+func (p *JSONParser) emit(event Event) error {
+	if p.eventHandler == nil {
+		return nil
+	}
+	return p.eventHandler(event)
+}
+
+// handleValue reports a primitive value to the event handler and then to the parse handler
+func (p *JSONParser) handleValue(value any) error {
+	if err := p.emit(Event{Kind: Value, Value: value}); err != nil {
+		return err
+	}
+	p.ending = Value
+	return p.callParseHandler(value)
+}
+
+// callParseHandler calls the parse handler, if any
+// Besides the primitive values, it gets a nil value at the end of each object and array
+func (p *JSONParser) callParseHandler(value any) error {
+	if p.parseHandler == nil {
+		return nil
+	}
+	return p.parseHandler(value)
+}
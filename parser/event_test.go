@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestJSONParserEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "empty object",
+			input:    `{}`,
+			expected: []string{"ObjectStart ", "ObjectEnd "},
+		},
+		{
+			name:  "object with array",
+			input: `{"data":[{"id":1},"x"]}`,
+			expected: []string{
+				"ObjectStart ",
+				"Key .data data",
+				"ArrayStart .data",
+				"ObjectStart .data",
+				"Key .data.id id",
+				"Value .data.id 1",
+				"ObjectEnd .data",
+				"Value .data x",
+				"ArrayEnd .data",
+				"ObjectEnd ",
+			},
+		},
+		{
+			name:     "lone value",
+			input:    `null`,
+			expected: []string{"Value  <nil>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result []string
+			var values []any
+			p, err := NewParser(strings.NewReader(tt.input), Options{}, func(v any) error {
+				values = append(values, v)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("NewParser() error = %v", err)
+			}
+			p.SetEventHandler(func(e Event) error {
+				switch e.Kind {
+				case Key:
+					result = append(result, fmt.Sprintf("%v %s %s", e.Kind, p.NowField, e.Key))
+				case Value:
+					result = append(result, fmt.Sprintf("%v %s %v", e.Kind, p.NowField, e.Value))
+				default:
+					result = append(result, fmt.Sprintf("%v %s", e.Kind, p.NowField))
+				}
+				return nil
+			})
+			if err := p.Parse(); err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			if strings.Join(result, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("events = %q, want %q", result, tt.expected)
+			}
+			// The parse handler still gets its values next to the events
+			if len(values) == 0 {
+				t.Error("parse handler was not called")
+			}
+		})
+	}
+}
@@ -18,35 +18,57 @@ func init() {
 		if p.pos >= len(p.buffer) {
 			return fmt.Errorf("unexpected end of input while parsing array")
 		}
-		p.incrementPos()
-		p.consume()
+		if err := p.incrementPos(); err != nil {
+			return err
+		}
+		if err := p.consume(); err != nil {
+			return err
+		}
 
-		for {
+		for index := 0; ; index++ {
 			// Check if we've reached the end of the array
 			// Need this logic for the empty array
 			if p.pos >= len(p.buffer) {
 				return fmt.Errorf("unexpected end of input: array was not closed")
 			}
 			if p.buffer[p.pos] == ']' {
-				p.incrementPos()
-				p.consume()
+				if err := p.incrementPos(); err != nil {
+					return err
+				}
+				if err := p.consume(); err != nil {
+					return err
+				}
 				break
 			}
 
 			// Parse the next value in the array
-			if err := p.Parse(); err != nil {
+			p.pushIndex(index)
+			if err := p.parseValue(); err != nil {
 				return err
 			}
+			p.popSegment()
 
 			if p.pos >= len(p.buffer) {
 				return fmt.Errorf("unexpected end of input: array was not closed")
 			}
 			if p.buffer[p.pos] == ',' {
-				p.incrementPos()
-				p.consume()
+				if err := p.incrementPos(); err != nil {
+					return err
+				}
+				if err := p.consume(); err != nil {
+					return err
+				}
+				// In strict mode a trailing comma like [1,] is not accepted
+				if p.options.Strict && p.pos < len(p.buffer) && p.buffer[p.pos] == ']' {
+					return fmt.Errorf("unexpected ']' after ',' in array")
+				}
 			} else if p.buffer[p.pos] == ']' {
-				p.incrementPos()
-				p.consume()
+				if err := p.incrementPos(); err != nil {
+					return err
+				}
+				if err := p.consume(); err != nil {
+					return err
+				}
 				break
 			} else {
 				return fmt.Errorf("expected ',' or ']' to be a valid array")
@@ -54,6 +76,7 @@ func init() {
 		}
 
 		// Call parse handler with nil value since array has no value
-		return p.parseHandler(nil)
+		p.ending = ArrayEnd
+		return p.callParseHandler(nil)
 	}
 }
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -15,7 +17,7 @@ var JSONNumber = &JSONValueType{}
 // * May contain a decimal point '.'
 // * May contain an exponent indicator ('e' or 'E') followed by an optional sign
 // * Must be a valid numeric value parsable to float64
-// * Stores a primitive numeric value
+// * Stores a primitive numeric value, typed according to Options.NumberMode
 func init() {
 	JSONNumber.ParseValue = func(p *JSONParser) error {
 		// Check if the current character is a valid number start (digit or minus sign)
@@ -27,16 +29,26 @@ func init() {
 		// Continue parsing while characters are valid number components (digits, signs, exponents, or decimal point)
 		// loose validation check since we parse float the value later
 		for p.pos < len(p.buffer) && (unicode.IsDigit(rune(p.buffer[p.pos])) || strings.ContainsRune("-+eE.", rune(p.buffer[p.pos]))) {
-			p.incrementPos()
+			if err := p.incrementPos(); err != nil {
+				return err
+			}
 		}
 
-		number, err := strconv.ParseFloat(p.buffer[start:p.pos], 64)
+		text := p.buffer[start:p.pos]
+		if p.options.Strict && !IsValidNumber(text) {
+			return fmt.Errorf("invalid number %q", text)
+		}
+		if p.options.NumberMode == NumberText {
+			text = p.detach(text) // the text itself is passed to the parse handler
+		}
+
+		number, err := convertNumber(text, p.options.NumberMode)
 		if err != nil {
 			return fmt.Errorf("invalid number")
 		}
 
 		// Handle the parsed number value
-		err = p.parseHandler(number)
+		err = p.handleValue(number)
 		if err != nil {
 			return err
 		}
@@ -49,3 +61,62 @@ func init() {
 		return nil
 	}
 }
+
+// convertNumber converts the text of a number to the Go type selected by the number mode
+func convertNumber(text string, mode NumberMode) (any, error) {
+	// The text is always checked with ParseFloat so that every mode accepts the same inputs,
+	// except that the text mode keeps numbers out of the float64 range
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil && !(mode == NumberText && errors.Is(err, strconv.ErrRange)) {
+		return nil, err
+	}
+
+	switch mode {
+	case NumberText:
+		return json.Number(text), nil
+	case NumberInt64:
+		if integer, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return integer, nil
+		}
+	}
+	return number, nil
+}
+
+// IsValidNumber checks the number grammar of RFC 8259:
+// an optional minus, an integer part without leading zeros, an optional fraction and an optional exponent
+func IsValidNumber(text string) bool {
+	i := 0
+	digits := func() int {
+		n := 0
+		for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+			i++
+			n++
+		}
+		return n
+	}
+
+	if i < len(text) && text[i] == '-' {
+		i++
+	}
+	if i < len(text) && text[i] == '0' {
+		i++
+	} else if digits() == 0 {
+		return false
+	}
+	if i < len(text) && text[i] == '.' {
+		i++
+		if digits() == 0 {
+			return false
+		}
+	}
+	if i < len(text) && (text[i] == 'e' || text[i] == 'E') {
+		i++
+		if i < len(text) && (text[i] == '+' || text[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+	return i == len(text)
+}
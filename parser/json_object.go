@@ -23,6 +23,9 @@ func init() {
 		}
 
 		for {
+			if p.pos >= len(p.buffer) {
+				return fmt.Errorf("unexpected end of input: object was not closed")
+			}
 			// Check for end of object: for the empty object
 			if p.buffer[p.pos] == '}' {
 				if err := p.incrementPos(); err != nil {
@@ -42,12 +45,20 @@ func init() {
 
 			// Navigate to correct path and parse value
 			p.goForward(key)
-			if err := p.Parse(); err != nil {
+			p.pushKey(key)
+			if err := p.emit(Event{Kind: Key, Key: key}); err != nil {
+				return err
+			}
+			if err := p.parseValue(); err != nil {
 				return err
 			}
 			p.goBackward(key)
+			p.popSegment()
 
 			// Handle comma separator or end of object
+			if p.pos >= len(p.buffer) {
+				return fmt.Errorf("unexpected end of input: object was not closed")
+			}
 			if p.buffer[p.pos] == ',' {
 				if err := p.incrementPos(); err != nil {
 					return err
@@ -55,6 +66,10 @@ func init() {
 				if err := p.consume(); err != nil {
 					return err
 				}
+				// In strict mode a trailing comma like {"a":1,} is not accepted
+				if p.options.Strict && p.pos < len(p.buffer) && p.buffer[p.pos] == '}' {
+					return fmt.Errorf("unexpected '}' after ',' in object")
+				}
 			} else if p.buffer[p.pos] == '}' {
 				if err := p.incrementPos(); err != nil {
 					return err
@@ -71,7 +86,8 @@ func init() {
 		// Call parse handler with nil value
 		// Objects and Arrays are considered as they have no significant data
 		// The target data is always the primitive values like string, number, boolean and null
-		if err := p.parseHandler(nil); err != nil {
+		p.ending = ObjectEnd
+		if err := p.callParseHandler(nil); err != nil {
 			return err
 		}
 		return nil
@@ -84,27 +100,10 @@ func parseKey(p *JSONParser) (string, error) {
 	if p.buffer[p.pos] != '"' {
 		return "", fmt.Errorf("expected string for the object key")
 	}
-	if err := p.incrementPos(); err != nil {
-		return "", err
-	}
 
-	// Find the end of the string
-	start := p.pos
-	for p.buffer[p.pos] != '"' {
-		// Handle escaped characters
-		if p.buffer[p.pos] == '\\' {
-			if err := p.incrementPos(); err != nil {
-				return "", err
-			}
-		}
-		if err := p.incrementPos(); err != nil {
-			return "", err
-		}
-	}
-	result := p.buffer[start:p.pos]
-
-	// Move past closing quote and whitespace
-	if err := p.incrementPos(); err != nil {
+	// Find the end of the string and move past closing quote
+	result, err := scanString(p)
+	if err != nil {
 		return "", err
 	}
 	if err := p.consume(); err != nil {
@@ -112,7 +111,7 @@ func parseKey(p *JSONParser) (string, error) {
 	}
 
 	// Ensure key is followed by colon
-	if p.buffer[p.pos] != ':' {
+	if p.pos >= len(p.buffer) || p.buffer[p.pos] != ':' {
 		return "", fmt.Errorf("expected ':' after key string")
 	}
 
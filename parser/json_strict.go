@@ -17,7 +17,7 @@ func init() {
 			return err
 		}
 
-		return p.parseHandler(true)
+		return p.handleValue(true)
 	}
 
 	JSONFalse.ParseValue = func(p *JSONParser) error {
@@ -26,7 +26,7 @@ func init() {
 			return err
 		}
 
-		return p.parseHandler(false)
+		return p.handleValue(false)
 	}
 
 	JSONNull.ParseValue = func(p *JSONParser) error {
@@ -35,17 +35,17 @@ func init() {
 			return err
 		}
 
-		return p.parseHandler(nil)
+		return p.handleValue(nil)
 	}
 }
 
 // strictCheck verifies that the input matches the expected string exactly
-// It panics if there is a mismatch
+// It returns an error if there is a mismatch
 func strictCheck(p *JSONParser, expected string) error {
 	position := 0
 	for position < len(expected) {
 		if p.pos >= len(p.buffer) || p.buffer[p.pos] != expected[position] {
-			panic(fmt.Sprintf("expected '%s'", expected))
+			return fmt.Errorf("expected '%s' at %s", expected, p.NowField)
 		}
 		if err := p.incrementPos(); err != nil { // If buffer limit is reached, load more data
 			return err
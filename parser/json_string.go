@@ -1,45 +1,34 @@
 package parser
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
 // JSONString represents a JSON string value type that:
 // * Begins with an opening double quote '"'
 // * Ends with a closing double quote '"'
 // * May contain any Unicode characters
 // * May contain escape sequences like \", \\, \n, \r, \t, etc.
-// * Stores the raw string value between the quotes
+// * Stores the raw string value between the quotes, or the decoded value with Options.DecodeStrings
 var JSONString = &JSONValueType{}
 
 func init() {
 	JSONString.ParseValue = func(p *JSONParser) error {
-		// Skip opening quote '"'
-		if err := p.incrementPos(); err != nil {
+		result, err := scanString(p)
+		if err != nil {
 			return err
 		}
 
-		// Track start position of string content to extract the string
-		start := p.pos
-		// Continue until closing quote is found
-		for p.buffer[p.pos] != '"' {
-			// Handle escape sequences
-			if p.buffer[p.pos] == '\\' {
-				if err := p.incrementPos(); err != nil {
-					return err
-				}
-			}
-			if err := p.incrementPos(); err != nil {
-				return err
-			}
-		}
-		result := p.buffer[start:p.pos]
-
 		// Process the parsed string value
-		if err := p.parseHandler(result); err != nil {
+		if err := p.handleValue(result); err != nil {
 			return err
 		}
 
-		// Skip closing quote and consume any whitespace
-		if err := p.incrementPos(); err != nil {
-			return err
-		}
+		// Consume any whitespace after the closing quote
 		if err := p.consume(); err != nil {
 			return err
 		}
@@ -47,3 +36,131 @@ func init() {
 		return nil
 	}
 }
+
+// scanString reads the string starting at the opening quote under the pointer
+// and leaves the pointer right after the closing quote
+// It is shared by string values and object keys
+func scanString(p *JSONParser) (string, error) {
+	// Skip opening quote '"'
+	if err := p.incrementPos(); err != nil {
+		return "", err
+	}
+
+	// Track start position of string content to extract the string
+	start := p.pos
+	escaped := false
+	// Continue until closing quote is found
+	for {
+		if p.pos >= len(p.buffer) {
+			return "", fmt.Errorf("unexpected end of input: string was not closed")
+		}
+		c := p.buffer[p.pos]
+		if c == '"' {
+			break
+		}
+		if p.options.Strict && c < 0x20 {
+			return "", fmt.Errorf("invalid control character %q in string", c)
+		}
+		// Handle escape sequences
+		if c == '\\' {
+			escaped = true
+			if err := p.incrementPos(); err != nil {
+				return "", err
+			}
+			if p.pos >= len(p.buffer) {
+				return "", fmt.Errorf("unexpected end of input: string was not closed")
+			}
+		}
+		if err := p.incrementPos(); err != nil {
+			return "", err
+		}
+		if p.options.MaxStringLength > 0 && p.pos-start > p.options.MaxStringLength {
+			return "", fmt.Errorf("string longer than %d bytes at %s", p.options.MaxStringLength, p.NowField)
+		}
+	}
+	result := p.buffer[start:p.pos]
+
+	// Skip closing quote
+	if err := p.incrementPos(); err != nil {
+		return "", err
+	}
+
+	if escaped && (p.options.DecodeStrings || p.options.Strict) {
+		decoded, err := decodeString(result, p.options.Strict)
+		if err != nil {
+			return "", err
+		}
+		if p.options.DecodeStrings {
+			return decoded, nil // decoding already made a copy
+		}
+	}
+	return p.detach(result), nil
+}
+
+// decodeString replaces the escape sequences of a raw JSON string with the characters they stand for
+// In strict mode an invalid escape sequence is an error, otherwise the escaped character is kept as is
+func decodeString(raw string, strict bool) (string, error) {
+	var builder strings.Builder
+	builder.Grow(len(raw))
+
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c != '\\' || i+1 >= len(raw) {
+			builder.WriteByte(c)
+			continue
+		}
+		i++
+		switch raw[i] {
+		case '"', '\\', '/':
+			builder.WriteByte(raw[i])
+		case 'b':
+			builder.WriteByte('\b')
+		case 'f':
+			builder.WriteByte('\f')
+		case 'n':
+			builder.WriteByte('\n')
+		case 'r':
+			builder.WriteByte('\r')
+		case 't':
+			builder.WriteByte('\t')
+		case 'u':
+			r, ok := decodeHex(raw, i+1)
+			if !ok {
+				if strict {
+					return "", fmt.Errorf("invalid escape sequence '\\u' in string")
+				}
+				builder.WriteByte('u')
+				continue
+			}
+			i += 4
+			// Characters outside the Basic Multilingual Plane are written as a surrogate pair
+			if utf16.IsSurrogate(r) && strings.HasPrefix(raw[i+1:], "\\u") {
+				if low, ok := decodeHex(raw, i+3); ok {
+					if pair := utf16.DecodeRune(r, low); pair != utf8.RuneError {
+						r = pair
+						i += 6
+					}
+				}
+			}
+			builder.WriteRune(r)
+		default:
+			if strict {
+				return "", fmt.Errorf("invalid escape sequence '\\%c' in string", raw[i])
+			}
+			builder.WriteByte(raw[i])
+		}
+	}
+	return builder.String(), nil
+}
+
+// decodeHex reads the four hexadecimal digits of a \u escape sequence starting at index i
+func decodeHex(raw string, i int) (rune, bool) {
+	if i+4 > len(raw) {
+		return 0, false
+	}
+	value, err := strconv.ParseUint(raw[i:i+4], 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return rune(value), true
+}
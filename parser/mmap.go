@@ -0,0 +1,30 @@
+package parser
+
+// MappedFile is a read-only view of a whole file in memory
+// On Linux the file is mapped with mmap(2), elsewhere it is read into memory
+type MappedFile struct {
+	data   []byte
+	mapped bool // whether data must be unmapped on Close
+}
+
+// MapFile maps the file at path into memory for NewBytesParser or ParseBytes
+// The file must not be truncated while it is mapped
+func MapFile(path string) (*MappedFile, error) {
+	return mapFile(path)
+}
+
+// Bytes returns the content of the file, valid until Close is called
+func (m *MappedFile) Bytes() []byte {
+	return m.data
+}
+
+// Close releases the mapping, after which the bytes and any zero-copy value taken from them are invalid
+func (m *MappedFile) Close() error {
+	data := m.data
+	m.data = nil
+	if !m.mapped || data == nil {
+		return nil
+	}
+	m.mapped = false
+	return unmap(data)
+}
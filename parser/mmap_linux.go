@@ -0,0 +1,43 @@
+//go:build linux
+
+package parser
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mapFile maps the whole file read-only with mmap(2)
+func mapFile(path string) (*MappedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	// The mapping stays valid after the file descriptor is closed
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("error reading file size: %w", err)
+	}
+	size := info.Size()
+	if size == 0 {
+		// mmap(2) rejects empty mappings
+		return &MappedFile{data: []byte{}}, nil
+	}
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("file too large to map: %d bytes", size)
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("error mapping file: %w", err)
+	}
+	return &MappedFile{data: data, mapped: true}, nil
+}
+
+// unmap releases a mapping created by mapFile
+func unmap(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package parser
+
+import (
+	"fmt"
+	"os"
+)
+
+// mapFile reads the whole file into memory where mmap is not used
+func mapFile(path string) (*MappedFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	return &MappedFile{data: data}, nil
+}
+
+// unmap is never called since the files are not mapped
+func unmap(data []byte) error {
+	return nil
+}
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMapFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "map JSON file", content: `{"data":[{"id":1},{"id":2}]}`},
+		{name: "map empty file", content: ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			file, err := MapFile(path)
+			if err != nil {
+				t.Fatalf("MapFile() error = %v", err)
+			}
+			if string(file.Bytes()) != tt.content {
+				t.Errorf("MapFile() bytes = %q, want %q", file.Bytes(), tt.content)
+			}
+			if err := file.Close(); err != nil {
+				t.Errorf("Close() error = %v", err)
+			}
+			if file.Bytes() != nil {
+				t.Error("Close() should release the bytes")
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := MapFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+			t.Error("MapFile() expected an error for a missing file")
+		}
+	})
+}
+
+func TestParseFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	if err := os.WriteFile(path, []byte(`{"data":[{"id":1},{"id":2}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var result []any
+	err := ParseFile(path, Options{}, func(v any) error {
+		result = append(result, v)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ParseFile() error = %v", err)
+	}
+	if expected := []any{1, nil, 2, nil, nil, nil}; !CompareArray(result, expected) {
+		t.Errorf("ParseFile() = %v, want %v", result, expected)
+	}
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"io"
+)
+
+// NumberMode defines how JSON numbers are passed to the parse handler
+type NumberMode int
+
+const (
+	// NumberFloat64 passes every number as a float64, the default behavior
+	NumberFloat64 NumberMode = iota
+	// NumberText passes every number as a json.Number holding the original text
+	NumberText
+	// NumberInt64 passes integers that fit in 64 bits as int64 and the other numbers as float64
+	NumberInt64
+)
+
+// Options configures a single JSONParser instance
+// The zero value behaves like NewJSONParser: loose validation, raw strings and float64 numbers
+type Options struct {
+	// ChunkSize is the size of data chunks to read, the package-level ChunkSize when zero
+	ChunkSize int
+	// NumberMode defines the Go type of the numbers passed to the parse handler
+	NumberMode NumberMode
+	// DecodeStrings decodes the escape sequences of strings and keys (e.g. \n, \u00e9)
+	// By default they are passed as they are written between the quotes
+	DecodeStrings bool
+	// MaxDepth limits the nesting of objects and arrays, unlimited when zero
+	MaxDepth int
+	// MaxStringLength limits the length in bytes of a string or key as written in the input, unlimited when zero
+	MaxStringLength int
+	// Strict validates the input against the JSON grammar (RFC 8259):
+	// number syntax, escape sequences, control characters in strings and trailing data after the document
+	// Without it the parser only checks what it needs to find the values
+	Strict bool
+	// ZeroCopy lets the parsers of NewBytesParser pass strings that reference the input bytes
+	// instead of copies, the values are then only valid as long as the input is unchanged
+	// It has no effect on parsers reading from an io.Reader
+	ZeroCopy bool
+}
+
+// NewParser creates a new JSON parser instance reading from any io.Reader with its own options
+// The reader is read in chunks of Options.ChunkSize, so it does not need to be buffered
+func NewParser(reader io.Reader, opts Options, parseHandler func(any) error) (*JSONParser, error) {
+	parser := &JSONParser{
+		reader:       reader,
+		options:      opts,
+		buffer:       "", // initially empty string
+		pos:          0,  // the position of the pointer is 0
+		NowField:     "", // no field is detected in the beginning
+		parseHandler: parseHandler,
+	}
+
+	if err := parser.streamData(); err != nil {
+		return nil, err
+	}
+	if err := parser.consume(); err != nil {
+		return nil, err
+	}
+
+	return parser, nil
+}
+
+// chunkSize returns the size of the next chunk to read
+// Parsers created by NewJSONParser keep following the package-level ChunkSize
+func (p *JSONParser) chunkSize() int {
+	if p.options.ChunkSize > 0 {
+		return p.options.ChunkSize
+	}
+	return ChunkSize
+}
+
+// Options returns the configuration of the parser, the zero Options for NewJSONParser
+func (p *JSONParser) Options() Options {
+	return p.options
+}
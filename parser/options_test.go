@@ -0,0 +1,173 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// parseAll parses the input with the options and collects the values passed to the parse handler
+func parseAll(input string, opts Options) ([]any, error) {
+	var result []any
+	parser, err := NewParser(strings.NewReader(input), opts, func(v any) error {
+		result = append(result, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = parser.Parse()
+	return result, err
+}
+
+func TestNewParserChunkSize(t *testing.T) {
+	input := `{"data":[{"id":1,"name":"John"},{"id":2,"name":"Jane"}]}`
+	expected := []any{1, "John", nil, 2, "Jane", nil, nil, nil}
+
+	// Parsers with different chunk sizes can run at the same time without sharing state
+	for _, size := range []int{1, 3, 16, 4096} {
+		size := size
+		t.Run(fmt.Sprintf("chunk size %d", size), func(t *testing.T) {
+			t.Parallel()
+			result, err := parseAll(input, Options{ChunkSize: size})
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !CompareArray(result, expected) {
+				t.Errorf("Parse() with chunk size %d = %v, want %v", size, result, expected)
+			}
+		})
+	}
+}
+
+func TestNewParserNumberMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     NumberMode
+		input    string
+		expected any
+	}{
+		{name: "float64 by default", mode: NumberFloat64, input: "42", expected: float64(42)},
+		{name: "text keeps the digits", mode: NumberText, input: "12345678901234567890", expected: json.Number("12345678901234567890")},
+		{name: "int64 for integers", mode: NumberInt64, input: "-42", expected: int64(-42)},
+		{name: "int64 falls back to float64", mode: NumberInt64, input: "4.5e1", expected: float64(45)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseAll(tt.input, Options{NumberMode: tt.mode})
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(result) != 1 || result[0] != tt.expected {
+				t.Errorf("Parse() = %#v, want %#v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewParserDecodeStrings(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		decode   bool
+		expected any
+	}{
+		{name: "raw by default", input: `"a\nb"`, decode: false, expected: `a\nb`},
+		{name: "simple escapes", input: `"a\nb\t\"c\"\\\/"`, decode: true, expected: "a\nb\t\"c\"\\/"},
+		{name: "unicode escape", input: `"caf\u00e9"`, decode: true, expected: "caf\u00e9"},
+		{name: "surrogate pair", input: `"\ud83d\ude00"`, decode: true, expected: "\U0001F600"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseAll(tt.input, Options{DecodeStrings: tt.decode})
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if len(result) != 1 || result[0] != tt.expected {
+				t.Errorf("Parse() = %q, want %q", result, tt.expected)
+			}
+		})
+	}
+
+	t.Run("decoded keys", func(t *testing.T) {
+		var fields []string
+		parser, _ := NewParser(strings.NewReader(`{"a.b":1}`), Options{DecodeStrings: true}, nil)
+		parser.SetParseHandler(func(v any) error {
+			fields = append(fields, parser.NowField)
+			return nil
+		})
+		if err := parser.Parse(); err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if fields[0] != ".a.b" {
+			t.Errorf("NowField = %q, want %q", fields[0], ".a.b")
+		}
+	})
+}
+
+func TestNewParserErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  Options
+	}{
+		{name: "depth limit", input: `[[[1]]]`, opts: Options{MaxDepth: 2}},
+		{name: "string length limit", input: `{"key":"0123456789"}`, opts: Options{MaxStringLength: 5}},
+		{name: "key length limit", input: `{"0123456789":1}`, opts: Options{MaxStringLength: 5}},
+		{name: "unclosed string", input: `["abc`},
+		{name: "unclosed object", input: `{"a":1`},
+		{name: "unclosed array", input: `[1,2`},
+		{name: "invalid literal", input: `[tru]`},
+		{name: "empty input", input: ``},
+		{name: "strict leading zero", input: `[01]`, opts: Options{Strict: true}},
+		{name: "strict missing fraction", input: `[1.]`, opts: Options{Strict: true}},
+		{name: "strict control character", input: "[\"a\tb\"]", opts: Options{Strict: true}},
+		{name: "strict invalid escape", input: `["\q"]`, opts: Options{Strict: true}},
+		{name: "strict trailing comma", input: `[1,]`, opts: Options{Strict: true}},
+		{name: "strict trailing data", input: `{} {}`, opts: Options{Strict: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseAll(tt.input, tt.opts); err == nil {
+				t.Errorf("Parse(%q) expected an error", tt.input)
+			}
+		})
+	}
+}
+
+func TestNewParserLenient(t *testing.T) {
+	// The checks of strict mode are skipped by default
+	inputs := []string{`[01]`, `["\q"]`, `[1,]`, `{} {}`}
+	for _, input := range inputs {
+		if _, err := parseAll(input, Options{}); err != nil {
+			t.Errorf("Parse(%q) error = %v, want nil", input, err)
+		}
+	}
+}
+
+func TestIsValidNumber(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected bool
+	}{
+		{"0", true},
+		{"-0.5", true},
+		{"12e+3", true},
+		{"1E-2", true},
+		{"-", false},
+		{"01", false},
+		{".5", false},
+		{"1e", false},
+		{"1-2", false},
+	}
+
+	for _, tt := range tests {
+		if result := IsValidNumber(tt.text); result != tt.expected {
+			t.Errorf("IsValidNumber(%q) = %v, want %v", tt.text, result, tt.expected)
+		}
+	}
+}
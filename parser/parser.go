@@ -3,19 +3,35 @@ package parser
 import (
 	"bufio"
 	"errors"
+	"fmt"
+	"io"
 	"unicode"
 )
 
 // ChunkSize defines the size of data chunks to read: 1kb by default
+// It is shared by all the parsers created by NewJSONParser, use NewParser with Options.ChunkSize
+// when parsers in the same process need different sizes
 var ChunkSize = 1024
 
+// errUnexpectedEnd is returned when the input ends in the middle of a value
+var errUnexpectedEnd = errors.New("unexpected end of input")
+
 // JSONParser represents a JSON parser with buffered reading capabilities
 type JSONParser struct {
-	reader       *bufio.Reader
+	reader       io.Reader
+	options      Options // the per-instance configuration
+	chunk        []byte  // the read buffer reused by streamData
 	buffer       string
-	pos          int             // the position of the parser pointer
-	NowField     string          // the current field parser is checking
-	parseHandler func(any) error // the logic the parser handles after parsing
+	aliased      bool              // whether buffer borrows the caller's memory, see NewBytesParser
+	pos          int               // the position of the parser pointer
+	consumed     int64             // the number of bytes of the input removed from the buffer
+	start        int64             // the byte offset of the current value in the input, see Offset
+	depth        int               // the nesting level of objects and arrays at the pointer
+	NowField     string            // the current field parser is checking
+	segments     []PathSegment     // the structured path to the current value, see Path
+	ending       EventKind         // what the parse handler is called for, see Ending
+	parseHandler func(any) error   // the logic the parser handles after parsing
+	eventHandler func(Event) error // the optional logic handling the structural events
 }
 
 // JSONValueType defines a type to check in JSON format
@@ -27,24 +43,10 @@ type JSONValueType struct {
 	ParseValue func(p *JSONParser) error
 }
 
-// NewJSONParser creates a new JSON parser instance
+// NewJSONParser creates a new JSON parser instance with the default options
+// It is kept for compatibility: the parser follows the package-level ChunkSize
 func NewJSONParser(reader *bufio.Reader, parseHandler func(any) error) (*JSONParser, error) {
-	parser := &JSONParser{
-		reader:       reader,
-		buffer:       "", // initially empty string
-		pos:          0,  // the position of the pointer is 0
-		NowField:     "", // no field is detected in the beginning
-		parseHandler: parseHandler,
-	}
-
-	if err := parser.streamData(); err != nil {
-		return nil, err
-	}
-	if err := parser.consume(); err != nil {
-		return nil, err
-	}
-
-	return parser, nil
+	return NewParser(reader, Options{}, parseHandler)
 }
 
 // SetParseHandler sets the parse handler function, made to set this private parseHandler
@@ -52,20 +54,31 @@ func (p *JSONParser) SetParseHandler(parseHandler func(any) error) {
 	p.parseHandler = parseHandler
 }
 
+// Offset returns the byte offset in the input of the first character of the current value
+// It is valid in the ObjectStart, ArrayStart and Value events and when the parse handler gets a primitive value
+func (p *JSONParser) Offset() int64 {
+	return p.start
+}
+
 // streamData reads data chunks from the reader into the buffer
 func (p *JSONParser) streamData() error {
-	chunk := make([]byte, ChunkSize) // stream data by chunk size
+	if p.reader == nil {
+		return nil // Nothing more to read
+	}
+	if size := p.chunkSize(); len(p.chunk) != size {
+		p.chunk = make([]byte, size) // stream data by chunk size
+	}
 
-	n, err := p.reader.Read(chunk)
+	n, err := p.reader.Read(p.chunk)
 	if n > 0 {
-		p.buffer += string(chunk[:n])
+		p.buffer += string(p.chunk[:n])
 	}
 
 	if err != nil {
-		if err.Error() == "EOF" {
+		if errors.Is(err, io.EOF) {
 			return nil // End of file reached
 		}
-		return errors.New("error loading more data")
+		return fmt.Errorf("error loading more data: %w", err)
 	}
 	return nil
 }
@@ -83,23 +96,61 @@ func (p *JSONParser) goBackward(field string) {
 
 // Parse is the main function to parse the JSON data
 func (p *JSONParser) Parse() error {
-	p.consume() // Skip whitespace for the first time..
+	if err := p.parseValue(); err != nil {
+		return err
+	}
+	// In strict mode the document must be the only thing in the input
+	if p.options.Strict && p.depth == 0 {
+		return p.expectEnd()
+	}
+	return nil
+}
+
+// parseValue parses the value at the pointer, composite types call it for each of their values
+func (p *JSONParser) parseValue() error {
+	if err := p.consume(); err != nil { // Skip whitespace for the first time..
+		return err
+	}
+	if p.pos >= len(p.buffer) {
+		return errUnexpectedEnd
+	}
+	p.start = p.consumed + int64(p.pos)
 
 	// Determine the JSONValue type by comparing the initializer with the current buffer
 	switch p.buffer[p.pos] {
 	// The JSONObject and JSONArray are composite types
 	// ParseValue function has no result return but calls the main Parse function inside
 	case '{':
+		if err := p.enter(); err != nil {
+			return err
+		}
+		if err := p.emit(Event{Kind: ObjectStart}); err != nil {
+			return err
+		}
 		// Parsing object: append "." and remove it before and after parsing
 		p.goForward(".")
 		if err := JSONObject.ParseValue(p); err != nil {
 			return err
 		}
 		p.goBackward(".")
+		p.depth--
+		if err := p.emit(Event{Kind: ObjectEnd}); err != nil {
+			return err
+		}
 	case '[':
+		if err := p.enter(); err != nil {
+			return err
+		}
+		if err := p.emit(Event{Kind: ArrayStart}); err != nil {
+			return err
+		}
 		if err := JSONArray.ParseValue(p); err != nil {
 			return err
 		}
+		p.depth--
+		if err := p.emit(Event{Kind: ArrayEnd}); err != nil {
+			return err
+		}
 	// The other types are primitive types
 	// These ParseValue functions only move the pointer and call parseHandler with the result taken
 	case '"':
@@ -133,6 +184,26 @@ func (p *JSONParser) Parse() error {
 // or JSONArray in the beginning (e.g. Just a simple '[]' in the beginning will finish the process)
 // and should initialize the JSONParser with NewJSONParser function.
 
+// enter goes one level deeper into an object or array, checking the depth limit
+func (p *JSONParser) enter() error {
+	p.depth++
+	if p.options.MaxDepth > 0 && p.depth > p.options.MaxDepth {
+		return fmt.Errorf("maximum depth of %d exceeded at %s", p.options.MaxDepth, p.NowField)
+	}
+	return nil
+}
+
+// expectEnd verifies that only whitespace is left after the document
+func (p *JSONParser) expectEnd() error {
+	if err := p.consume(); err != nil {
+		return err
+	}
+	if p.pos < len(p.buffer) {
+		return fmt.Errorf("unexpected character '%c' after the end of the document", p.buffer[p.pos])
+	}
+	return nil
+}
+
 // incrementPos increments the buffer position and loads more data if needed
 func (p *JSONParser) incrementPos() error {
 	p.pos++
@@ -158,6 +229,7 @@ func (p *JSONParser) skipWhitespace() error {
 
 // subtractBuffer removes processed data from the buffer
 func (p *JSONParser) subtractBuffer() {
+	p.consumed += int64(p.pos)
 	p.buffer = p.buffer[p.pos:]
 	p.pos = 0
 }
@@ -3,6 +3,7 @@ package parser
 import (
 	"bufio"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -604,6 +605,32 @@ func TestJSONParserParse(t *testing.T) {
 	}
 }
 
+func TestJSONParserOffset(t *testing.T) {
+	input := ` {"a": [1, "two"],  "b": {"c": null}, "d": []}`
+	expected := []string{"ObjectStart 1", "ArrayStart 7", "Value 8", "Value 11", "ObjectStart 25", "Value 31", "ArrayStart 43"}
+
+	for _, chunkSize := range []int{1, 3, 1024} {
+		var results []string
+		p, err := NewParser(strings.NewReader(input), Options{ChunkSize: chunkSize}, nil)
+		if err != nil {
+			t.Fatalf("NewParser() error = %v", err)
+		}
+		p.SetEventHandler(func(event Event) error {
+			switch event.Kind {
+			case ObjectStart, ArrayStart, Value:
+				results = append(results, fmt.Sprintf("%v %d", event.Kind, p.Offset()))
+			}
+			return nil
+		})
+		if err := p.Parse(); err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Errorf("chunk size %d: Offset() = %q, want %q", chunkSize, results, expected)
+		}
+	}
+}
+
 func CompareArray(result []any, expected []any) bool {
 	// Check if lengths are equal
 	if len(result) != len(expected) {
@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PathSegment is one step of the path from the root to a value: an object key or an array position
+type PathSegment struct {
+	Key   string // the key in the object, for object members
+	Index int    // the position in the array, -1 for object members
+}
+
+// Pointer is a JSON Pointer (RFC 6901) split into its reference tokens, e.g. "/a~1b/0" is {"a/b", "0"}
+// The empty pointer refers to the whole document
+type Pointer []string
+
+// IsPointer reports whether a path is written as a JSON Pointer rather than in the dotted syntax
+func IsPointer(path string) bool {
+	return strings.HasPrefix(path, "/")
+}
+
+// ParsePointer parses a JSON Pointer, decoding the "~0" and "~1" escapes of its tokens
+func ParsePointer(pointer string) (Pointer, error) {
+	if pointer == "" {
+		return Pointer{}, nil
+	}
+	if !IsPointer(pointer) {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must be empty or start with '/'", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		if !strings.Contains(token, "~") {
+			continue
+		}
+		// "~1" is decoded before "~0", so "~01" is "~1" and not "/"
+		var decoded strings.Builder
+		for j := 0; j < len(token); j++ {
+			if token[j] != '~' {
+				decoded.WriteByte(token[j])
+				continue
+			}
+			if j+1 < len(token) && token[j+1] == '0' {
+				decoded.WriteByte('~')
+			} else if j+1 < len(token) && token[j+1] == '1' {
+				decoded.WriteByte('/')
+			} else {
+				return nil, fmt.Errorf("invalid JSON pointer %q: '~' must be followed by '0' or '1'", pointer)
+			}
+			j++
+		}
+		tokens[i] = decoded.String()
+	}
+	return Pointer(tokens), nil
+}
+
+// String returns the pointer in its escaped form
+func (ptr Pointer) String() string {
+	var builder strings.Builder
+	for _, token := range ptr {
+		builder.WriteByte('/')
+		builder.WriteString(escapeToken(token))
+	}
+	return builder.String()
+}
+
+// escapeToken escapes '~' and '/' in a reference token
+func escapeToken(token string) string {
+	if !strings.ContainsAny(token, "~/") {
+		return token
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// Match reports whether the pointer refers to the value at the given path
+// Like the dotted fields, arrays are transparent: an array position that the pointer leaves out
+// matches every element, so "/dataset/keyword" matches all the keywords of all the datasets,
+// while "/dataset/0/keyword" only matches those of the first one
+func (ptr Pointer) Match(path []PathSegment) bool {
+	if len(path) == 0 {
+		return len(ptr) == 0
+	}
+	segment := path[0]
+	if segment.Index < 0 {
+		return len(ptr) > 0 && ptr[0] == segment.Key && ptr[1:].Match(path[1:])
+	}
+	// An array position matches its index token, or is skipped when the pointer leaves it out
+	if len(ptr) > 0 && ptr[0] == strconv.Itoa(segment.Index) && ptr[1:].Match(path[1:]) {
+		return true
+	}
+	return ptr.Match(path[1:])
+}
+
+// Path returns the path to the current value, or to the object or array being closed
+// when the parse handler is called with nil at its end
+// The slice is only valid until the parser moves on, copy it to keep it
+func (p *JSONParser) Path() []PathSegment {
+	return p.segments
+}
+
+// Pointer returns the JSON Pointer to the current value, e.g. "/dataset/3/title"
+func (p *JSONParser) Pointer() string {
+	var builder strings.Builder
+	for _, segment := range p.segments {
+		builder.WriteByte('/')
+		if segment.Index < 0 {
+			builder.WriteString(escapeToken(segment.Key))
+		} else {
+			builder.WriteString(strconv.Itoa(segment.Index))
+		}
+	}
+	return builder.String()
+}
+
+// Ending tells what the parse handler is called for: Value for a primitive value,
+// ObjectEnd or ArrayEnd for the nil reported at the end of an object or array
+func (p *JSONParser) Ending() EventKind {
+	return p.ending
+}
+
+// pushKey enters the member of an object with the given key
+func (p *JSONParser) pushKey(key string) {
+	p.segments = append(p.segments, PathSegment{Key: key, Index: -1})
+}
+
+// pushIndex enters the element of an array at the given position
+func (p *JSONParser) pushIndex(index int) {
+	p.segments = append(p.segments, PathSegment{Index: index})
+}
+
+// popSegment leaves the current member or element
+func (p *JSONParser) popSegment() {
+	p.segments = p.segments[:len(p.segments)-1]
+}
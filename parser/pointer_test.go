@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePointer(t *testing.T) {
+	tests := []struct {
+		pointer  string
+		expected Pointer
+	}{
+		{"", Pointer{}},
+		{"/", Pointer{""}},
+		{"/dataset", Pointer{"dataset"}},
+		{"/publisher/name", Pointer{"publisher", "name"}},
+		{"/a~1b/m~0n", Pointer{"a/b", "m~n"}},
+		{"/~01", Pointer{"~1"}},
+		{"/a.b//0", Pointer{"a.b", "", "0"}},
+	}
+
+	for _, tt := range tests {
+		result, err := ParsePointer(tt.pointer)
+		if err != nil {
+			t.Errorf("ParsePointer(%q) error = %v", tt.pointer, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("ParsePointer(%q) = %q, want %q", tt.pointer, result, tt.expected)
+		}
+		if result.String() != tt.pointer {
+			t.Errorf("ParsePointer(%q).String() = %q", tt.pointer, result.String())
+		}
+	}
+
+	for _, invalid := range []string{"dataset", ".dataset", "/a~", "/a~2"} {
+		if _, err := ParsePointer(invalid); err == nil {
+			t.Errorf("ParsePointer(%q) expected an error", invalid)
+		}
+	}
+}
+
+func TestPointerMatch(t *testing.T) {
+	key := func(k string) PathSegment { return PathSegment{Key: k, Index: -1} }
+	index := func(i int) PathSegment { return PathSegment{Index: i} }
+
+	tests := []struct {
+		pointer  string
+		path     []PathSegment
+		expected bool
+	}{
+		{"", nil, true},
+		{"", []PathSegment{index(2)}, true},
+		{"/dataset", []PathSegment{key("dataset")}, true},
+		{"/dataset", []PathSegment{key("dataset"), index(1)}, true},
+		{"/dataset/1", []PathSegment{key("dataset"), index(1)}, true},
+		{"/dataset/0", []PathSegment{key("dataset"), index(1)}, false},
+		{"/dataset/title", []PathSegment{key("dataset"), index(1), key("title")}, true},
+		{"/dataset/1/title", []PathSegment{key("dataset"), index(1), key("title")}, true},
+		{"/dataset/0", []PathSegment{key("dataset"), index(1), key("0")}, true},
+		{"/dataset", []PathSegment{key("dataset"), index(1), key("title")}, false},
+		{"/a/b", []PathSegment{key("a")}, false},
+	}
+
+	for _, tt := range tests {
+		pointer, err := ParsePointer(tt.pointer)
+		if err != nil {
+			t.Fatalf("ParsePointer(%q) error = %v", tt.pointer, err)
+		}
+		if result := pointer.Match(tt.path); result != tt.expected {
+			t.Errorf("Pointer(%q).Match(%v) = %v, want %v", tt.pointer, tt.path, result, tt.expected)
+		}
+	}
+}
+
+func TestParserPointer(t *testing.T) {
+	input := `{"a/b": [1, {"m~n": true}], "": null}`
+
+	var p *JSONParser
+	var results []string
+	p, err := NewParser(strings.NewReader(input), Options{}, func(value any) error {
+		results = append(results, p.Ending().String()+" "+p.Pointer())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewParser() error = %v", err)
+	}
+	if err := p.Parse(); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	expected := []string{
+		"Value /a~1b/0",
+		"Value /a~1b/1/m~0n",
+		"ObjectEnd /a~1b/1",
+		"ArrayEnd /a~1b",
+		"Value /",
+		"ObjectEnd ",
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Pointer() = %q, want %q", results, expected)
+	}
+}
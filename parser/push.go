@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"errors"
+	"io"
+)
+
+// PushParser is a JSON parser fed through Write calls instead of reading from an io.Reader,
+// for data arriving in pieces from callbacks (message frames, chunked uploads...)
+//
+// It runs the same grammar as JSONParser on its own goroutine, which waits for the next Write
+// whenever a token is cut between two writes, so the parse handler receives exactly the same values
+// Each Write returns once the parser has handled every complete token of the written bytes
+// Close must be called at the end of the input, it reports whether a whole document was written;
+// without it the goroutine keeps waiting for data and is never released
+// A PushParser is not safe for concurrent use
+type PushParser struct {
+	parser *JSONParser
+	input  *pushReader
+	done   chan struct{} // closed when the parser goroutine is finished
+	err    error         // the result of the parser goroutine, valid once done is closed
+	closed bool
+}
+
+// errWriteAfterClose is returned by Write after Close
+var errWriteAfterClose = errors.New("write to closed push parser")
+
+// NewPushParser creates a push parser with the given options and starts waiting for data
+func NewPushParser(opts Options, parseHandler func(any) error) *PushParser {
+	input := &pushReader{
+		input:    make(chan []byte),
+		consumed: make(chan struct{}),
+	}
+	pp := &PushParser{
+		parser: &JSONParser{
+			reader:       input,
+			options:      opts,
+			parseHandler: parseHandler,
+		},
+		input: input,
+		done:  make(chan struct{}),
+	}
+
+	go pp.run()
+	return pp
+}
+
+// run parses the document and then, in strict mode, makes sure that only whitespace follows it
+func (pp *PushParser) run() {
+	defer close(pp.done)
+
+	p := pp.parser
+	if pp.err = p.streamData(); pp.err != nil {
+		return
+	}
+	if pp.err = p.parseValue(); pp.err != nil {
+		return
+	}
+	// The goroutine keeps reading until Close so that writes after the document are checked too,
+	// or ignored outside of the strict mode as JSONParser.Parse does
+	if p.options.Strict {
+		pp.err = p.expectEnd()
+		return
+	}
+	_, pp.err = io.Copy(io.Discard, p.reader)
+}
+
+// Parser returns the underlying parser, e.g. to read NowField from the parse handler
+func (pp *PushParser) Parser() *JSONParser {
+	return pp.parser
+}
+
+// Write hands data to the parser and waits until every complete token in it is handled
+// It returns the parsing error, if any, instead of accepting more data
+func (pp *PushParser) Write(data []byte) (int, error) {
+	if pp.closed {
+		return 0, errWriteAfterClose
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	select {
+	case pp.input.input <- data:
+	case <-pp.done:
+		// Before Close the goroutine only stops on an error
+		return 0, pp.err
+	}
+
+	// The parser signals when it needs more data, meaning that data has been fully read
+	select {
+	case <-pp.input.consumed:
+		return len(data), nil
+	case <-pp.done:
+		return 0, pp.err
+	}
+}
+
+// Close tells the parser that the input is complete and waits for it to finish
+// It returns an error if the document is incomplete or invalid
+func (pp *PushParser) Close() error {
+	if !pp.closed {
+		pp.closed = true
+		close(pp.input.input)
+	}
+	<-pp.done
+	return pp.err
+}
+
+// pushReader is the io.Reader the parser goroutine reads the written slices from
+type pushReader struct {
+	input    chan []byte   // slices handed over by Write, closed by Close
+	consumed chan struct{} // signalled when the slice of the pending Write is fully read
+	current  []byte        // the rest of the slice being read
+	pending  bool          // whether a Write is waiting for current to be consumed
+}
+
+// Read copies the written data into b, waiting for the next Write when it is all read
+func (r *pushReader) Read(b []byte) (int, error) {
+	if len(r.current) == 0 {
+		if r.pending {
+			// The written slice is fully copied into the parser's buffer: release the Write
+			r.pending = false
+			r.consumed <- struct{}{}
+		}
+		data, ok := <-r.input
+		if !ok {
+			return 0, io.EOF
+		}
+		r.current, r.pending = data, true
+	}
+	n := copy(b, r.current)
+	r.current = r.current[n:]
+	return n, nil
+}
@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// event is a value passed to the parse handler with the field it was found at
+type event struct {
+	field string
+	value any
+}
+
+// pullEvents parses the input with JSONParser to get the events the push parser must reproduce
+func pullEvents(t *testing.T, input string) []event {
+	t.Helper()
+	var events []event
+	parser, err := NewParser(strings.NewReader(input), Options{}, nil)
+	if err != nil {
+		t.Fatalf("NewParser() error = %v", err)
+	}
+	parser.SetParseHandler(func(v any) error {
+		events = append(events, event{parser.NowField, v})
+		return nil
+	})
+	if err := parser.Parse(); err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return events
+}
+
+// pushEvents writes the pieces to a push parser and collects its events
+func pushEvents(pieces []string) ([]event, error) {
+	var events []event
+	var pp *PushParser
+	pp = NewPushParser(Options{}, func(v any) error {
+		events = append(events, event{pp.Parser().NowField, v})
+		return nil
+	})
+	for _, piece := range pieces {
+		if _, err := pp.Write([]byte(piece)); err != nil {
+			pp.Close()
+			return events, err
+		}
+	}
+	return events, pp.Close()
+}
+
+func compareEvents(result []event, expected []event) bool {
+	if len(result) != len(expected) {
+		return false
+	}
+	for i := range result {
+		if result[i].field != expected[i].field || result[i].value != expected[i].value {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPushParserSplits(t *testing.T) {
+	inputs := []string{
+		`{"data":[{"id":1,"name":"John"},{"id":2.5e3,"name":"J\"ane"}]}`,
+		`[true, false, null, -12, "text", {"nested": {"deep": []}}]`,
+		`  "lone string"  `,
+		`12345`,
+	}
+
+	for _, input := range inputs {
+		expected := pullEvents(t, input)
+
+		// Cut the input in two at every position
+		for i := 0; i <= len(input); i++ {
+			result, err := pushEvents([]string{input[:i], input[i:]})
+			if err != nil {
+				t.Fatalf("split %q|%q error = %v", input[:i], input[i:], err)
+			}
+			if !compareEvents(result, expected) {
+				t.Errorf("split %q|%q events = %v, want %v", input[:i], input[i:], result, expected)
+			}
+		}
+
+		// Feed the input byte by byte
+		result, err := pushEvents(strings.Split(input, ""))
+		if err != nil {
+			t.Fatalf("byte by byte %q error = %v", input, err)
+		}
+		if !compareEvents(result, expected) {
+			t.Errorf("byte by byte %q events = %v, want %v", input, result, expected)
+		}
+	}
+}
+
+func TestPushParserEventsBeforeClose(t *testing.T) {
+	var events []any
+	pp := NewPushParser(Options{}, func(v any) error {
+		events = append(events, v)
+		return nil
+	})
+	defer pp.Close()
+
+	// Complete tokens are handled as soon as Write returns, the cut one is not
+	if _, err := pp.Write([]byte(`["first", "sec`)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if !CompareArray(events, []any{"first"}) {
+		t.Errorf("events after first write = %v, want [first]", events)
+	}
+	if _, err := pp.Write([]byte(`ond", `)); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if !CompareArray(events, []any{"first", "second"}) {
+		t.Errorf("events after second write = %v, want [first second]", events)
+	}
+}
+
+func TestPushParserErrors(t *testing.T) {
+	handlerErr := errors.New("stop")
+
+	tests := []struct {
+		name    string
+		pieces  []string
+		opts    Options
+		handler func(any) error
+	}{
+		{name: "nothing written", pieces: nil},
+		{name: "unfinished document", pieces: []string{`{"a": [1, 2`}},
+		{name: "cut literal", pieces: []string{`[tr`}},
+		{name: "data after the document", pieces: []string{`{}`, ` {}`}, opts: Options{Strict: true}},
+		{name: "invalid token", pieces: []string{`[1, `, `x]`}},
+		{name: "handler error", pieces: []string{`[1]`}, handler: func(any) error { return handlerErr }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.handler
+			if handler == nil {
+				handler = func(any) error { return nil }
+			}
+			pp := NewPushParser(tt.opts, handler)
+			var err error
+			for _, piece := range tt.pieces {
+				if _, err = pp.Write([]byte(piece)); err != nil {
+					break
+				}
+			}
+			closeErr := pp.Close()
+			if err == nil && closeErr == nil {
+				t.Error("expected an error from Write or Close")
+			}
+			if tt.handler != nil && !errors.Is(closeErr, handlerErr) {
+				t.Errorf("Close() error = %v, want %v", closeErr, handlerErr)
+			}
+		})
+	}
+
+	t.Run("data after the document outside of the strict mode", func(t *testing.T) {
+		pp := NewPushParser(Options{}, func(any) error { return nil })
+		for _, piece := range []string{`{}`, ` {}`, ` x`} {
+			if _, err := pp.Write([]byte(piece)); err != nil {
+				t.Fatalf("Write(%q) error = %v", piece, err)
+			}
+		}
+		if err := pp.Close(); err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	})
+
+	t.Run("write after close", func(t *testing.T) {
+		pp := NewPushParser(Options{}, func(any) error { return nil })
+		pp.Write([]byte(`[]`))
+		if err := pp.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		if _, err := pp.Write([]byte(` `)); err == nil {
+			t.Error("Write() after Close expected an error")
+		}
+	})
+}
@@ -0,0 +1,366 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// Schema is a compiled JSON Schema, ready to validate documents while they are streamed
+// The supported keywords are the core of draft 2020-12:
+//
+//	type, enum, const
+//	properties, required, additionalProperties, minProperties, maxProperties
+//	items, prefixItems, minItems, maxItems
+//	minLength, maxLength, pattern
+//	minimum, maximum, exclusiveMinimum, exclusiveMaximum
+//	allOf, anyOf, oneOf, not
+//	$ref to a location inside the same schema document, e.g. "#/$defs/dataset"
+//
+// together with the draft-04 forms of items (an array) and exclusiveMinimum/exclusiveMaximum (booleans)
+// Other keywords, like format or $comment, are ignored as annotations
+type Schema struct {
+	root *node
+	doc  any              // the parsed schema document, where references are resolved
+	refs map[string]*node // the compiled nodes by location in the document
+}
+
+// node is a compiled schema object or boolean
+type node struct {
+	location string // the JSON Pointer of the node in the schema document
+
+	reject bool // the false schema, or a schema that accepts nothing
+	ref    *node
+
+	types    []string
+	enum     []any
+	hasEnum  bool
+	constant any
+	hasConst bool
+
+	properties    map[string]*node
+	additional    *node // additionalProperties, nil when absent
+	required      []string
+	minProperties int
+	maxProperties int
+
+	prefixItems []*node
+	items       *node // the schema of the items after prefixItems, nil when absent
+	minItems    int
+	maxItems    int
+
+	minLength int
+	maxLength int
+	pattern   *regexp.Regexp
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+
+	allOf []*node
+	anyOf []*node
+	oneOf []*node
+	not   *node
+}
+
+// CompileSchema compiles a JSON Schema document
+func CompileSchema(data []byte) (*Schema, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid schema: unexpected data after the document")
+	}
+
+	s := &Schema{doc: doc, refs: make(map[string]*node)}
+	root, err := s.compile(doc, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	s.root = root
+	return s, nil
+}
+
+// LoadSchema reads and compiles a JSON Schema file
+func LoadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema: %w", err)
+	}
+	return CompileSchema(data)
+}
+
+// Ref returns the schema at a reference inside the document, e.g. "#/$defs/dataset",
+// to validate the elements of a document described by the whole schema
+func (s *Schema) Ref(ref string) (*Schema, error) {
+	root, err := s.resolve(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return &Schema{root: root, doc: s.doc, refs: s.refs}, nil
+}
+
+// resolve compiles the value a reference points to
+func (s *Schema) resolve(ref string) (*node, error) {
+	fragment, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q: only references inside the schema are supported", ref)
+	}
+	fragment, err := url.PathUnescape(fragment)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $ref %q: %w", ref, err)
+	}
+	if fragment != "" && !parser.IsPointer(fragment) {
+		return nil, fmt.Errorf("unsupported $ref %q: anchors are not supported", ref)
+	}
+	pointer, err := parser.ParsePointer(fragment)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $ref %q: %w", ref, err)
+	}
+	if compiled, ok := s.refs[pointer.String()]; ok {
+		return compiled, nil
+	}
+
+	value := s.doc
+	for _, token := range pointer {
+		switch container := value.(type) {
+		case map[string]any:
+			value, ok = container[token]
+		case []any:
+			index, err := strconv.Atoi(token)
+			ok = err == nil && index >= 0 && index < len(container)
+			if ok {
+				value = container[index]
+			}
+		default:
+			ok = false
+		}
+		if !ok {
+			return nil, fmt.Errorf("$ref %q not found", ref)
+		}
+	}
+	return s.compile(value, pointer.String())
+}
+
+// compile compiles the schema found at location in the document
+// Compiled nodes are kept by location, so that recursive references end up on the same node
+func (s *Schema) compile(value any, location string) (*node, error) {
+	if compiled, ok := s.refs[location]; ok {
+		return compiled, nil
+	}
+	n := &node{location: location, minProperties: -1, maxProperties: -1, minItems: -1, maxItems: -1, minLength: -1, maxLength: -1}
+	s.refs[location] = n
+
+	switch value := value.(type) {
+	case bool:
+		n.reject = !value
+		return n, nil
+	case map[string]any:
+		if err := s.compileKeywords(n, value); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("%s: a schema must be an object or a boolean", describe(location))
+}
+
+// compileKeywords reads the supported keywords of a schema object
+func (s *Schema) compileKeywords(n *node, keywords map[string]any) error {
+	var err error
+	sub := func(keyword string, value any) (*node, error) {
+		return s.compile(value, n.location+"/"+escape(keyword))
+	}
+	subs := func(keyword string, value any) ([]*node, error) {
+		list, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: %s must be an array", describe(n.location), keyword)
+		}
+		nodes := make([]*node, len(list))
+		for i, item := range list {
+			if nodes[i], err = s.compile(item, n.location+"/"+escape(keyword)+"/"+strconv.Itoa(i)); err != nil {
+				return nil, err
+			}
+		}
+		return nodes, nil
+	}
+
+	for keyword, value := range keywords {
+		switch keyword {
+		case "$ref":
+			ref, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%s: $ref must be a string", describe(n.location))
+			}
+			n.ref, err = s.resolve(ref)
+		case "type":
+			if n.types, err = typeList(value); err != nil {
+				err = fmt.Errorf("%s: %w", describe(n.location), err)
+			}
+		case "enum":
+			list, ok := value.([]any)
+			if !ok {
+				return fmt.Errorf("%s: enum must be an array", describe(n.location))
+			}
+			n.enum, n.hasEnum = list, true
+		case "const":
+			n.constant, n.hasConst = value, true
+		case "properties":
+			object, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s: properties must be an object", describe(n.location))
+			}
+			n.properties = make(map[string]*node, len(object))
+			for name, property := range object {
+				if n.properties[name], err = s.compile(property, n.location+"/properties/"+escape(name)); err != nil {
+					return err
+				}
+			}
+		case "additionalProperties":
+			n.additional, err = sub(keyword, value)
+		case "required":
+			list, ok := value.([]any)
+			if !ok {
+				return fmt.Errorf("%s: required must be an array of strings", describe(n.location))
+			}
+			for _, name := range list {
+				text, ok := name.(string)
+				if !ok {
+					return fmt.Errorf("%s: required must be an array of strings", describe(n.location))
+				}
+				n.required = append(n.required, text)
+			}
+		case "prefixItems":
+			n.prefixItems, err = subs(keyword, value)
+		case "items":
+			if _, ok := value.([]any); ok { // draft-04 tuple
+				n.prefixItems, err = subs(keyword, value)
+			} else {
+				n.items, err = sub(keyword, value)
+			}
+		case "pattern":
+			text, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("%s: pattern must be a string", describe(n.location))
+			}
+			if n.pattern, err = regexp.Compile(text); err != nil {
+				err = fmt.Errorf("%s: invalid pattern: %w", describe(n.location), err)
+			}
+		case "minLength", "maxLength", "minItems", "maxItems", "minProperties", "maxProperties":
+			var limit int
+			if limit, err = count(n.location, keyword, value); err != nil {
+				return err
+			}
+			switch keyword {
+			case "minLength":
+				n.minLength = limit
+			case "maxLength":
+				n.maxLength = limit
+			case "minItems":
+				n.minItems = limit
+			case "maxItems":
+				n.maxItems = limit
+			case "minProperties":
+				n.minProperties = limit
+			case "maxProperties":
+				n.maxProperties = limit
+			}
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+			if _, ok := value.(bool); ok {
+				continue // draft-04 flags, applied below once minimum and maximum are known
+			}
+			var limit float64
+			if limit, err = number(n.location, keyword, value); err != nil {
+				return err
+			}
+			switch keyword {
+			case "minimum":
+				n.minimum = &limit
+			case "maximum":
+				n.maximum = &limit
+			case "exclusiveMinimum":
+				n.exclusiveMinimum = &limit
+			case "exclusiveMaximum":
+				n.exclusiveMaximum = &limit
+			}
+		case "allOf":
+			n.allOf, err = subs(keyword, value)
+		case "anyOf":
+			n.anyOf, err = subs(keyword, value)
+		case "oneOf":
+			n.oneOf, err = subs(keyword, value)
+		case "not":
+			n.not, err = sub(keyword, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// In draft-04, exclusiveMinimum and exclusiveMaximum are booleans making minimum and maximum exclusive
+	if exclusive, _ := keywords["exclusiveMinimum"].(bool); exclusive && n.minimum != nil {
+		n.exclusiveMinimum, n.minimum = n.minimum, nil
+	}
+	if exclusive, _ := keywords["exclusiveMaximum"].(bool); exclusive && n.maximum != nil {
+		n.exclusiveMaximum, n.maximum = n.maximum, nil
+	}
+	return nil
+}
+
+// typeList reads the type keyword, a type name or an array of them
+func typeList(value any) ([]string, error) {
+	list, ok := value.([]any)
+	if !ok {
+		list = []any{value}
+	}
+	types := make([]string, len(list))
+	for i, item := range list {
+		name, _ := item.(string)
+		switch name {
+		case "object", "array", "string", "number", "integer", "boolean", "null":
+			types[i] = name
+		default:
+			return nil, fmt.Errorf("unknown type %v", item)
+		}
+	}
+	return types, nil
+}
+
+// count reads a keyword holding a non-negative integer
+func count(location string, keyword string, value any) (int, error) {
+	limit, err := strconv.Atoi(fmt.Sprint(value))
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("%s: %s must be a non-negative integer", describe(location), keyword)
+	}
+	return limit, nil
+}
+
+// number reads a keyword holding a number
+func number(location string, keyword string, value any) (float64, error) {
+	text, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("%s: %s must be a number", describe(location), keyword)
+	}
+	return text.Float64()
+}
+
+// escape escapes a key as a reference token of a JSON Pointer
+func escape(key string) string {
+	return parser.Pointer{key}.String()[1:]
+}
+
+// describe names a location of the schema document in error messages
+func describe(location string) string {
+	return "schema at \"#" + location + "\""
+}
@@ -0,0 +1,69 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompileSchema(t *testing.T) {
+	valid := []string{
+		`true`,
+		`{}`,
+		`{"type": ["string", "null"], "format": "uri", "$comment": "ignored"}`,
+		`{"$defs": {"node": {"type": "object", "properties": {"next": {"$ref": "#/$defs/node"}}}}, "$ref": "#/$defs/node"}`,
+		`{"properties": {"a/b": {"$ref": "#/properties/a~1b"}}}`,
+		`{"items": [{"type": "string"}], "minimum": 1, "exclusiveMinimum": true}`,
+		`{"definitions": {"x": {"type": "string"}}, "anyOf": [{"$ref": "#/definitions/x"}, {"type": "null"}]}`,
+	}
+	for _, tt := range valid {
+		if _, err := CompileSchema([]byte(tt)); err != nil {
+			t.Errorf("CompileSchema(%s) error = %v", tt, err)
+		}
+	}
+
+	invalid := []string{
+		``,
+		`{} {}`,
+		`"string"`,
+		`{"type": "text"}`,
+		`{"$ref": "other.json#/definitions/x"}`,
+		`{"$ref": "#anchor"}`,
+		`{"$ref": "#/$defs/missing"}`,
+		`{"required": "name"}`,
+		`{"pattern": "("}`,
+		`{"minLength": -1}`,
+		`{"maximum": "10"}`,
+		`{"properties": {"a": 1}}`,
+		`{"anyOf": {}}`,
+	}
+	for _, tt := range invalid {
+		if _, err := CompileSchema([]byte(tt)); err == nil {
+			t.Errorf("CompileSchema(%s) error = nil", tt)
+		}
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(`{"$defs": {"dataset": {"required": ["title"]}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := LoadSchema(path)
+	if err != nil {
+		t.Fatalf("LoadSchema() error = %v", err)
+	}
+	dataset, err := s.Ref("#/$defs/dataset")
+	if err != nil {
+		t.Fatalf("Ref() error = %v", err)
+	}
+	if len(dataset.root.required) != 1 {
+		t.Errorf("Ref() = %+v, want the dataset schema", dataset.root)
+	}
+	if _, err := s.Ref("#/$defs/distribution"); err == nil {
+		t.Error("Ref() of a missing location error = nil")
+	}
+	if _, err := LoadSchema(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("LoadSchema() of a missing file error = nil")
+	}
+}
@@ -0,0 +1,240 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// defaultSamples is the default number of distinct sample values kept per path
+const defaultSamples = 3
+
+// DiscoverOptions configures Discover
+type DiscoverOptions struct {
+	// Base is the field of the elements the presence ratios are relative to, dotted like ".dataset"
+	// or a JSON Pointer like "/dataset"; the root document is the only element when empty
+	Base string
+	// Samples is the number of distinct sample values kept per path, 3 when zero, none when negative
+	Samples int
+	// Parser configures the parser, numbers are always kept as text to tell integers apart
+	Parser parser.Options
+}
+
+// PathInfo describes the values found at one path
+type PathInfo struct {
+	Path     string         // the absolute path with array positions collapsed, e.g. ".dataset[].keyword[]"
+	Field    string         // the path relative to the base element, as a field of the extractor, e.g. "keyword"
+	Types    map[string]int // the number of values of each JSON type, e.g. {"string": 10, "null": 2}
+	Count    int            // the number of values at the path
+	Elements int            // the number of base elements holding the path at least once
+	Samples  []any          // distinct sample values, primitive values only
+
+	inElement bool        // whether the path is inside the base elements
+	key       string      // the key of the path in its parent object
+	isItem    bool        // whether the path holds the elements of its parent array
+	children  []*PathInfo // the paths directly inside this one, in order of discovery
+}
+
+// Report is the result of Discover
+type Report struct {
+	Elements int         // the number of base elements
+	Paths    []*PathInfo // every distinct path, in order of discovery
+
+	index map[string]*PathInfo
+}
+
+// Presence returns the ratio of base elements holding the path, -1 for paths outside the elements
+func (r *Report) Presence(info *PathInfo) float64 {
+	if !info.inElement {
+		return -1
+	}
+	if r.Elements == 0 {
+		return 0
+	}
+	return float64(info.Elements) / float64(r.Elements)
+}
+
+// Lookup returns the information of a path, e.g. ".dataset[].title", or nil if it was not found
+func (r *Report) Lookup(path string) *PathInfo {
+	return r.index[path]
+}
+
+// discoverer collects the report from the events of the parser
+type discoverer struct {
+	parser  *parser.JSONParser
+	report  *Report
+	base    parser.Pointer
+	samples int
+
+	stack        []*PathInfo        // the open containers
+	elementDepth int                // the length of the path of the current element, -1 outside of elements
+	elementPath  string             // the collapsed path of the current element
+	seen         map[*PathInfo]bool // the paths found in the current element
+}
+
+// Discover streams the whole document once and reports every distinct path with its types and samples
+func Discover(reader io.Reader, opts DiscoverOptions) (*Report, error) {
+	base, err := basePointer(opts.Base)
+	if err != nil {
+		return nil, err
+	}
+	parserOptions := opts.Parser
+	parserOptions.NumberMode = parser.NumberText
+	jsonParser, err := parser.NewParser(reader, parserOptions, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := opts.Samples
+	if samples == 0 {
+		samples = defaultSamples
+	}
+	d := &discoverer{
+		parser:       jsonParser,
+		report:       &Report{index: make(map[string]*PathInfo)},
+		base:         base,
+		samples:      samples,
+		elementDepth: -1,
+		seen:         make(map[*PathInfo]bool),
+	}
+	jsonParser.SetEventHandler(d.handle)
+
+	if err := jsonParser.Parse(); err != nil {
+		return nil, fmt.Errorf("error parsing data: %w", err)
+	}
+	return d.report, nil
+}
+
+// basePointer parses a dotted base or a JSON Pointer
+func basePointer(base string) (parser.Pointer, error) {
+	if base == "" || parser.IsPointer(base) {
+		return parser.ParsePointer(base)
+	}
+	return parser.Pointer(strings.Split(strings.TrimPrefix(base, "."), ".")), nil
+}
+
+// handle records each value and follows the base elements
+func (d *discoverer) handle(event parser.Event) error {
+	switch event.Kind {
+	case parser.Key:
+		return nil
+	case parser.ObjectEnd, parser.ArrayEnd:
+		d.stack = d.stack[:len(d.stack)-1]
+		if event.Kind == parser.ObjectEnd && len(d.parser.Path()) == d.elementDepth {
+			d.endElement()
+		}
+		return nil
+	}
+
+	path := d.parser.Path()
+	if event.Kind == parser.ObjectStart && d.elementDepth < 0 && d.base.Match(path) {
+		d.elementDepth = len(path)
+	}
+	info := d.pathInfo(path)
+
+	switch event.Kind {
+	case parser.ObjectStart:
+		info.Types["object"]++
+	case parser.ArrayStart:
+		info.Types["array"]++
+	default:
+		info.Types[typeOf(event.Value)]++
+		d.sample(info, event.Value)
+	}
+	info.Count++
+	if d.elementDepth >= 0 {
+		d.seen[info] = true
+	}
+
+	if event.Kind == parser.ObjectStart || event.Kind == parser.ArrayStart {
+		d.stack = append(d.stack, info)
+	}
+	return nil
+}
+
+// pathInfo finds or creates the information of the value at path
+func (d *discoverer) pathInfo(path []parser.PathSegment) *PathInfo {
+	var parent *PathInfo
+	if len(d.stack) > 0 {
+		parent = d.stack[len(d.stack)-1]
+	}
+
+	var collapsed string
+	var last parser.PathSegment
+	if parent != nil {
+		last = path[len(path)-1]
+		collapsed = parent.Path + "[]"
+		if last.Index < 0 {
+			collapsed = parent.Path + "." + last.Key
+		}
+	}
+	if d.elementDepth >= 0 && len(path) == d.elementDepth {
+		d.elementPath = collapsed // the element starts here
+	}
+	if info, ok := d.report.index[collapsed]; ok {
+		return info
+	}
+
+	info := &PathInfo{Path: collapsed, Types: make(map[string]int)}
+	if parent != nil {
+		info.key, info.isItem = last.Key, last.Index >= 0
+		parent.children = append(parent.children, info)
+	}
+	if d.elementDepth >= 0 {
+		info.inElement = true
+		info.Field = fieldOf(strings.TrimPrefix(collapsed, d.elementPath))
+	}
+	d.report.index[collapsed] = info
+	d.report.Paths = append(d.report.Paths, info)
+	return info
+}
+
+// endElement counts the paths found in the element that just ended
+func (d *discoverer) endElement() {
+	d.report.Elements++
+	for info := range d.seen {
+		info.Elements++
+	}
+	clear(d.seen)
+	d.elementDepth = -1
+}
+
+// sample keeps a value if it is new and there is room for it
+func (d *discoverer) sample(info *PathInfo, value any) {
+	if len(info.Samples) >= d.samples {
+		return
+	}
+	for _, existing := range info.Samples {
+		if existing == value {
+			return
+		}
+	}
+	info.Samples = append(info.Samples, value)
+}
+
+// fieldOf converts a collapsed path relative to the element to a dotted field, where arrays are transparent
+func fieldOf(relative string) string {
+	relative = strings.ReplaceAll(relative, "[]", "")
+	return strings.TrimPrefix(relative, ".")
+}
+
+// typeOf returns the JSON type of a primitive value parsed with NumberText
+func typeOf(value any) string {
+	switch value := value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if strings.ContainsAny(value.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	case nil:
+		return "null"
+	}
+	return "number"
+}
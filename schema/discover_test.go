@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const catalog = `{
+	"title": "catalog",
+	"dataset": [
+		{"id": 1, "title": "a", "keyword": ["x", "y"], "size": 1.5, "publisher": {"name": "GSA"}},
+		{"id": 2, "title": null, "keyword": []},
+		{"id": 3, "title": "c", "keyword": ["x"], "size": 2}
+	]
+}`
+
+func TestDiscover(t *testing.T) {
+	report, err := Discover(strings.NewReader(catalog), DiscoverOptions{Base: ".dataset"})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if report.Elements != 3 {
+		t.Errorf("Elements = %d, want 3", report.Elements)
+	}
+
+	var paths []string
+	for _, info := range report.Paths {
+		paths = append(paths, info.Path)
+	}
+	want := []string{"", ".title", ".dataset", ".dataset[]", ".dataset[].id", ".dataset[].title", ".dataset[].keyword",
+		".dataset[].keyword[]", ".dataset[].size", ".dataset[].publisher", ".dataset[].publisher.name"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("paths = %q, want %q", paths, want)
+	}
+
+	tests := []struct {
+		path     string
+		field    string
+		types    map[string]int
+		presence float64
+		samples  []any
+	}{
+		{".title", "", map[string]int{"string": 1}, -1, []any{"catalog"}},
+		{".dataset[]", "", map[string]int{"object": 3}, 1, nil},
+		{".dataset[].title", "title", map[string]int{"string": 2, "null": 1}, 1, []any{"a", nil, "c"}},
+		{".dataset[].keyword[]", "keyword", map[string]int{"string": 3}, 2.0 / 3, []any{"x", "y"}},
+		{".dataset[].size", "size", map[string]int{"number": 1, "integer": 1}, 2.0 / 3, nil},
+		{".dataset[].publisher.name", "publisher.name", map[string]int{"string": 1}, 1.0 / 3, []any{"GSA"}},
+	}
+	for _, tt := range tests {
+		info := report.Lookup(tt.path)
+		if info == nil {
+			t.Errorf("Lookup(%q) = nil", tt.path)
+			continue
+		}
+		if info.Field != tt.field {
+			t.Errorf("%q Field = %q, want %q", tt.path, info.Field, tt.field)
+		}
+		if !reflect.DeepEqual(info.Types, tt.types) {
+			t.Errorf("%q Types = %v, want %v", tt.path, info.Types, tt.types)
+		}
+		if presence := report.Presence(info); presence != tt.presence {
+			t.Errorf("%q Presence() = %v, want %v", tt.path, presence, tt.presence)
+		}
+		if tt.samples != nil && !reflect.DeepEqual(info.Samples, tt.samples) {
+			t.Errorf("%q Samples = %v, want %v", tt.path, info.Samples, tt.samples)
+		}
+	}
+}
+
+func TestDiscoverOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     DiscoverOptions
+		elements int
+		samples  int
+	}{
+		{"root", DiscoverOptions{}, 1, 3},
+		{"pointer base", DiscoverOptions{Base: "/dataset"}, 3, 3},
+		{"one sample", DiscoverOptions{Base: "/dataset", Samples: 1}, 3, 1},
+		{"no samples", DiscoverOptions{Base: "/dataset", Samples: -1}, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Discover(strings.NewReader(catalog), tt.opts)
+			if err != nil {
+				t.Fatalf("Discover() error = %v", err)
+			}
+			if report.Elements != tt.elements {
+				t.Errorf("Elements = %d, want %d", report.Elements, tt.elements)
+			}
+			if samples := len(report.Lookup(".dataset[].title").Samples); samples != tt.samples {
+				t.Errorf("len(Samples) = %d, want %d", samples, tt.samples)
+			}
+		})
+	}
+
+	if _, err := Discover(strings.NewReader(`{"a": [1,`), DiscoverOptions{}); err == nil {
+		t.Error("Discover() on invalid JSON error = nil")
+	}
+	if _, err := Discover(strings.NewReader(catalog), DiscoverOptions{Base: "/a~2"}); err == nil {
+		t.Error("Discover() with invalid base error = nil")
+	}
+}
@@ -0,0 +1,131 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// draft is the JSON Schema dialect of the inferred schemas
+const draft = "https://json-schema.org/draft/2020-12/schema"
+
+// maxSampleLength is the number of characters of a sample shown in the text report
+const maxSampleLength = 40
+
+// typeOrder is the order the types are listed in
+var typeOrder = []string{"object", "array", "string", "integer", "number", "boolean", "null"}
+
+// WriteText writes the report as an aligned table, one line per path
+func (r *Report) WriteText(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(table, "Base elements: %d\n\n", r.Elements)
+	fmt.Fprintln(table, "PATH\tFIELD\tPRESENCE\tTYPES\tSAMPLES")
+	for _, info := range r.Paths {
+		path := info.Path
+		if path == "" {
+			path = "(root)"
+		}
+		presence := "-"
+		if ratio := r.Presence(info); ratio >= 0 {
+			presence = fmt.Sprintf("%.1f%%", ratio*100)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", path, info.Field, presence, formatTypes(info.Types), formatSamples(info.Samples))
+	}
+	return table.Flush()
+}
+
+// formatTypes lists the types with their counts, e.g. "string(10) null(2)"
+func formatTypes(types map[string]int) string {
+	var parts []string
+	for _, name := range typeOrder {
+		if count := types[name]; count > 0 {
+			parts = append(parts, fmt.Sprintf("%s(%d)", name, count))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatSamples lists the samples as JSON, shortened to maxSampleLength characters
+func formatSamples(samples []any) string {
+	parts := make([]string, len(samples))
+	for i, sample := range samples {
+		text, err := json.Marshal(sample)
+		if err != nil {
+			text = []byte(fmt.Sprint(sample))
+		}
+		if runes := []rune(string(text)); len(runes) > maxSampleLength {
+			text = []byte(string(runes[:maxSampleLength-3]) + "...")
+		}
+		parts[i] = string(text)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// JSONSchema infers a JSON Schema (draft 2020-12) describing the document
+// A property is required when it was found in every object holding its parent path,
+// and the samples are given as examples
+func (r *Report) JSONSchema() map[string]any {
+	root := r.Lookup("")
+	if root == nil {
+		return map[string]any{"$schema": draft}
+	}
+	schema := schemaOf(root)
+	schema["$schema"] = draft
+	return schema
+}
+
+// WriteJSONSchema writes the inferred JSON Schema as indented JSON
+func (r *Report) WriteJSONSchema(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r.JSONSchema())
+}
+
+// schemaOf infers the schema of the values at one path
+func schemaOf(info *PathInfo) map[string]any {
+	schema := make(map[string]any)
+
+	var types []string
+	for _, name := range typeOrder {
+		if info.Types[name] == 0 {
+			continue
+		}
+		// Integers are numbers, so "number" alone covers both
+		if name == "integer" && info.Types["number"] > 0 {
+			continue
+		}
+		types = append(types, name)
+	}
+	if len(types) == 1 {
+		schema["type"] = types[0]
+	} else if len(types) > 1 {
+		schema["type"] = types
+	}
+
+	properties := make(map[string]any)
+	var required []string
+	for _, child := range info.children {
+		if child.isItem {
+			schema["items"] = schemaOf(child)
+			continue
+		}
+		properties[child.key] = schemaOf(child)
+		if child.Count >= info.Types["object"] {
+			required = append(required, child.key)
+		}
+	}
+	if len(properties) > 0 {
+		schema["properties"] = properties
+	}
+	if len(required) > 0 {
+		slices.Sort(required)
+		schema["required"] = required
+	}
+	if len(info.Samples) > 0 {
+		schema["examples"] = info.Samples
+	}
+	return schema
+}
@@ -0,0 +1,96 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReportWriteText(t *testing.T) {
+	report, err := Discover(strings.NewReader(catalog), DiscoverOptions{Base: ".dataset"})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := report.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if lines[0] != "Base elements: 3" {
+		t.Errorf("first line = %q", lines[0])
+	}
+	want := map[string][]string{
+		"(root)":               {"-", "object(1)"},
+		".dataset[].title":     {"title", "100.0%", "string(2)", "null(1)", `"a",`, "null,", `"c"`},
+		".dataset[].keyword[]": {"keyword", "66.7%", "string(3)", `"x",`, `"y"`},
+		".dataset[].size":      {"size", "66.7%", "integer(1)", "number(1)", "1.5,", "2"},
+		".dataset[].publisher": {"publisher", "33.3%", "object(1)"},
+	}
+	for _, line := range lines {
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+		if expected, ok := want[words[0]]; ok {
+			if !reflect.DeepEqual(words[1:], expected) {
+				t.Errorf("line %q, want %q", words, expected)
+			}
+			delete(want, words[0])
+		}
+	}
+	for path := range want {
+		t.Errorf("missing line for %q", path)
+	}
+}
+
+func TestReportJSONSchema(t *testing.T) {
+	report, err := Discover(strings.NewReader(catalog), DiscoverOptions{Samples: -1})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := report.WriteJSONSchema(&buf); err != nil {
+		t.Fatalf("WriteJSONSchema() error = %v", err)
+	}
+	var got any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("WriteJSONSchema() wrote invalid JSON: %v", err)
+	}
+
+	var want any
+	json.Unmarshal([]byte(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"required": ["dataset", "title"],
+		"properties": {
+			"title": {"type": "string"},
+			"dataset": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"required": ["id", "keyword", "title"],
+					"properties": {
+						"id": {"type": "integer"},
+						"title": {"type": ["string", "null"]},
+						"keyword": {"type": "array", "items": {"type": "string"}},
+						"size": {"type": "number"},
+						"publisher": {"type": "object", "required": ["name"], "properties": {"name": {"type": "string"}}}
+					}
+				}
+			}
+		}
+	}`), &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("WriteJSONSchema() = %s", buf.String())
+	}
+
+	examples := (&Report{index: map[string]*PathInfo{"": {Types: map[string]int{"string": 1}, Samples: []any{"a"}}}}).JSONSchema()
+	if !reflect.DeepEqual(examples["examples"], []any{"a"}) {
+		t.Errorf("JSONSchema() examples = %v", examples["examples"])
+	}
+	if empty := (&Report{}).JSONSchema(); len(empty) != 1 {
+		t.Errorf("JSONSchema() of an empty report = %v", empty)
+	}
+}
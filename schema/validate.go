@@ -0,0 +1,539 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// Violation is a value of the document that does not satisfy the schema
+type Violation struct {
+	Pointer string // the location of the value as a JSON Pointer, e.g. "/dataset/3/title", "" for the document
+	Offset  int64  // the byte offset of the first character of the value in the input
+	Keyword string // the keyword that failed, e.g. "required"
+	Message string
+}
+
+// String formats the violation, e.g. `/dataset/3 (offset 1024): required: missing property "title"`
+func (v Violation) String() string {
+	pointer := v.Pointer
+	if pointer == "" {
+		pointer = "(root)"
+	}
+	return fmt.Sprintf("%s (offset %d): %s: %s", pointer, v.Offset, v.Keyword, v.Message)
+}
+
+// ValidateOptions configures Validate
+type ValidateOptions struct {
+	// Parser configures the parser, strings are always decoded to check their length and pattern
+	Parser parser.Options
+}
+
+// Validate streams the document from reader and calls handler with every violation of the schema, in document order
+// of the values, except that the violations found at the end of an object or array (e.g. required) come after
+// the ones inside it. Validation stops at the first error returned by the handler
+func Validate(reader io.Reader, s *Schema, opts ValidateOptions, handler func(Violation) error) error {
+	parserOptions := opts.Parser
+	parserOptions.DecodeStrings = true
+	jsonParser, err := parser.NewParser(reader, parserOptions, nil)
+	if err != nil {
+		return err
+	}
+
+	validator := s.NewValidator(handler)
+	jsonParser.SetEventHandler(func(event parser.Event) error {
+		switch event.Kind {
+		case parser.ObjectStart, parser.ArrayStart, parser.Value:
+			return validator.Start(jsonParser.Path(), event.Kind, event.Value, jsonParser.Offset())
+		case parser.ObjectEnd, parser.ArrayEnd:
+			return validator.End(jsonParser.Path())
+		}
+		return nil
+	})
+
+	if err := jsonParser.Parse(); err != nil {
+		return fmt.Errorf("error parsing data: %w", err)
+	}
+	return nil
+}
+
+// Validator checks one value against the schema, given as the starts and ends of the values inside it
+// It is driven by Validate, or by any code following the events of a parser, e.g. to validate the
+// elements of an array one by one
+type Validator struct {
+	schema *Schema
+	report func(Violation) error
+	frames []validationFrame // the open objects and arrays
+}
+
+// check is a schema applied to a value
+// Inside anyOf, oneOf and not, the violations go to the branch being tried instead of the report
+type check struct {
+	node   *node
+	branch *branch
+}
+
+// branch collects the outcome of one subschema of anyOf, oneOf or not
+type branch struct {
+	failed bool
+}
+
+// combination is an anyOf, oneOf or not, decided once the value has been checked against all its branches
+type combination struct {
+	keyword  string
+	branches []*branch
+	parent   *branch // where the failure of the combination goes
+}
+
+// validationFrame holds the checks of an open object or array
+type validationFrame struct {
+	checks       []check
+	combinations []combination
+	offset       int64
+	keys         map[string]bool // the keys found in an object
+	count        int             // the number of properties or items found
+	capture      bool            // whether the value is rebuilt for enum and const
+	value        any             // the rebuilt value
+}
+
+// NewValidator creates a validator of one value against the schema, report is called with each violation
+func (s *Schema) NewValidator(report func(Violation) error) *Validator {
+	return &Validator{schema: s, report: report}
+}
+
+// Reset forgets the current value, e.g. after an error, to validate the next one
+func (v *Validator) Reset() {
+	v.frames = v.frames[:0]
+}
+
+// Start checks the start of a value at path: an object or an array (its end is given to End) or a primitive value
+// The first call gives the value validated against the whole schema, the next ones the values inside it
+func (v *Validator) Start(path []parser.PathSegment, kind parser.EventKind, value any, offset int64) error {
+	var checks []check
+	var combinations []combination
+	var err error
+	if len(v.frames) == 0 {
+		err = v.expand(check{node: v.schema.root}, &checks, &combinations, path, offset)
+	} else {
+		parent := &v.frames[len(v.frames)-1]
+		segment := path[len(path)-1]
+		parent.count++
+		if segment.Index < 0 {
+			parent.keys[segment.Key] = true
+		}
+		err = v.children(parent, segment, &checks, &combinations, path, offset)
+	}
+	if err != nil {
+		return err
+	}
+
+	if kind == parser.ObjectStart {
+		value = map[string]any{}
+	} else if kind == parser.ArrayStart {
+		value = []any{}
+	}
+	v.capture(path, value)
+
+	for _, c := range checks {
+		if err := v.checkValue(c, path, kind, value, offset); err != nil {
+			return err
+		}
+	}
+
+	if kind != parser.ObjectStart && kind != parser.ArrayStart {
+		return v.decide(combinations, path, offset)
+	}
+	frame := validationFrame{checks: checks, combinations: combinations, offset: offset}
+	if kind == parser.ObjectStart {
+		frame.keys = make(map[string]bool)
+	}
+	for _, c := range checks {
+		if c.node.hasEnum || c.node.hasConst {
+			frame.capture, frame.value = true, value
+		}
+	}
+	v.frames = append(v.frames, frame)
+	return nil
+}
+
+// End checks the object or array ending at path
+func (v *Validator) End(path []parser.PathSegment) error {
+	if len(v.frames) == 0 {
+		return nil
+	}
+	frame := v.frames[len(v.frames)-1]
+	v.frames = v.frames[:len(v.frames)-1]
+
+	for _, c := range frame.checks {
+		n := c.node
+		if frame.keys != nil {
+			for _, name := range n.required {
+				if !frame.keys[name] {
+					if err := v.fail(c.branch, path, frame.offset, "required", fmt.Sprintf("missing property %q", name)); err != nil {
+						return err
+					}
+				}
+			}
+			if err := v.checkCount(c, path, frame.offset, "Properties", frame.count, n.minProperties, n.maxProperties); err != nil {
+				return err
+			}
+		} else {
+			if err := v.checkCount(c, path, frame.offset, "Items", frame.count, n.minItems, n.maxItems); err != nil {
+				return err
+			}
+		}
+		if frame.capture {
+			if err := v.checkEnum(c, path, frame.offset, frame.value); err != nil {
+				return err
+			}
+		}
+	}
+	return v.decide(frame.combinations, path, frame.offset)
+}
+
+// children finds the checks of a property or item from the checks of its object or array
+func (v *Validator) children(parent *validationFrame, segment parser.PathSegment, checks *[]check, combinations *[]combination, path []parser.PathSegment, offset int64) error {
+	for _, c := range parent.checks {
+		var child *node
+		var keyword string
+		if segment.Index < 0 {
+			child, keyword = c.node.properties[segment.Key], "additionalProperties"
+			if child == nil {
+				child = c.node.additional
+			}
+		} else {
+			child, keyword = c.node.items, "items"
+			if segment.Index < len(c.node.prefixItems) {
+				child = c.node.prefixItems[segment.Index]
+			}
+		}
+		if child == nil {
+			continue
+		}
+		if child.reject && (child == c.node.additional || child == c.node.items) {
+			message := "no additional items are allowed"
+			if segment.Index < 0 {
+				message = fmt.Sprintf("property %q is not allowed", segment.Key)
+			}
+			if err := v.fail(c.branch, path, offset, keyword, message); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := v.expand(check{node: child, branch: c.branch}, checks, combinations, path, offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expand adds a check and the checks of the subschemas applying to the same value: $ref, allOf, anyOf, oneOf and not
+func (v *Validator) expand(c check, checks *[]check, combinations *[]combination, path []parser.PathSegment, offset int64) error {
+	for _, existing := range *checks {
+		if existing == c {
+			return nil // a reference cycle
+		}
+	}
+	if c.node.reject {
+		return v.fail(c.branch, path, offset, "false", "no value is allowed here")
+	}
+	*checks = append(*checks, c)
+
+	n := c.node
+	if n.ref != nil {
+		if err := v.expand(check{node: n.ref, branch: c.branch}, checks, combinations, path, offset); err != nil {
+			return err
+		}
+	}
+	for _, sub := range n.allOf {
+		if err := v.expand(check{node: sub, branch: c.branch}, checks, combinations, path, offset); err != nil {
+			return err
+		}
+	}
+	for _, group := range []struct {
+		keyword string
+		nodes   []*node
+	}{{"anyOf", n.anyOf}, {"oneOf", n.oneOf}, {"not", []*node{n.not}}} {
+		if len(group.nodes) == 0 || group.nodes[0] == nil {
+			continue
+		}
+		// The combinations nested in the branches come after this one, to be decided before it
+		combination := combination{keyword: group.keyword, parent: c.branch}
+		for range group.nodes {
+			combination.branches = append(combination.branches, &branch{})
+		}
+		*combinations = append(*combinations, combination)
+		for i, sub := range group.nodes {
+			if err := v.expand(check{node: sub, branch: combination.branches[i]}, checks, combinations, path, offset); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decide checks the combinations of a value once all their branches are known, the innermost first
+func (v *Validator) decide(combinations []combination, path []parser.PathSegment, offset int64) error {
+	for i := len(combinations) - 1; i >= 0; i-- {
+		combination := combinations[i]
+		passed := 0
+		for _, b := range combination.branches {
+			if !b.failed {
+				passed++
+			}
+		}
+
+		var message string
+		switch {
+		case combination.keyword == "anyOf" && passed == 0:
+			message = "the value does not match any of the schemas"
+		case combination.keyword == "oneOf" && passed != 1:
+			message = fmt.Sprintf("the value matches %d of the schemas instead of exactly one", passed)
+		case combination.keyword == "not" && passed == 1:
+			message = "the value matches the schema it must not match"
+		default:
+			continue
+		}
+		if err := v.fail(combination.parent, path, offset, combination.keyword, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkValue checks the keywords known at the start of a value
+func (v *Validator) checkValue(c check, path []parser.PathSegment, kind parser.EventKind, value any, offset int64) error {
+	n := c.node
+	actual := typeName(kind, value)
+	if len(n.types) > 0 && !matchType(n.types, actual) {
+		message := fmt.Sprintf("expected %s, found %s", strings.Join(n.types, " or "), actual)
+		if err := v.fail(c.branch, path, offset, "type", message); err != nil {
+			return err
+		}
+	}
+	if kind != parser.Value {
+		return nil
+	}
+	if err := v.checkEnum(c, path, offset, value); err != nil {
+		return err
+	}
+
+	switch value := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(value)
+		if err := v.checkCount(c, path, offset, "Length", length, n.minLength, n.maxLength); err != nil {
+			return err
+		}
+		if n.pattern != nil && !n.pattern.MatchString(value) {
+			if err := v.fail(c.branch, path, offset, "pattern", fmt.Sprintf("%q does not match %q", value, n.pattern)); err != nil {
+				return err
+			}
+		}
+	default:
+		number, ok := toFloat(value)
+		if !ok {
+			return nil
+		}
+		limits := []struct {
+			keyword string
+			limit   *float64
+			failed  func(float64) bool
+		}{
+			{"minimum", n.minimum, func(limit float64) bool { return number < limit }},
+			{"maximum", n.maximum, func(limit float64) bool { return number > limit }},
+			{"exclusiveMinimum", n.exclusiveMinimum, func(limit float64) bool { return number <= limit }},
+			{"exclusiveMaximum", n.exclusiveMaximum, func(limit float64) bool { return number >= limit }},
+		}
+		for _, limit := range limits {
+			if limit.limit != nil && limit.failed(*limit.limit) {
+				message := fmt.Sprintf("%v is out of the %s of %v", number, limit.keyword, *limit.limit)
+				if err := v.fail(c.branch, path, offset, limit.keyword, message); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkEnum checks the enum and const keywords
+func (v *Validator) checkEnum(c check, path []parser.PathSegment, offset int64, value any) error {
+	n := c.node
+	if n.hasConst && !equalValues(value, n.constant) {
+		if err := v.fail(c.branch, path, offset, "const", fmt.Sprintf("expected %s", formatValue(n.constant))); err != nil {
+			return err
+		}
+	}
+	if !n.hasEnum {
+		return nil
+	}
+	for _, allowed := range n.enum {
+		if equalValues(value, allowed) {
+			return nil
+		}
+	}
+	return v.fail(c.branch, path, offset, "enum", fmt.Sprintf("%s is not one of the allowed values", formatValue(value)))
+}
+
+// checkCount checks a minimum and maximum count, e.g. minItems and maxItems for the suffix "Items"
+func (v *Validator) checkCount(c check, path []parser.PathSegment, offset int64, suffix string, count int, minimum int, maximum int) error {
+	if minimum >= 0 && count < minimum {
+		message := fmt.Sprintf("found %d, expected at least %d", count, minimum)
+		if err := v.fail(c.branch, path, offset, "min"+suffix, message); err != nil {
+			return err
+		}
+	}
+	if maximum >= 0 && count > maximum {
+		message := fmt.Sprintf("found %d, expected at most %d", count, maximum)
+		return v.fail(c.branch, path, offset, "max"+suffix, message)
+	}
+	return nil
+}
+
+// fail reports a violation, or marks the branch of anyOf, oneOf or not it was found in as failed
+func (v *Validator) fail(b *branch, path []parser.PathSegment, offset int64, keyword string, message string) error {
+	if b != nil {
+		b.failed = true
+		return nil
+	}
+	if v.report == nil {
+		return nil
+	}
+	return v.report(Violation{Pointer: pointerOf(path), Offset: offset, Keyword: keyword, Message: message})
+}
+
+// capture adds a value to the objects and arrays rebuilt for enum and const
+func (v *Validator) capture(path []parser.PathSegment, value any) {
+	for depth := range v.frames {
+		frame := &v.frames[depth]
+		if frame.capture {
+			frame.value = insertValue(frame.value, path[len(path)-len(v.frames)+depth:], value)
+		}
+	}
+}
+
+// insertValue sets the value at the path relative to a container, returning the updated container
+func insertValue(container any, relative []parser.PathSegment, value any) any {
+	if len(relative) == 0 {
+		return value
+	}
+	segment := relative[0]
+	if segment.Index < 0 {
+		object := container.(map[string]any)
+		object[segment.Key] = insertValue(object[segment.Key], relative[1:], value)
+		return object
+	}
+	array := container.([]any)
+	if segment.Index == len(array) {
+		array = append(array, nil)
+	}
+	array[segment.Index] = insertValue(array[segment.Index], relative[1:], value)
+	return array
+}
+
+// typeName returns the JSON type of a value, "integer" for the numbers without a fraction
+func typeName(kind parser.EventKind, value any) string {
+	switch kind {
+	case parser.ObjectStart:
+		return "object"
+	case parser.ArrayStart:
+		return "array"
+	}
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	if number, ok := toFloat(value); ok && number == math.Trunc(number) && !math.IsInf(number, 0) {
+		return "integer"
+	}
+	return "number"
+}
+
+// matchType checks the type of a value against the allowed types, integers being numbers too
+func matchType(types []string, actual string) bool {
+	for _, name := range types {
+		if name == actual || (name == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// toFloat converts the numbers of any number mode of the parser
+func toFloat(value any) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case int64:
+		return float64(value), true
+	case json.Number:
+		number, err := strconv.ParseFloat(value.String(), 64)
+		return number, err == nil || math.IsInf(number, 0)
+	}
+	return 0, false
+}
+
+// equalValues compares two JSON values, numbers by their value whatever their Go type
+func equalValues(a any, b any) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	switch a := a.(type) {
+	case map[string]any:
+		other, ok := b.(map[string]any)
+		if !ok || len(a) != len(other) {
+			return false
+		}
+		for key, value := range a {
+			if otherValue, ok := other[key]; !ok || !equalValues(value, otherValue) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		other, ok := b.([]any)
+		if !ok || len(a) != len(other) {
+			return false
+		}
+		for i := range a {
+			if !equalValues(a[i], other[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// formatValue formats a value as JSON in messages
+func formatValue(value any) string {
+	text, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(text)
+}
+
+// pointerOf formats a path as a JSON Pointer
+func pointerOf(path []parser.PathSegment) string {
+	tokens := make(parser.Pointer, len(path))
+	for i, segment := range path {
+		if segment.Index < 0 {
+			tokens[i] = segment.Key
+		} else {
+			tokens[i] = strconv.Itoa(segment.Index)
+		}
+	}
+	return tokens.String()
+}
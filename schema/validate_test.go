@@ -0,0 +1,141 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bluesky0724/jsonstream/parser"
+)
+
+// validateAll collects the violations of a document as strings
+func validateAll(t *testing.T, schemaText string, document string) []string {
+	t.Helper()
+	s, err := CompileSchema([]byte(schemaText))
+	if err != nil {
+		t.Fatalf("CompileSchema() error = %v", err)
+	}
+	var violations []string
+	err = Validate(strings.NewReader(document), s, ValidateOptions{Parser: parser.Options{ChunkSize: 4}}, func(v Violation) error {
+		violations = append(violations, v.String())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	return violations
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		schema     string
+		document   string
+		violations []string
+	}{
+		{"valid", `{"type": "object", "properties": {"a": {"type": "integer"}}}`, `{"a": 1}`, nil},
+		{"type", `{"properties": {"a": {"type": "integer"}, "b": {"type": ["string", "null"]}}}`, `{"a": 1.5, "b": []}`,
+			[]string{"/a (offset 6): type: expected integer, found number", "/b (offset 16): type: expected string or null, found array"}},
+		{"integer is a number", `{"type": "number"}`, `10`, nil},
+		{"required", `{"required": ["a", "b"]}`, `{"a": 1}`, []string{`(root) (offset 0): required: missing property "b"`}},
+		{"additionalProperties", `{"properties": {"a": true}, "additionalProperties": false}`, `{"a": 1, "b": {"c": 2}}`,
+			[]string{`/b (offset 14): additionalProperties: property "b" is not allowed`}},
+		{"additionalProperties schema", `{"properties": {"a": true}, "additionalProperties": {"type": "string"}}`, `{"a": 1, "b": 2}`,
+			[]string{"/b (offset 14): type: expected string, found integer"}},
+		{"items", `{"prefixItems": [{"type": "string"}], "items": {"type": "integer"}, "maxItems": 2}`, `["a", "b", 3]`,
+			[]string{"/1 (offset 6): type: expected integer, found string", "(root) (offset 0): maxItems: found 3, expected at most 2"}},
+		{"items false", `{"items": [true], "additionalItems": false, "minItems": 2}`, `[1]`,
+			[]string{"(root) (offset 0): minItems: found 1, expected at least 2"}},
+		{"strings", `{"items": {"minLength": 2, "maxLength": 3, "pattern": "^\\pL+$"}}`, `["é", "abcd", "ab", "A1"]`,
+			[]string{"/0 (offset 1): minLength: found 1, expected at least 2", "/1 (offset 7): maxLength: found 4, expected at most 3", `/3 (offset 21): pattern: "A1" does not match "^\\pL+$"`}},
+		{"escaped strings", `{"const": "a\"b"}`, `"a\"b"`, nil},
+		{"numbers", `{"items": {"minimum": 0, "exclusiveMaximum": 10}}`, `[0, -1, 10, 9.5]`,
+			[]string{"/1 (offset 4): minimum: -1 is out of the minimum of 0", "/2 (offset 8): exclusiveMaximum: 10 is out of the exclusiveMaximum of 10"}},
+		{"draft-04 exclusiveMinimum", `{"minimum": 0, "exclusiveMinimum": true}`, `0`,
+			[]string{"(root) (offset 0): exclusiveMinimum: 0 is out of the exclusiveMinimum of 0"}},
+		{"enum", `{"items": {"enum": ["public", "restricted public", 1]}}`, `["public", 1.0, "private"]`,
+			[]string{`/2 (offset 16): enum: "private" is not one of the allowed values`}},
+		{"enum of objects", `{"items": {"enum": [{"a": [1, {"b": null}]}]}}`, `[{"a": [1, {"b": null}]}, {"a": [1]}]`,
+			[]string{`/1 (offset 26): enum: {"a":[1]} is not one of the allowed values`}},
+		{"const", `{"properties": {"a": {"const": [1, 2]}}}`, `{"a": [1, 3]}`, []string{"/a (offset 6): const: expected [1,2]"}},
+		{"minProperties", `{"minProperties": 1, "maxProperties": 1}`, `[{}, {"a": 1, "b": 2}]`, nil},
+		{"properties counts", `{"items": {"minProperties": 1, "maxProperties": 1}}`, `[{}, {"a": 1, "b": 2}]`,
+			[]string{"/0 (offset 1): minProperties: found 0, expected at least 1", "/1 (offset 5): maxProperties: found 2, expected at most 1"}},
+		{"ref", `{"$defs": {"node": {"required": ["v"], "properties": {"next": {"$ref": "#/$defs/node"}}}}, "$ref": "#/$defs/node"}`,
+			`{"v": 1, "next": {"v": 2, "next": {}}}`, []string{`/next/next (offset 34): required: missing property "v"`}},
+		{"allOf", `{"allOf": [{"type": "string"}, {"maxLength": 1}]}`, `"ab"`, []string{"(root) (offset 0): maxLength: found 2, expected at most 1"}},
+		{"anyOf", `{"items": {"anyOf": [{"type": "string"}, {"type": "object", "required": ["a"]}]}}`, `["x", {"a": 1}, {"b": 1}]`,
+			[]string{"/2 (offset 16): anyOf: the value does not match any of the schemas"}},
+		{"oneOf", `{"items": {"oneOf": [{"type": "integer"}, {"minimum": 2}]}}`, `[1, 2.5, 3, "x"]`,
+			[]string{"/2 (offset 9): oneOf: the value matches 2 of the schemas instead of exactly one"}},
+		{"not", `{"items": {"not": {"type": "null"}}}`, `[1, null]`, []string{"/1 (offset 4): not: the value matches the schema it must not match"}},
+		{"nested combinations", `{"anyOf": [{"not": {"type": "string"}}, {"const": "x"}]}`, `"y"`,
+			[]string{"(root) (offset 0): anyOf: the value does not match any of the schemas"}},
+		{"false", `{"properties": {"a": false}}`, `{"a": 1}`, []string{"/a (offset 6): false: no value is allowed here"}},
+		{"pointer escapes", `{"additionalProperties": false}`, `{"a/b~c": 1}`, []string{`/a~1b~0c (offset 10): additionalProperties: property "a/b~c" is not allowed`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := validateAll(t, tt.schema, tt.document)
+			if !reflect.DeepEqual(violations, tt.violations) {
+				t.Errorf("violations = %q, want %q", violations, tt.violations)
+			}
+		})
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	s, err := CompileSchema([]byte(`{"items": {"type": "string"}}`))
+	if err != nil {
+		t.Fatalf("CompileSchema() error = %v", err)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = Validate(strings.NewReader(`[1, 2, 3]`), s, ValidateOptions{}, func(Violation) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Validate() = %v after %d calls, want the handler error after 1 call", err, calls)
+	}
+
+	if err := Validate(strings.NewReader(`["a", `), s, ValidateOptions{}, nil); err == nil {
+		t.Error("Validate() of invalid JSON error = nil")
+	}
+}
+
+func TestValidatorReuse(t *testing.T) {
+	s, err := CompileSchema([]byte(`{"required": ["id"]}`))
+	if err != nil {
+		t.Fatalf("CompileSchema() error = %v", err)
+	}
+	var violations []Violation
+	validator := s.NewValidator(func(v Violation) error {
+		violations = append(violations, v)
+		return nil
+	})
+
+	// Each element of the array is validated on its own
+	element := []parser.PathSegment{{Index: 0}}
+	for i, id := range []bool{true, false} {
+		element[0].Index = i
+		validator.Reset()
+		if err := validator.Start(element, parser.ObjectStart, nil, int64(i*10)); err != nil {
+			t.Fatal(err)
+		}
+		if id {
+			if err := validator.Start(append(element, parser.PathSegment{Key: "id", Index: -1}), parser.Value, 1.0, 5); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := validator.End(element); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []Violation{{Pointer: "/1", Offset: 10, Keyword: "required", Message: `missing property "id"`}}
+	if !reflect.DeepEqual(violations, want) {
+		t.Errorf("violations = %+v, want %+v", violations, want)
+	}
+}